@@ -0,0 +1,126 @@
+package artifact
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tychonis/cyanotype/cmd/flags"
+	"github.com/tychonis/cyanotype/core/artifact"
+	"github.com/tychonis/cyanotype/core/catalog"
+	"github.com/tychonis/cyanotype/model"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "artifact",
+	Short: "Artifact reads artifacts stored in the local catalog",
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get <qualifier> <name>",
+	Short: "Write an artifact of a symbol to a file",
+	Args:  cobra.ExactArgs(2),
+	RunE:  get,
+}
+
+var output string
+
+func init() {
+	getCmd.Flags().StringVarP(&output, "output", "o", "", "set output path, defaults to the artifact filename")
+	Cmd.AddCommand(getCmd)
+}
+
+func findArtifact(sym model.ConcreteSymbol, name string) (*model.Artifact, error) {
+	var content *model.ItemContent
+	switch s := sym.(type) {
+	case *model.Item:
+		content = s.Content
+	case *model.CoItem:
+		content = s.Content
+	}
+	if content != nil {
+		for _, a := range content.Artifacts {
+			if a.Name == name {
+				return a, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s has no artifact %s", sym.GetQualifier(), name)
+}
+
+func get(cmd *cobra.Command, args []string) error {
+	qualifier := args[0]
+	name := args[1]
+
+	cat := catalog.New("local")
+	sym, err := cat.FindCurrent(qualifier)
+	if err != nil {
+		slog.Error("Failed to find symbol.", "qualifier", qualifier, "error", err)
+		return flags.ErrReported
+	}
+	a, err := findArtifact(sym, name)
+	if err != nil {
+		slog.Error("Failed to find artifact.", "error", err)
+		return flags.ErrReported
+	}
+	path := output
+	if path == "" && a.Filename != "" {
+		path = filepath.Base(a.Filename)
+	}
+	if path == "" {
+		path = name
+	}
+	if len(a.Files) > 0 {
+		err = writeFiles(cat, a, path)
+		if err != nil {
+			slog.Error("Failed to write artifact.", "artifact", name, "error", err)
+			return flags.ErrReported
+		}
+		slog.Info("Artifact written.", "path", path, "files", len(a.Files), "digest", a.Digest)
+		return nil
+	}
+	err = writeFile(cat, a.Digest, path)
+	if err != nil {
+		slog.Error("Failed to write artifact.", "artifact", name, "error", err)
+		return flags.ErrReported
+	}
+	slog.Info("Artifact written.", "path", path, "digest", a.Digest)
+	return nil
+}
+
+func writeFile(cat *catalog.Catalog, digest model.Digest, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = cat.WriteArtifact(digest, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// writeFiles writes the files of a directory or glob artifact under dir.
+func writeFiles(cat *catalog.Catalog, a *model.Artifact, dir string) error {
+	for _, file := range a.Files {
+		path, err := artifact.LocalPath(dir, file)
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			return err
+		}
+		err = writeFile(cat, file.Digest, path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/tychonis/cyanotype/cmd/flags"
 	"github.com/tychonis/cyanotype/core/catalog"
 	"github.com/tychonis/cyanotype/core/instantiator"
 	"github.com/tychonis/cyanotype/core/parser/hcl"
@@ -13,15 +14,18 @@ import (
 var Cmd = &cobra.Command{
 	Use:   "bom <path> <root>",
 	Short: "Generate bom from bpo",
-	Run:   run,
+	RunE:  run,
 	Args:  cobra.MinimumNArgs(2),
 }
 
+var variables *flags.Variables
+
 func init() {
 	Cmd.Flags().StringP("output", "o", "csv", "set output format")
+	variables = flags.AddVariables(Cmd)
 }
 
-func run(cmd *cobra.Command, args []string) {
+func run(cmd *cobra.Command, args []string) error {
 	bomPath := args[0]
 	rootPart := args[1]
 
@@ -31,23 +35,28 @@ func run(cmd *cobra.Command, args []string) {
 	}
 
 	p := hcl.NewParser()
-	err := p.Build(bomPath)
+	err := variables.Apply(p)
+	if err != nil {
+		slog.Error("Invalid variables.", "error", err)
+		return flags.ErrReported
+	}
+	err = p.Build(bomPath)
 	if err != nil {
-		slog.Warn("Failed to parse bpo.", "error", err)
-		return
+		return flags.ReportDiagnostics(err, p.Files())
 	}
 
 	cat := catalog.New("memory")
 	err = p.Commit(cat)
 	if err != nil {
-		slog.Error("Failed to commit to catalog.", "error", err)
-		return
+		return flags.ReportDiagnostics(err, p.Files())
 	}
 
 	ins := instantiator.New()
 	counter, err := ins.Count(cat, rootPart)
 	if err != nil {
-		slog.Warn("Error counting", "error", err)
+		slog.Error("Error counting", "error", err)
+		return flags.ErrReported
 	}
 	ins.CounterToCSV(counter)
+	return nil
 }
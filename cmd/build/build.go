@@ -2,19 +2,29 @@ package build
 
 import (
 	"log/slog"
+	"os"
 
 	"github.com/spf13/cobra"
 
+	"github.com/tychonis/cyanotype/cmd/flags"
 	"github.com/tychonis/cyanotype/core/parser/hcl"
 )
 
 var Cmd = &cobra.Command{
 	Use:   "build <path>",
 	Short: "Build revision from bpo, report errors but don't commit to catalog",
-	Run:   run,
+	RunE:  run,
 }
 
-func run(cmd *cobra.Command, args []string) {
+var variables *flags.Variables
+var watching *flags.Watch
+
+func init() {
+	variables = flags.AddVariables(Cmd)
+	watching = flags.AddWatch(Cmd)
+}
+
+func run(cmd *cobra.Command, args []string) error {
 	var bpoPath string
 	if len(args) == 0 {
 		bpoPath = "."
@@ -22,10 +32,23 @@ func run(cmd *cobra.Command, args []string) {
 		bpoPath = args[0]
 	}
 
-	core := hcl.NewParser()
-	err := core.Build(bpoPath)
-	if err != nil {
-		slog.Warn("Failed to parse bpo.", "error", err)
-		return
+	if info, err := os.Stat(bpoPath); err == nil && !info.IsDir() && !hcl.IsSource(bpoPath) {
+		slog.Error("Not a source file, expected .bpo, .bpo.json or .bpo.yaml.", "path", bpoPath)
+		return flags.ErrReported
 	}
+
+	return watching.Run(bpoPath, func() (*hcl.Parser, error) {
+		core := hcl.NewParser()
+		core.Options.Cache = watching.Cache
+		err := variables.Apply(core)
+		if err != nil {
+			slog.Error("Invalid variables.", "error", err)
+			return nil, flags.ErrReported
+		}
+		err = core.Build(bpoPath)
+		if err != nil {
+			return core, flags.ReportDiagnostics(err, core.Files())
+		}
+		return core, nil
+	})
 }
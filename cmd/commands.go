@@ -1,16 +1,25 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/tychonis/cyanotype/cmd/artifact"
 	"github.com/tychonis/cyanotype/cmd/bom"
 	"github.com/tychonis/cyanotype/cmd/build"
 	"github.com/tychonis/cyanotype/cmd/commit"
 	"github.com/tychonis/cyanotype/cmd/export"
+	"github.com/tychonis/cyanotype/cmd/flags"
+	"github.com/tychonis/cyanotype/cmd/format"
 	"github.com/tychonis/cyanotype/cmd/history"
+	"github.com/tychonis/cyanotype/cmd/importer"
 	"github.com/tychonis/cyanotype/cmd/initialize"
+	"github.com/tychonis/cyanotype/cmd/lint"
+	"github.com/tychonis/cyanotype/cmd/lsp"
+	"github.com/tychonis/cyanotype/cmd/materialize"
 	"github.com/tychonis/cyanotype/cmd/plan"
 	"github.com/tychonis/cyanotype/cmd/pull"
 	"github.com/tychonis/cyanotype/cmd/push"
@@ -21,11 +30,18 @@ import (
 
 var debug bool
 
+// started is set once arguments and flags are accepted. Errors returned
+// after that point have been logged by the command itself.
+var started bool
+
 var rootCmd = &cobra.Command{
-	Use:   "cyanotype",
-	Short: "cyanotype manages bom as code",
-	Long:  "TODO: Add doc string",
+	Use:           "cyanotype",
+	Short:         "cyanotype manages bom as code",
+	Long:          "TODO: Add doc string",
+	SilenceErrors: true,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		started = true
+		cmd.SilenceUsage = true
 		if debug {
 			slog.SetLogLoggerLevel(slog.LevelDebug)
 			slog.Debug("Debug logging enabled")
@@ -35,13 +51,20 @@ var rootCmd = &cobra.Command{
 
 func Run() {
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "enable debug logging")
+	flags.AddDiagnosticsFormat(rootCmd)
 
 	rootCmd.AddCommand(
 		initialize.Cmd,
+		artifact.Cmd,
 		bom.Cmd,
 		build.Cmd,
 		commit.Cmd,
 		export.Cmd,
+		format.Cmd,
+		importer.Cmd,
+		lint.Cmd,
+		lsp.Cmd,
+		materialize.Cmd,
 		tree.Cmd,
 		pull.Cmd,
 		push.Cmd,
@@ -52,8 +75,13 @@ func Run() {
 	)
 
 	err := rootCmd.Execute()
-	if err != nil {
-		rootCmd.Usage()
+	if err == nil {
+		os.Exit(0)
+	}
+	if !started {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	} else if !errors.Is(err, flags.ErrReported) {
+		slog.Error("Command failed.", "error", err)
 	}
-	os.Exit(0)
+	os.Exit(1)
 }
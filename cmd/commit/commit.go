@@ -5,6 +5,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/tychonis/cyanotype/cmd/flags"
 	"github.com/tychonis/cyanotype/core/catalog"
 	"github.com/tychonis/cyanotype/core/parser/hcl"
 )
@@ -12,31 +13,37 @@ import (
 var Cmd = &cobra.Command{
 	Use:   "commit",
 	Short: "Build bpc from bpo",
-	Run:   run,
+	RunE:  run,
 }
 var ignoreArtifacts bool
+var variables *flags.Variables
 
 func init() {
 	// TODO: distinguish from output format
 	Cmd.Flags().StringP("output", "o", "", "set output path")
 	Cmd.Flags().BoolVar(&ignoreArtifacts, "ignore-artifacts", false, "ignore artifacts during commit")
+	variables = flags.AddVariables(Cmd)
 }
 
-func run(cmd *cobra.Command, args []string) {
+func run(cmd *cobra.Command, args []string) error {
 	bpoPath := "."
 
 	p := hcl.NewParser()
 	p.Options.IgnoreArtifacts = ignoreArtifacts
-	err := p.Build(bpoPath)
+	err := variables.Apply(p)
 	if err != nil {
-		slog.Warn("Failed to parse bpo.", "error", err)
-		return
+		slog.Error("Invalid variables.", "error", err)
+		return flags.ErrReported
+	}
+	err = p.Build(bpoPath)
+	if err != nil {
+		return flags.ReportDiagnostics(err, p.Files())
 	}
 
 	cat := catalog.New("local")
 	err = p.Commit(cat)
 	if err != nil {
-		slog.Error("Failed to commit to catalog.", "error", err)
-		return
+		return flags.ReportDiagnostics(err, p.Files())
 	}
+	return nil
 }
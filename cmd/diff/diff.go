@@ -10,17 +10,24 @@ import (
 var Cmd = &cobra.Command{
 	Use:   "diff",
 	Short: "Compare built out from two version",
-	Run:   run,
+	RunE:  run,
 	Args:  cobra.MinimumNArgs(2),
 }
 
-func run(cmd *cobra.Command, args []string) {
+func run(cmd *cobra.Command, args []string) error {
 	commitA := args[0]
 	commitB := args[1]
-	treeA, _ := getCommitTree(".", commitA)
-	treeB, _ := getCommitTree(".", commitB)
+	treeA, err := getCommitTree(".", commitA)
+	if err != nil {
+		return err
+	}
+	treeB, err := getCommitTree(".", commitB)
+	if err != nil {
+		return err
+	}
 	buildOnTree(treeA)
 	buildOnTree(treeB)
+	return nil
 }
 
 func getCommitTree(repo string, commit string) (*object.Tree, error) {
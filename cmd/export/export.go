@@ -6,13 +6,15 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/tychonis/cyanotype/cmd/flags"
 	"github.com/tychonis/cyanotype/core/catalog"
 )
 
 var Cmd = &cobra.Command{
 	Use:   "export <path> <root>",
 	Short: "Export catalog from bpo",
-	Run:   run,
+	RunE:  run,
 }
 
 func init() {
@@ -20,7 +22,7 @@ func init() {
 	Cmd.Flags().StringP("output", "o", "", "set output path")
 }
 
-func run(cmd *cobra.Command, args []string) {
+func run(cmd *cobra.Command, args []string) error {
 	bpoPath := args[0]
 
 	catalogPath := cmd.Flag("output").Value.String()
@@ -37,7 +39,7 @@ func run(cmd *cobra.Command, args []string) {
 	output, err := cat.Export()
 	if err != nil {
 		slog.Error("Failed to export catalog.", "error", err)
-		return
+		return flags.ErrReported
 	}
-	os.WriteFile(catalogPath, output, 0o644)
+	return os.WriteFile(catalogPath, output, 0o644)
 }
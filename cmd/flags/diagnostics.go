@@ -0,0 +1,92 @@
+package flags
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/tychonis/cyanotype/internal/cerror"
+)
+
+// ErrReported is returned by commands once their errors have been printed.
+var ErrReported = errors.New("errors reported")
+
+var diagnosticsFormat string
+
+func AddDiagnosticsFormat(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&diagnosticsFormat, "diagnostics-format", "text", "print diagnostics as text or json")
+}
+
+type Pos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+type Range struct {
+	Filename string `json:"filename"`
+	Start    Pos    `json:"start"`
+	End      Pos    `json:"end"`
+}
+
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail,omitempty"`
+	Range    *Range `json:"range,omitempty"`
+}
+
+func toPos(pos hcl.Pos) Pos {
+	return Pos{Line: pos.Line, Column: pos.Column, Byte: pos.Byte}
+}
+
+func toDiagnostic(diag *hcl.Diagnostic) *Diagnostic {
+	ret := &Diagnostic{
+		Severity: "error",
+		Summary:  diag.Summary,
+		Detail:   diag.Detail,
+	}
+	if diag.Severity == hcl.DiagWarning {
+		ret.Severity = "warning"
+	}
+	if diag.Subject != nil {
+		ret.Range = &Range{
+			Filename: diag.Subject.Filename,
+			Start:    toPos(diag.Subject.Start),
+			End:      toPos(diag.Subject.End),
+		}
+	}
+	return ret
+}
+
+func writeDiagnostics(w io.Writer, diags hcl.Diagnostics, files map[string]*hcl.File) error {
+	switch diagnosticsFormat {
+	case "json":
+		list := make([]*Diagnostic, 0, len(diags))
+		for _, diag := range diags {
+			list = append(list, toDiagnostic(diag))
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+	case "text", "":
+		return hcl.NewDiagnosticTextWriter(w, files, 0, false).WriteDiagnostics(diags)
+	default:
+		return fmt.Errorf("unknown diagnostics format %q", diagnosticsFormat)
+	}
+}
+
+// ReportDiagnostics prints err to stderr as diagnostics, with snippets taken
+// from files, and returns ErrReported.
+func ReportDiagnostics(err error, files map[string]*hcl.File) error {
+	werr := writeDiagnostics(os.Stderr, cerror.Diagnostics(err), files)
+	if werr != nil {
+		return werr
+	}
+	return ErrReported
+}
@@ -0,0 +1,32 @@
+package flags
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+)
+
+// Variables collects --var and --var-file values for the root module.
+type Variables struct {
+	vars     []string
+	varFiles []string
+}
+
+func AddVariables(cmd *cobra.Command) *Variables {
+	v := &Variables{}
+	cmd.Flags().StringArrayVar(&v.vars, "var", nil, "set a variable, as name=value")
+	cmd.Flags().StringArrayVar(&v.varFiles, "var-file", nil, "load variable values from file")
+	return v
+}
+
+func (v *Variables) Apply(p *hcl.Parser) error {
+	for _, arg := range v.vars {
+		name, value, err := hcl.ParseVarArg(arg)
+		if err != nil {
+			return err
+		}
+		p.Options.Vars[name] = value
+	}
+	p.Options.VarFiles = append(p.Options.VarFiles, v.varFiles...)
+	return nil
+}
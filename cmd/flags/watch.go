@@ -0,0 +1,98 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+	"github.com/tychonis/cyanotype/internal/watch"
+)
+
+// Watch is the --watch flag of commands building a workspace.
+type Watch struct {
+	enabled bool
+	// Cache is shared by the builds of a watch, nil otherwise.
+	Cache *hcl.BuildCache
+}
+
+func AddWatch(cmd *cobra.Command) *Watch {
+	w := &Watch{}
+	cmd.Flags().BoolVarP(&w.enabled, "watch", "w", false, "run again whenever sources change")
+	return w
+}
+
+func watched(name string) bool {
+	return hcl.IsSource(name) || name == hcl.MANIFEST
+}
+
+// Run calls run once, or with --watch, again after every change to the
+// sources of the folders the last build read, until interrupted. run
+// returns the parser of its build, which may have failed. Errors are
+// printed and the watch goes on.
+func (w *Watch) Run(path string, run func() (*hcl.Parser, error)) error {
+	if !w.enabled {
+		_, err := run()
+		return err
+	}
+	w.Cache = hcl.NewBuildCache()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	poller := watch.NewPoller(watched)
+	folders := workspaceFolders(path, nil)
+	_, err := poller.Scan(folders)
+	if err != nil {
+		return err
+	}
+	for {
+		p, err := run()
+		if err == nil {
+			slog.Info("Build succeeded.")
+		} else if !errors.Is(err, ErrReported) {
+			slog.Error("Build failed.", "error", err)
+		}
+		// A broken save may hide imports, keep watching them.
+		next := workspaceFolders(path, p)
+		if err == nil {
+			folders = next
+		} else {
+			for _, dir := range next {
+				if !slices.Contains(folders, dir) {
+					folders = append(folders, dir)
+				}
+			}
+		}
+		slog.Info("Watching for changes.", "folders", len(folders))
+		changed, err := poller.Wait(ctx, folders)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		slog.Info("Sources changed, rebuilding.", "files", changed)
+	}
+}
+
+// workspaceFolders adds the folder of path to the folders read by p.
+func workspaceFolders(path string, p *hcl.Parser) []string {
+	dir := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+	ret := make([]string, 0)
+	if p != nil {
+		ret = p.Folders()
+	}
+	if abs, err := filepath.Abs(dir); err == nil && !slices.Contains(ret, abs) {
+		ret = append(ret, abs)
+	}
+	return ret
+}
@@ -1,14 +1,116 @@
 package format
 
 import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/spf13/cobra"
+
+	"github.com/tychonis/cyanotype/cmd/flags"
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+	"github.com/tychonis/cyanotype/internal/textdiff"
 )
 
 var Cmd = &cobra.Command{
-	Use:   "format",
+	Use:   "format [path]",
 	Short: "Format bpo file.",
-	Run:   run,
+	RunE:  run,
+}
+
+var check bool
+var showDiff bool
+
+func init() {
+	Cmd.Flags().BoolVar(&check, "check", false, "exit with non-zero status if any file is not formatted")
+	Cmd.Flags().BoolVar(&showDiff, "diff", false, "print the diff instead of rewriting files")
 }
 
-func run(cmd *cobra.Command, args []string) {
+func listFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	files := make([]string, 0)
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != path && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(p) == hcl.EXTENSION {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func formatFile(path string) (bool, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	formatted, err := hcl.Format(src, path)
+	if err != nil {
+		return false, err
+	}
+	if string(src) == string(formatted) {
+		return false, nil
+	}
+	if showDiff {
+		fmt.Print(textdiff.Unified("a/"+path, "b/"+path, string(src), string(formatted)))
+	}
+	if check || showDiff {
+		return true, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return true, err
+	}
+	return true, os.WriteFile(path, formatted, info.Mode().Perm())
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	files, err := listFiles(path)
+	if err != nil {
+		slog.Error("Failed to list bpo files.", "error", err)
+		return flags.ErrReported
+	}
+
+	unformatted := 0
+	failed := 0
+	for _, file := range files {
+		changed, err := formatFile(file)
+		if err != nil {
+			slog.Error("Failed to format file.", "file", file, "error", err)
+			failed++
+			continue
+		}
+		if changed {
+			unformatted++
+			if check && !showDiff {
+				fmt.Println(file)
+			}
+		}
+	}
+	if failed > 0 || (check && unformatted > 0) {
+		return flags.ErrReported
+	}
+	return nil
 }
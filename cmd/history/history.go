@@ -5,6 +5,8 @@ import (
 	"log/slog"
 
 	"github.com/spf13/cobra"
+
+	"github.com/tychonis/cyanotype/cmd/flags"
 	"github.com/tychonis/cyanotype/core/catalog"
 	"github.com/tychonis/cyanotype/internal/serializer"
 )
@@ -12,7 +14,8 @@ import (
 var Cmd = &cobra.Command{
 	Use:   "history",
 	Short: "History shows the history of a given symbol",
-	Run:   run,
+	RunE:  run,
+	Args:  cobra.MinimumNArgs(2),
 }
 
 func report(data any) error {
@@ -25,7 +28,7 @@ func report(data any) error {
 	return nil
 }
 
-func run(cmd *cobra.Command, args []string) {
+func run(cmd *cobra.Command, args []string) error {
 	bpoPath := args[0]
 	qualifier := args[1]
 	if bpoPath == "" {
@@ -36,7 +39,7 @@ func run(cmd *cobra.Command, args []string) {
 	syms, err := cat.FindAll(qualifier)
 	if err != nil {
 		slog.Error("Failed to find item.", "error", err)
-		return
+		return flags.ErrReported
 	}
 	for _, sym := range syms {
 		fmt.Print(sym.GetDigest() + ":")
@@ -45,8 +48,9 @@ func run(cmd *cobra.Command, args []string) {
 		meta, err := cat.GetMetadata(sym.GetDigest())
 		if err != nil {
 			slog.Error("Failed to get metadata.", "error", err)
-			return
+			return flags.ErrReported
 		}
 		report(meta)
 	}
+	return nil
 }
@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tychonis/cyanotype/cmd/flags"
+	"github.com/tychonis/cyanotype/core/bomimport"
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Generate bpo from a csv or KiCad xml bom.",
+	RunE:  run,
+	Args:  cobra.ExactArgs(1),
+}
+
+var output string
+var inputFormat string
+var root string
+var columns []string
+
+func init() {
+	Cmd.Flags().StringVarP(&output, "output", "o", "", "write to file instead of stdout")
+	Cmd.Flags().StringVar(&inputFormat, "format", "", "input format, csv or kicad, guessed from the extension by default")
+	Cmd.Flags().StringVar(&root, "root", "", "name of the top assembly, for single level boms")
+	Cmd.Flags().StringArrayVar(&columns, "column", nil, "map an attribute to a column, as attribute=column")
+}
+
+func detectFormat(path string) string {
+	if inputFormat != "" {
+		return inputFormat
+	}
+	if strings.EqualFold(filepath.Ext(path), ".xml") {
+		return "kicad"
+	}
+	return "csv"
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	mapping := bomimport.DefaultMapping()
+	for _, column := range columns {
+		key, value, ok := strings.Cut(column, "=")
+		if !ok {
+			slog.Error("Invalid column mapping, expected attribute=column.", "column", column)
+			return flags.ErrReported
+		}
+		err := mapping.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+		if err != nil {
+			slog.Error("Invalid column mapping.", "error", err)
+			return flags.ErrReported
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		slog.Error("Failed to open bom.", "error", err)
+		return flags.ErrReported
+	}
+	defer f.Close()
+
+	var bom *bomimport.BOM
+	switch detectFormat(path) {
+	case "csv":
+		name := root
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		bom, err = bomimport.ReadCSV(f, mapping, name)
+	case "kicad":
+		bom, err = bomimport.ReadKiCad(f, mapping, root)
+	default:
+		slog.Error("Format not supported.", "format", inputFormat)
+		return flags.ErrReported
+	}
+	if err != nil {
+		slog.Error("Failed to read bom.", "file", path, "error", err)
+		return flags.ErrReported
+	}
+
+	filename := output
+	if filename == "" {
+		filename = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + hcl.EXTENSION
+	}
+	content, err := bom.Write(filename)
+	if err != nil {
+		slog.Error("Failed to generate bpo.", "error", err)
+		return flags.ErrReported
+	}
+	if output == "" {
+		fmt.Print(string(content))
+		return nil
+	}
+	return os.WriteFile(output, content, 0o644)
+}
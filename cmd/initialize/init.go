@@ -7,20 +7,22 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/tychonis/cyanotype/cmd/flags"
 	"github.com/tychonis/cyanotype/core/catalog"
 )
 
 var Cmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize current folder",
-	Run:   run,
+	RunE:  run,
 }
 
-func run(cmd *cobra.Command, args []string) {
+func run(cmd *cobra.Command, args []string) error {
 	err := catalog.Initialize()
 	if err != nil {
 		fmt.Println("Failed to initialize:", err)
-		return
+		return flags.ErrReported
 	}
 	fmt.Println("Initialized empty cyanotype repo in .bpc/")
+	return nil
 }
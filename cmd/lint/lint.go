@@ -0,0 +1,109 @@
+package lint
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	hcl2 "github.com/hashicorp/hcl/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/tychonis/cyanotype/cmd/flags"
+	"github.com/tychonis/cyanotype/core/catalog"
+	"github.com/tychonis/cyanotype/core/lint"
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "lint <path>",
+	Short: "Check bpo for BOM hygiene, fail on findings of error severity",
+	RunE:  run,
+	Args:  cobra.MaximumNArgs(1),
+}
+
+var config string
+var format string
+var output string
+var withCatalog bool
+var variables *flags.Variables
+
+func init() {
+	Cmd.Flags().StringVar(&config, "config", "", "set lint config, defaults to "+lint.CONFIG+" next to the bpo")
+	Cmd.Flags().StringVar(&format, "format", "text", "print findings as text or sarif")
+	Cmd.Flags().StringVarP(&output, "output", "o", "", "set output path, defaults to stdout")
+	Cmd.Flags().BoolVar(&withCatalog, "catalog", false, "also check against the local catalog")
+	variables = flags.AddVariables(Cmd)
+}
+
+func write(w io.Writer, findings []*lint.Finding, files map[string]*hcl2.File) error {
+	if format == "sarif" {
+		return lint.WriteSARIF(w, findings)
+	}
+	return hcl2.NewDiagnosticTextWriter(w, files, 0, false).WriteDiagnostics(lint.Diagnostics(findings))
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	bpoPath := "."
+	if len(args) > 0 {
+		bpoPath = args[0]
+	}
+	if format != "text" && format != "sarif" {
+		slog.Error("Unknown format, expected text or sarif.", "format", format)
+		return flags.ErrReported
+	}
+
+	configPath := config
+	if configPath == "" {
+		root := bpoPath
+		if info, err := os.Stat(bpoPath); err == nil && !info.IsDir() {
+			root = filepath.Dir(bpoPath)
+		}
+		configPath = filepath.Join(root, lint.CONFIG)
+	}
+	cfg, err := lint.LoadConfig(configPath)
+	if err != nil {
+		return flags.ReportDiagnostics(err, nil)
+	}
+
+	p := hcl.NewParser()
+	p.Options.NoLock = true
+	err = variables.Apply(p)
+	if err != nil {
+		slog.Error("Invalid variables.", "error", err)
+		return flags.ErrReported
+	}
+	err = p.Build(bpoPath)
+	if err != nil {
+		return flags.ReportDiagnostics(err, p.Files())
+	}
+
+	var cat *catalog.Catalog
+	if withCatalog {
+		cat = catalog.New("local")
+	}
+	findings, err := lint.Run(p, cat, cfg)
+	if err != nil {
+		slog.Error("Failed to lint.", "error", err)
+		return flags.ErrReported
+	}
+
+	w := os.Stdout
+	if output != "" {
+		w, err = os.Create(output)
+		if err != nil {
+			slog.Error("Failed to create output.", "error", err)
+			return flags.ErrReported
+		}
+		defer w.Close()
+	}
+	err = write(w, findings, p.Files())
+	if err != nil {
+		slog.Error("Failed to write findings.", "error", err)
+		return flags.ErrReported
+	}
+	if lint.HasErrors(findings) {
+		return flags.ErrReported
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package lsp
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tychonis/cyanotype/internal/lsp"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run a language server for bpo files over stdio",
+	RunE:  run,
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	// Logs go to stderr, stdout carries the protocol.
+	return lsp.NewServer(os.Stdin, os.Stdout).Serve()
+}
@@ -0,0 +1,72 @@
+package materialize
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tychonis/cyanotype/cmd/flags"
+	"github.com/tychonis/cyanotype/core/catalog"
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+	"github.com/tychonis/cyanotype/model"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "materialize <dir>",
+	Short: "Write the bpo of a catalog revision into a folder.",
+	RunE:  run,
+	Args:  cobra.ExactArgs(1),
+}
+
+var rev string
+
+func init() {
+	Cmd.Flags().StringVar(&rev, "rev", "", "revision id or unique prefix, the latest by default")
+}
+
+func findRevision(cat *catalog.Catalog) (*model.Revision, error) {
+	if rev != "" {
+		return cat.FindRevision(rev)
+	}
+	latest, err := cat.GetLatestRevision()
+	if err == nil && latest == nil {
+		err = catalog.ErrNotFound
+	}
+	return latest, err
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	dir := args[0]
+	cat := catalog.New("local")
+	revision, err := findRevision(cat)
+	if err != nil {
+		slog.Error("Failed to find revision.", "revision", rev, "error", err)
+		return flags.ErrReported
+	}
+
+	content, err := hcl.Materialize(cat, revision)
+	if err != nil {
+		slog.Error("Failed to materialize revision.", "revision", revision.Digest, "error", err)
+		return flags.ErrReported
+	}
+
+	filename := filepath.Join(dir, "main"+hcl.EXTENSION)
+	if _, err := os.Stat(filename); err == nil {
+		slog.Error("File already exists.", "file", filename)
+		return flags.ErrReported
+	}
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		slog.Error("Failed to create folder.", "error", err)
+		return flags.ErrReported
+	}
+	err = os.WriteFile(filename, content, 0o644)
+	if err != nil {
+		slog.Error("Failed to write bpo.", "error", err)
+		return flags.ErrReported
+	}
+	slog.Info("Materialized revision.", "revision", revision.Digest, "file", filename)
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"log/slog"
 
 	"github.com/spf13/cobra"
+	"github.com/tychonis/cyanotype/cmd/flags"
 	"github.com/tychonis/cyanotype/core/catalog"
 	"github.com/tychonis/cyanotype/core/parser/hcl"
 )
@@ -11,32 +12,43 @@ import (
 var Cmd = &cobra.Command{
 	Use:   "plan",
 	Short: "Plan shows the diff between working-tree and local catalog",
-	Run:   run,
+	RunE:  run,
 }
 var ignoreArtifacts bool
+var variables *flags.Variables
+var watching *flags.Watch
 
 func init() {
 	Cmd.Flags().BoolVar(&ignoreArtifacts, "ignore-artifacts", false, "ignore artifacts during commit")
+	variables = flags.AddVariables(Cmd)
+	watching = flags.AddWatch(Cmd)
 }
 
-func run(cmd *cobra.Command, args []string) {
+func run(cmd *cobra.Command, args []string) error {
 	bpoPath := args[0]
 	if bpoPath == "" {
 		bpoPath = "."
 	}
 
-	p := hcl.NewParser()
-	p.Options.IgnoreArtifacts = ignoreArtifacts
-	err := p.Build(bpoPath)
-	if err != nil {
-		slog.Warn("Failed to parse bpo.", "error", err)
-		return
-	}
+	return watching.Run(bpoPath, func() (*hcl.Parser, error) {
+		p := hcl.NewParser()
+		p.Options.IgnoreArtifacts = ignoreArtifacts
+		p.Options.Cache = watching.Cache
+		err := variables.Apply(p)
+		if err != nil {
+			slog.Error("Invalid variables.", "error", err)
+			return nil, flags.ErrReported
+		}
+		err = p.Build(bpoPath)
+		if err != nil {
+			return p, flags.ReportDiagnostics(err, p.Files())
+		}
 
-	cat := catalog.New("local")
-	err = p.PreviewCommit(cat)
-	if err != nil {
-		slog.Error("Failed to commit to catalog.", "error", err)
-		return
-	}
+		cat := catalog.New("local")
+		err = p.PreviewCommit(cat)
+		if err != nil {
+			return p, flags.ReportDiagnostics(err, p.Files())
+		}
+		return p, nil
+	})
 }
@@ -5,16 +5,18 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+
+	"github.com/tychonis/cyanotype/cmd/flags"
 	"github.com/tychonis/cyanotype/core/catalog"
 )
 
 var Cmd = &cobra.Command{
 	Use:   "pull <server> <tag>",
 	Short: "Adhoc implementation pulling catalog from remote",
-	Run:   run,
+	RunE:  run,
 }
 
-func run(cmd *cobra.Command, args []string) {
+func run(cmd *cobra.Command, args []string) error {
 	server := args[0]
 	tag := args[1]
 	token := os.Getenv("BOMHUB_TOKEN")
@@ -24,5 +26,7 @@ func run(cmd *cobra.Command, args []string) {
 	err := localCat.Pull(remoteCat)
 	if err != nil {
 		slog.Error("Failed to pull catalog from remote.", "error", err)
+		return flags.ErrReported
 	}
+	return nil
 }
@@ -5,16 +5,18 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+
+	"github.com/tychonis/cyanotype/cmd/flags"
 	"github.com/tychonis/cyanotype/core/catalog"
 )
 
 var Cmd = &cobra.Command{
 	Use:   "push <server> <tag>",
 	Short: "Adhoc implementation saving catalog to remote",
-	Run:   run,
+	RunE:  run,
 }
 
-func run(cmd *cobra.Command, args []string) {
+func run(cmd *cobra.Command, args []string) error {
 	server := args[0]
 	tag := args[1]
 	token := os.Getenv("BOMHUB_TOKEN")
@@ -25,5 +27,9 @@ func run(cmd *cobra.Command, args []string) {
 	if err != nil {
 		slog.Error("Failed to push catalog to remote.", "error", err)
 	}
-	remoteCat.Upload(server, token, tag)
+	uploadErr := remoteCat.Upload(server, token, tag)
+	if err != nil {
+		return flags.ErrReported
+	}
+	return uploadErr
 }
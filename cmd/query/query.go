@@ -3,11 +3,13 @@ package query
 import (
 	"fmt"
 	"log/slog"
-	"os"
 
 	"github.com/spf13/cobra"
+
+	"github.com/tychonis/cyanotype/cmd/flags"
 	"github.com/tychonis/cyanotype/core/catalog"
 	"github.com/tychonis/cyanotype/internal/serializer"
+	"github.com/tychonis/cyanotype/model"
 )
 
 var Cmd = &cobra.Command{
@@ -40,7 +42,7 @@ func run(cmd *cobra.Command, args []string) error {
 	sym, err := cat.FindCurrent(qualifier)
 	if err != nil {
 		slog.Error("Failed to find item.", "error", err)
-		os.Exit(1)
+		return flags.ErrReported
 	}
 	fmt.Print(sym.GetDigest() + ":")
 	report(sym)
@@ -48,8 +50,49 @@ func run(cmd *cobra.Command, args []string) error {
 	meta, err := cat.GetMetadata(sym.GetDigest())
 	if err != nil {
 		slog.Error("Failed to get metadata.", "error", err)
-		os.Exit(1)
+		return flags.ErrReported
 	}
 	report(meta)
+
+	if contract, ok := sym.(*model.Contract); ok {
+		relations, err := contractRelations(cat, contract)
+		if err != nil {
+			slog.Error("Failed to get contract relations.", "error", err)
+			return flags.ErrReported
+		}
+		report(relations)
+	}
 	return nil
 }
+
+type SymbolRef struct {
+	Qualifier string       `json:"qualifier"`
+	Digest    model.Digest `json:"digest"`
+}
+
+type ContractRelations struct {
+	ImplementedBy []SymbolRef `json:"implemented_by"`
+	RequiredBy    []SymbolRef `json:"required_by"`
+}
+
+func contractRelations(cat *catalog.Catalog, contract *model.Contract) (*ContractRelations, error) {
+	ret := &ContractRelations{
+		ImplementedBy: make([]SymbolRef, 0),
+		RequiredBy:    make([]SymbolRef, 0),
+	}
+	items, err := cat.GetContractItems(contract.Digest)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		ret.ImplementedBy = append(ret.ImplementedBy, SymbolRef{item.Qualifier, item.Digest})
+	}
+	coItems, err := cat.GetContractCoItems(contract.Digest)
+	if err != nil {
+		return nil, err
+	}
+	for _, coItem := range coItems {
+		ret.RequiredBy = append(ret.RequiredBy, SymbolRef{coItem.Qualifier, coItem.Digest})
+	}
+	return ret, nil
+}
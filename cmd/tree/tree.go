@@ -3,10 +3,12 @@ package tree
 import (
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/tychonis/cyanotype/cmd/flags"
 	"github.com/tychonis/cyanotype/core/catalog"
 	"github.com/tychonis/cyanotype/core/instantiator"
 	"github.com/tychonis/cyanotype/core/parser/hcl"
@@ -15,16 +17,21 @@ import (
 var Cmd = &cobra.Command{
 	Use:   "tree <path> <root>",
 	Short: "Build bom tree from bpo",
-	Run:   run,
+	RunE:  run,
 	Args:  cobra.MinimumNArgs(2),
 }
 
+var variables *flags.Variables
+var watching *flags.Watch
+
 func init() {
 	// TODO: distinguish from output format
 	Cmd.Flags().StringP("output", "o", "", "set output path")
+	variables = flags.AddVariables(Cmd)
+	watching = flags.AddWatch(Cmd)
 }
 
-func run(cmd *cobra.Command, args []string) {
+func run(cmd *cobra.Command, args []string) error {
 	bpoPath := args[0]
 	root := args[1]
 
@@ -36,31 +43,65 @@ func run(cmd *cobra.Command, args []string) {
 			bpcPath = root + ".bpc"
 		}
 	}
-	p := hcl.NewParser()
-	err := p.Build(bpoPath)
-	if err != nil {
-		slog.Warn("Failed to parse bpo.", "error", err)
-		return
-	}
+	return watching.Run(bpoPath, func() (*hcl.Parser, error) {
+		p := hcl.NewParser()
+		p.Options.Cache = watching.Cache
+		err := variables.Apply(p)
+		if err != nil {
+			slog.Error("Invalid variables.", "error", err)
+			return nil, flags.ErrReported
+		}
+		err = p.Build(bpoPath)
+		if err != nil {
+			return p, flags.ReportDiagnostics(err, p.Files())
+		}
 
-	cat := catalog.New("local")
-	err = p.Commit(cat)
-	if err != nil {
-		slog.Error("Failed to commit to catalog.", "error", err)
-		return
-	}
+		cat := catalog.New("local")
+		err = p.Commit(cat)
+		if err != nil {
+			return p, flags.ReportDiagnostics(err, p.Files())
+		}
+
+		ins := instantiator.New()
+		rootNode, err := ins.TreeFromQualifier(cat, root)
+		if err != nil {
+			slog.Error("Failed to build.", "error", err)
+			return p, flags.ErrReported
+		}
+
+		output, err := rootNode.Export()
+		if err != nil {
+			slog.Error("Failed to export.", "error", err)
+			return p, flags.ErrReported
+		}
+		err = writeFile(bpcPath, output)
+		if err != nil {
+			slog.Error("Failed to write tree.", "error", err)
+			return p, flags.ErrReported
+		}
+		return p, nil
+	})
+}
 
-	ins := instantiator.New()
-	rootNode, err := ins.TreeFromQualifier(cat, root)
+// writeFile replaces path with data through a rename, so that readers never
+// see a partial tree.
+func writeFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
 	if err != nil {
-		slog.Error("Failed to build.", "error", err)
-		return
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
 	}
-
-	output, err := rootNode.Export()
 	if err != nil {
-		slog.Error("Failed to export.", "error", err)
-		return
+		os.Remove(f.Name())
 	}
-	os.WriteFile(bpcPath, output, 0o644)
+	return err
 }
@@ -8,9 +8,10 @@ import (
 var Cmd = &cobra.Command{
 	Use:   "version",
 	Short: "Show the version",
-	Run:   run,
+	RunE:  run,
 }
 
-func run(cmd *cobra.Command, args []string) {
+func run(cmd *cobra.Command, args []string) error {
 	cmd.Println("cyanotype version:", version.Version)
+	return nil
 }
@@ -0,0 +1,186 @@
+package artifact
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// INDEX maps the sources fetched so far to the digests of their content.
+const INDEX = "index"
+
+// Cache stores remote artifacts by digest, so each source is downloaded once
+// and later builds work offline. Without a directory, artifacts are hashed
+// on every build and nothing is kept.
+type Cache struct {
+	Dir       string
+	Resolvers map[string]Resolver
+
+	mu      sync.Mutex
+	sources map[string]string
+}
+
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: dir, Resolvers: DefaultResolvers()}
+}
+
+// Path returns where the content of digest is kept.
+func (c *Cache) Path(digest string) (string, error) {
+	if len(digest) < 2 || c.Dir == "" {
+		return "", errors.New("incorrect digest")
+	}
+	return filepath.Join(c.Dir, digest[:2], digest), nil
+}
+
+func (c *Cache) loadIndex() error {
+	if c.sources != nil {
+		return nil
+	}
+	c.sources = make(map[string]string)
+	if c.Dir == "" {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(c.Dir, INDEX))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		digest, source, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		c.sources[source] = digest
+	}
+	return scanner.Err()
+}
+
+// cached returns the digest of a source fetched before, as long as its
+// content is still in the cache.
+func (c *Cache) cached(source string) (string, bool) {
+	digest, ok := c.sources[source]
+	if !ok {
+		return "", false
+	}
+	path, err := c.Path(digest)
+	if err != nil {
+		return "", false
+	}
+	_, err = os.Stat(path)
+	return digest, err == nil
+}
+
+// Open returns the content of source, from the cache if it was fetched
+// before.
+func (c *Cache) Open(source string) (io.ReadCloser, error) {
+	c.mu.Lock()
+	err := c.loadIndex()
+	digest, ok := c.cached(source)
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		path, err := c.Path(digest)
+		if err != nil {
+			return nil, err
+		}
+		return os.Open(path)
+	}
+	resolver, ok := c.Resolvers[Scheme(source)]
+	if !ok {
+		return nil, fmt.Errorf("scheme not supported: %s", Scheme(source))
+	}
+	return resolver.Open(source)
+}
+
+// Digest returns the SHA-256 of the content of source, fetching it unless
+// it is cached.
+func (c *Cache) Digest(source string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.loadIndex()
+	if err != nil {
+		return "", err
+	}
+	if digest, ok := c.cached(source); ok {
+		return digest, nil
+	}
+	scheme := Scheme(source)
+	resolver, ok := c.Resolvers[scheme]
+	if !ok {
+		return "", fmt.Errorf("scheme not supported: %s", scheme)
+	}
+	r, err := resolver.Open(source)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	digest, err := c.store(r)
+	if err != nil {
+		return "", fmt.Errorf("fetch %s: %w", source, err)
+	}
+	c.sources[source] = digest
+	if c.Dir == "" {
+		return digest, nil
+	}
+	return digest, c.appendIndex(digest, source)
+}
+
+// store hashes r while copying it into the cache.
+func (c *Cache) store(r io.Reader) (string, error) {
+	hasher := sha256.New()
+	if c.Dir == "" {
+		_, err := io.Copy(hasher, r)
+		return hex.EncodeToString(hasher.Sum(nil)), err
+	}
+	err := os.MkdirAll(c.Dir, 0o755)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(c.Dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(io.MultiWriter(tmp, hasher), r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	digest := hex.EncodeToString(hasher.Sum(nil))
+	path, err := c.Path(digest)
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return "", err
+	}
+	return digest, os.Rename(tmp.Name(), path)
+}
+
+func (c *Cache) appendIndex(digest string, source string) error {
+	f, err := os.OpenFile(filepath.Join(c.Dir, INDEX), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "%s %s\n", digest, source)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package artifact_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/tychonis/cyanotype/core/artifact"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCacheHTTP(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.URL.Path != "/drawing.pdf" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("drawing"))
+	}))
+	dir := t.TempDir()
+	source := srv.URL + "/drawing.pdf"
+
+	c := artifact.NewCache(dir)
+	got, err := c.Digest(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != sha256Hex("drawing") {
+		t.Errorf("unexpected digest %s", got)
+	}
+	path, err := c.Path(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "drawing" {
+		t.Errorf("expected cached content, got %q: %v", data, err)
+	}
+
+	_, err = c.Digest(srv.URL + "/missing.pdf")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	// A new build reads the index and never goes online.
+	srv.Close()
+	again, err := artifact.NewCache(dir).Digest(source)
+	if err != nil || again != got {
+		t.Errorf("expected cached digest %s, got %s: %v", got, again, err)
+	}
+	if hits != 2 {
+		t.Errorf("expected 2 requests, got %d", hits)
+	}
+}
+
+func TestCacheGit(t *testing.T) {
+	lib := t.TempDir()
+	repo, err := git.PlainInit(lib, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(filepath.Join(lib, "docs"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(lib, "docs", "spec.txt"), []byte("spec"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.Add("docs/spec.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := wt.Commit("spec", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := artifact.NewCache(t.TempDir())
+	source := "git://" + filepath.ToSlash(lib) + "@" + hash.String() + ":docs/spec.txt"
+	got, err := c.Digest(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != sha256Hex("spec") {
+		t.Errorf("unexpected digest %s", got)
+	}
+	_, err = c.Digest("git://" + filepath.ToSlash(lib) + "@master:docs/spec.txt")
+	if err == nil {
+		t.Error("expected branches to be rejected")
+	}
+}
+
+func TestCacheS3(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/") || r.Header.Get("X-Amz-Date") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.EscapedPath() != "/parts/cad/deck%20v2.step" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("step"))
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	c := artifact.NewCache("")
+	got, err := c.Digest("s3://parts/cad/deck v2.step")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != sha256Hex("step") {
+		t.Errorf("unexpected digest %s", got)
+	}
+}
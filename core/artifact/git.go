@@ -0,0 +1,103 @@
+package artifact
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// GitSource is an artifact of the form git://<repo>@<commit>:<path>. The
+// commit must be a full hash, so the content never changes.
+type GitSource struct {
+	Repo   string
+	Commit string
+	Path   string
+}
+
+func ParseGitSource(source string) (*GitSource, error) {
+	rest, ok := strings.CutPrefix(source, "git://")
+	if !ok {
+		return nil, fmt.Errorf("not a git source: %s", source)
+	}
+	at := strings.LastIndex(rest, "@")
+	if at < 0 {
+		return nil, fmt.Errorf("git source %s has no commit", source)
+	}
+	commit, path, ok := strings.Cut(rest[at+1:], ":")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("git source %s has no path", source)
+	}
+	if !plumbing.IsHash(commit) {
+		return nil, fmt.Errorf("git source %s must pin a full commit hash", source)
+	}
+	return &GitSource{Repo: rest[:at], Commit: commit, Path: strings.Trim(path, "/")}, nil
+}
+
+// RepoURL returns where to open the repository. Paths are local
+// repositories, and bare hosts default to https.
+func (s *GitSource) RepoURL() string {
+	switch {
+	case strings.HasPrefix(s.Repo, "/"), strings.HasPrefix(s.Repo, "file://"):
+		return s.Repo
+	case strings.Contains(s.Repo, "://"):
+		return s.Repo
+	default:
+		return "https://" + s.Repo
+	}
+}
+
+type GitResolver struct {
+	repos map[string]*git.Repository
+}
+
+func NewGitResolver() *GitResolver {
+	return &GitResolver{repos: make(map[string]*git.Repository)}
+}
+
+// open opens local repositories in place and clones remote ones into
+// memory, without a worktree.
+func (g *GitResolver) open(repoURL string) (*git.Repository, error) {
+	repo, ok := g.repos[repoURL]
+	if ok {
+		return repo, nil
+	}
+	var err error
+	if local, ok := strings.CutPrefix(repoURL, "file://"); ok || strings.HasPrefix(repoURL, "/") {
+		if !ok {
+			local = repoURL
+		}
+		repo, err = git.PlainOpen(filepath.FromSlash(local))
+	} else {
+		repo, err = git.Clone(memory.NewStorage(), nil, &git.CloneOptions{URL: repoURL})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open repository %s: %w", repoURL, err)
+	}
+	g.repos[repoURL] = repo
+	return repo, nil
+}
+
+func (g *GitResolver) Open(source string) (io.ReadCloser, error) {
+	src, err := ParseGitSource(source)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := g.open(src.RepoURL())
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(plumbing.NewHash(src.Commit))
+	if err != nil {
+		return nil, fmt.Errorf("commit %s of %s: %w", src.Commit, src.Repo, err)
+	}
+	file, err := commit.File(src.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%s at %s: %w", src.Path, src.Commit, err)
+	}
+	return file.Reader()
+}
@@ -0,0 +1,170 @@
+package artifact
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tychonis/cyanotype/internal/digest"
+	"github.com/tychonis/cyanotype/model"
+)
+
+// Manifest lists the files of a directory or glob artifact. It is encoded
+// deterministically, so its digest changes only with the files.
+type Manifest struct {
+	Files []*model.ArtifactFile `json:"files"`
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// IsManifest reports whether a local path names a directory or a glob, so
+// that the artifact is described by a manifest.
+func IsManifest(pattern string) bool {
+	if isGlob(pattern) {
+		return true
+	}
+	stat, err := os.Stat(pattern)
+	return err == nil && stat.IsDir()
+}
+
+// ManifestBase returns the directory manifest paths are relative to: the
+// directory itself, or the part of a glob before its first wildcard.
+func ManifestBase(pattern string) string {
+	if !isGlob(pattern) {
+		return filepath.Clean(pattern)
+	}
+	parts := strings.Split(filepath.ToSlash(pattern), "/")
+	i := slices.IndexFunc(parts, isGlob)
+	base := strings.Join(parts[:i], "/")
+	if base == "" && strings.HasPrefix(pattern, "/") {
+		return "/"
+	}
+	if base == "" {
+		return "."
+	}
+	return filepath.FromSlash(base)
+}
+
+// BuildManifest hashes every file of a directory, or matched by a glob.
+// Directories matched by a glob are included recursively.
+func BuildManifest(pattern string) (*Manifest, error) {
+	base := ManifestBase(pattern)
+	matches := []string{pattern}
+	if isGlob(pattern) {
+		var err error
+		matches, err = filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	seen := make(map[string]bool)
+	m := &Manifest{Files: make([]*model.ArtifactFile, 0)}
+	for _, match := range matches {
+		err := filepath.WalkDir(match, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(base, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if seen[rel] {
+				return nil
+			}
+			seen[rel] = true
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			sum, err := digest.SHA256FromFile(p)
+			if err != nil {
+				return err
+			}
+			m.Files = append(m.Files, &model.ArtifactFile{Path: rel, Size: info.Size(), Digest: sum})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(m.Files) == 0 {
+		return nil, fmt.Errorf("no files match %s", pattern)
+	}
+	slices.SortFunc(m.Files, func(a, b *model.ArtifactFile) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return m, nil
+}
+
+func (m *Manifest) Encode() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+func (m *Manifest) Digest() (string, error) {
+	data, err := m.Encode()
+	if err != nil {
+		return "", err
+	}
+	return digest.SHA256FromReader(bytes.NewReader(data))
+}
+
+// LocalPath returns where a file of the manifest is written under dir,
+// refusing paths that escape it.
+func LocalPath(dir string, file *model.ArtifactFile) (string, error) {
+	rel := filepath.FromSlash(file.Path)
+	if !filepath.IsLocal(rel) || path.Clean(file.Path) != file.Path {
+		return "", errors.New("invalid artifact file path " + file.Path)
+	}
+	return filepath.Join(dir, rel), nil
+}
+
+const (
+	FileAdded    = "added"
+	FileRemoved  = "removed"
+	FileModified = "modified"
+)
+
+type FileChange struct {
+	Path   string
+	Change string
+}
+
+// DiffFiles lists the files added, removed or modified from old to new, by
+// path.
+func DiffFiles(old []*model.ArtifactFile, new []*model.ArtifactFile) []FileChange {
+	before := make(map[string]string, len(old))
+	for _, f := range old {
+		before[f.Path] = f.Digest
+	}
+	ret := make([]FileChange, 0)
+	after := make(map[string]bool, len(new))
+	for _, f := range new {
+		after[f.Path] = true
+		sum, ok := before[f.Path]
+		switch {
+		case !ok:
+			ret = append(ret, FileChange{Path: f.Path, Change: FileAdded})
+		case sum != f.Digest:
+			ret = append(ret, FileChange{Path: f.Path, Change: FileModified})
+		}
+	}
+	for _, f := range old {
+		if !after[f.Path] {
+			ret = append(ret, FileChange{Path: f.Path, Change: FileRemoved})
+		}
+	}
+	slices.SortFunc(ret, func(a, b FileChange) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return ret
+}
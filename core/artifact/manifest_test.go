@@ -0,0 +1,81 @@
+package artifact_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tychonis/cyanotype/core/artifact"
+	"github.com/tychonis/cyanotype/model"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"board-F_Cu.gbr":  "front",
+		"board-B_Cu.gbr":  "back",
+		"drill/board.drl": "holes",
+	} {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	byDir, err := artifact.BuildManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byGlob, err := artifact.BuildManifest(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make([]string, 0)
+	for _, f := range byDir.Files {
+		paths = append(paths, f.Path)
+	}
+	if len(paths) != 3 || paths[0] != "board-B_Cu.gbr" || paths[2] != "drill/board.drl" || byDir.Files[0].Size != 4 {
+		t.Errorf("unexpected manifest %v", paths)
+	}
+	dirDigest, _ := byDir.Digest()
+	globDigest, _ := byGlob.Digest()
+	if dirDigest != globDigest {
+		t.Errorf("expected the same manifest for the folder and its glob")
+	}
+
+	gerbers, err := artifact.BuildManifest(filepath.Join(dir, "*.gbr"))
+	if err != nil || len(gerbers.Files) != 2 {
+		t.Fatalf("expected 2 gerbers, got %v: %v", gerbers, err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "board-F_Cu.gbr"), []byte("front v2"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Remove(filepath.Join(dir, "drill", "board.drl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := artifact.BuildManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := changed.Digest(); d == dirDigest {
+		t.Error("expected the digest to change with a file")
+	}
+	diff := artifact.DiffFiles(byDir.Files, changed.Files)
+	if len(diff) != 2 || diff[0] != (artifact.FileChange{Path: "board-F_Cu.gbr", Change: artifact.FileModified}) ||
+		diff[1] != (artifact.FileChange{Path: "drill/board.drl", Change: artifact.FileRemoved}) {
+		t.Errorf("unexpected changes %v", diff)
+	}
+
+	_, err = artifact.LocalPath("out", &model.ArtifactFile{Path: "../escape"})
+	if err == nil {
+		t.Error("expected paths outside the output folder to be rejected")
+	}
+}
@@ -0,0 +1,69 @@
+package artifact
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Resolver fetches the content of artifact sources of one scheme.
+type Resolver interface {
+	Open(source string) (io.ReadCloser, error)
+}
+
+// Scheme returns the scheme of a source such as `https://host/file` or
+// `git://repo@commit:path`.
+func Scheme(source string) string {
+	scheme, _, ok := strings.Cut(source, "://")
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(scheme)
+}
+
+// HTTP_TIMEOUT bounds a whole download, and HTTP_HEADER_TIMEOUT the wait for
+// a response, so that a stalled server fails a build instead of hanging it.
+const (
+	HTTP_TIMEOUT        = 10 * time.Minute
+	HTTP_HEADER_TIMEOUT = 30 * time.Second
+)
+
+// NewHTTPClient returns the client of the http(s) and s3 resolvers.
+func NewHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = HTTP_HEADER_TIMEOUT
+	return &http.Client{
+		Transport: transport,
+		Timeout:   HTTP_TIMEOUT,
+	}
+}
+
+// DefaultResolvers returns the resolvers for http(s), git and s3 sources.
+// S3 credentials and endpoint are read from the environment.
+func DefaultResolvers() map[string]Resolver {
+	h := &HTTPResolver{Client: NewHTTPClient()}
+	return map[string]Resolver{
+		"http":  h,
+		"https": h,
+		"git":   NewGitResolver(),
+		"s3":    S3ResolverFromEnv(),
+	}
+}
+
+type HTTPResolver struct {
+	Client *http.Client
+}
+
+func (h *HTTPResolver) Open(source string) (io.ReadCloser, error) {
+	resp, err := h.Client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error response fetching %s: %s", source, resp.Status)
+	}
+	return resp.Body, nil
+}
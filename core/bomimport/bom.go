@@ -0,0 +1,231 @@
+package bomimport
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+	"github.com/tychonis/cyanotype/internal/refdes"
+	"github.com/tychonis/cyanotype/internal/unit"
+)
+
+// Part is an item read from a legacy BOM. Parts are identified by their
+// part number, or by their name when they have none.
+type Part struct {
+	Symbol     string
+	Name       string
+	PartNumber string
+	Unit       string
+	Details    map[string]string
+	Lines      []*Line
+}
+
+// Line is a child of an assembly.
+type Line struct {
+	Part   *Part
+	Qty    float64
+	HasQty bool
+	Unit   string
+	Refdes []string
+}
+
+// BOM collects the parts of an import in the order they first appear.
+type BOM struct {
+	Parts []*Part
+
+	byKey   map[string]*Part
+	symbols map[string]bool
+}
+
+func NewBOM() *BOM {
+	return &BOM{
+		Parts:   make([]*Part, 0),
+		byKey:   make(map[string]*Part),
+		symbols: make(map[string]bool),
+	}
+}
+
+// Part returns the part with the given part number or name, adding it on
+// first use.
+func (b *BOM) Part(partNumber string, name string) (*Part, error) {
+	key := partNumber
+	if key == "" {
+		key = name
+	}
+	if key == "" {
+		return nil, fmt.Errorf("part has neither a part number nor a name")
+	}
+	if part, ok := b.byKey[key]; ok {
+		if part.Name == "" {
+			part.Name = name
+		}
+		return part, nil
+	}
+	if name == "" {
+		name = partNumber
+	}
+	part := &Part{
+		Symbol:     b.symbol(name),
+		Name:       name,
+		PartNumber: partNumber,
+		Details:    make(map[string]string),
+	}
+	b.byKey[key] = part
+	b.Parts = append(b.Parts, part)
+	return part, nil
+}
+
+// symbol turns a name into an unused identifier.
+func (b *BOM) symbol(name string) string {
+	base := Identifier(name)
+	ret := base
+	for i := 2; b.symbols[ret]; i++ {
+		ret = base + "_" + strconv.Itoa(i)
+	}
+	b.symbols[ret] = true
+	return ret
+}
+
+// Identifier turns a name into a valid HCL identifier, e.g. "10k 0402" into
+// "p_10k_0402".
+func Identifier(name string) string {
+	sb := &strings.Builder{}
+	underscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if 'a' <= r && r <= 'z' || '0' <= r && r <= '9' {
+			sb.WriteRune(r)
+			underscore = false
+		} else if !underscore && sb.Len() > 0 {
+			sb.WriteByte('_')
+			underscore = true
+		}
+	}
+	ret := strings.TrimSuffix(sb.String(), "_")
+	if ret == "" || ret[0] >= '0' && ret[0] <= '9' {
+		ret = "p_" + ret
+	}
+	return ret
+}
+
+// SetDetail records a detail, keeping the first value seen for a part.
+func (p *Part) SetDetail(key string, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	key = Identifier(key)
+	if _, reserved := hcl.RESERVED[key]; reserved || key == "description" {
+		key = "detail_" + key
+	}
+	if _, ok := p.Details[key]; !ok {
+		p.Details[key] = value
+	}
+}
+
+// AddLine adds a child to an assembly, merging it with an earlier line of
+// the same part.
+func (p *Part) AddLine(line *Line) error {
+	if u, err := unit.Lookup(line.Unit); err == nil && u.Dimension != unit.Count && line.Part.Unit == "" {
+		line.Part.Unit = line.Unit
+	}
+	for _, other := range p.Lines {
+		if other.Part != line.Part {
+			continue
+		}
+		qty, err := unit.Convert(line.Qty, line.Unit, other.Unit)
+		if err != nil {
+			return fmt.Errorf("%s in %s: %w", line.Part.Name, p.Name, err)
+		}
+		other.Qty += qty
+		other.HasQty = other.HasQty || line.HasQty
+		other.Refdes = append(other.Refdes, line.Refdes...)
+		return nil
+	}
+	p.Lines = append(p.Lines, line)
+	return nil
+}
+
+// Check verifies what the parser would reject: designators that do not
+// match quantities, or are used twice within an assembly.
+func (b *BOM) Check() error {
+	for _, part := range b.Parts {
+		all := make([]string, 0)
+		for _, line := range part.Lines {
+			if line.Refdes == nil {
+				continue
+			}
+			if line.HasQty && line.Qty != float64(len(line.Refdes)) {
+				return fmt.Errorf("qty %g of %s in %s does not match its %d designators",
+					line.Qty, line.Part.Name, part.Name, len(line.Refdes))
+			}
+			all = append(all, line.Refdes...)
+		}
+		if d, ok := refdes.Duplicate(all); ok {
+			return fmt.Errorf("designator %s is used twice in %s", d, part.Name)
+		}
+	}
+	return nil
+}
+
+func quote(s string) string {
+	return string(hclwrite.TokensForValue(cty.StringVal(s)).Bytes())
+}
+
+func formatQty(line *Line) string {
+	qty := strconv.FormatFloat(line.Qty, 'f', -1, 64)
+	if line.Unit == "" {
+		return qty
+	}
+	return quote(qty + " " + line.Unit)
+}
+
+// Write emits one formatted item block per part, in the order they were
+// read.
+func (b *BOM) Write(filename string) ([]byte, error) {
+	err := b.Check()
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	for i, part := range b.Parts {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(buf, "item %s {\n", quote(part.Symbol))
+		if part.PartNumber != "" {
+			fmt.Fprintf(buf, "part_number = %s\n", quote(part.PartNumber))
+		}
+		if part.Unit != "" {
+			fmt.Fprintf(buf, "unit = %s\n", quote(part.Unit))
+		}
+		if part.Name != part.Symbol {
+			fmt.Fprintf(buf, "description = %s\n", quote(part.Name))
+		}
+		for _, key := range slices.Sorted(maps.Keys(part.Details)) {
+			fmt.Fprintf(buf, "%s = %s\n", key, quote(part.Details[key]))
+		}
+		if len(part.Lines) > 0 {
+			buf.WriteString("from = [\n")
+			for _, line := range part.Lines {
+				fmt.Fprintf(buf, "{ name = %s, ref = %s", quote(line.Part.Name), line.Part.Symbol)
+				if line.Refdes == nil || line.Qty != float64(len(line.Refdes)) || line.Unit != "" {
+					fmt.Fprintf(buf, ", qty = %s", formatQty(line))
+				}
+				if line.Refdes != nil {
+					fmt.Fprintf(buf, ", refdes = %s", quote(refdes.Format(line.Refdes)))
+				}
+				buf.WriteString(" },\n")
+			}
+			buf.WriteString("]\n")
+		}
+		buf.WriteString("}\n")
+	}
+	return hcl.Format(buf.Bytes(), filename)
+}
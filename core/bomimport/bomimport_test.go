@@ -0,0 +1,133 @@
+package bomimport_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tychonis/cyanotype/core/bomimport"
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+	"github.com/tychonis/cyanotype/core/process"
+	"github.com/tychonis/cyanotype/model"
+)
+
+// build parses generated source as a file of its own.
+func build(t *testing.T, bom *bomimport.BOM) *hcl.Parser {
+	t.Helper()
+	src, err := bom.Write("main.bpo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	err = os.WriteFile(filepath.Join(dir, "main.bpo"), src, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := hcl.Format(src, "main.bpo")
+	if err != nil || string(formatted) != string(src) {
+		t.Errorf("output is not formatted: %v\n%s", err, src)
+	}
+	p := hcl.NewParser()
+	err = p.Build(dir)
+	if err != nil {
+		t.Fatalf("Build error: %v\n%s", err, src)
+	}
+	return p
+}
+
+func input(t *testing.T, p *hcl.Parser, q string) []*model.BOMLine {
+	t.Helper()
+	sym, err := p.Symbols.FindConcreteSymbol(q + ".__process__")
+	if err != nil {
+		t.Fatalf("process of %s not found: %v", q, err)
+	}
+	return sym.(*process.Process).Input()
+}
+
+func TestReadCSV(t *testing.T) {
+	src := `Level,Part Number,Description,Qty,RefDes,Vendor
+0,CART-1,Cart,,,
+1,PCB-7,Board,2,,Acme
+2,RC-10K,10k Resistor,,"R1, R2 R3",Yageo
+2,RC-10K,10k Resistor,1,R7,
+1,W-18,Wire,"250 mm",,
+1,PCB-7,Board,1,,
+2,RC-10K,10k Resistor,3,R1..R3,
+`
+	mapping := bomimport.DefaultMapping()
+	err := mapping.Set("name", "Description")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bom, err := bomimport.ReadCSV(strings.NewReader(src), mapping, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := build(t, bom)
+
+	cart := input(t, p, ".cart")
+	if len(cart) != 2 || cart[0].Qty != 3 || cart[1].Qty != 250 || cart[1].Unit != "mm" {
+		t.Errorf("unexpected cart lines: %+v %+v", cart[0], cart[1])
+	}
+	board := input(t, p, ".board")
+	if len(board) != 1 || board[0].Qty != 4 || !slices.Equal(board[0].Refdes, []string{"R1", "R2", "R3", "R7"}) {
+		t.Errorf("unexpected board lines: %+v", board[0])
+	}
+	sym, err := p.Symbols.FindConcreteSymbol(".p_10k_resistor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resistor := sym.(*model.Item)
+	if resistor.Content.PartNumber != "RC-10K" || resistor.Content.Details["vendor"] != "Yageo" {
+		t.Errorf("unexpected resistor: %+v", resistor.Content)
+	}
+
+	_, err = bomimport.ReadCSV(strings.NewReader("Name,Qty\nbolt,x\n"), bomimport.DefaultMapping(), "kit")
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error on line 2, got %v", err)
+	}
+}
+
+func TestReadKiCad(t *testing.T) {
+	src := `<?xml version="1.0" encoding="UTF-8"?>
+<export version="E">
+  <design><source>/proj/motor_driver.kicad_sch</source></design>
+  <components>
+    <comp ref="R2">
+      <value>10k</value>
+      <footprint>Resistor_SMD:R_0402</footprint>
+      <fields><field name="MPN">RC0402-10K</field></fields>
+    </comp>
+    <comp ref="R1">
+      <value>10k</value>
+      <footprint>Resistor_SMD:R_0402</footprint>
+      <fields><field name="MPN">RC0402-10K</field></fields>
+    </comp>
+    <comp ref="C1">
+      <value>100n</value>
+      <footprint>Capacitor_SMD:C_0402</footprint>
+    </comp>
+    <comp ref="TP1">
+      <value>TestPoint</value>
+      <property name="exclude_from_bom"/>
+    </comp>
+  </components>
+</export>
+`
+	mapping := bomimport.DefaultMapping()
+	err := mapping.Set("part_number", "MPN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bom, err := bomimport.ReadKiCad(strings.NewReader(src), mapping, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := build(t, bom)
+	lines := input(t, p, ".motor_driver")
+	if len(lines) != 2 || lines[0].Qty != 2 || !slices.Equal(lines[0].Refdes, []string{"R1", "R2"}) || lines[1].Qty != 1 {
+		t.Errorf("unexpected lines: %+v", lines)
+	}
+}
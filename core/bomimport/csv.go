@@ -0,0 +1,238 @@
+package bomimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tychonis/cyanotype/internal/refdes"
+	"github.com/tychonis/cyanotype/internal/unit"
+)
+
+// Mapping names the columns, or KiCad fields, holding each attribute of a
+// line. Names are matched ignoring case, spaces and dashes.
+type Mapping struct {
+	Level      string
+	PartNumber string
+	Name       string
+	Qty        string
+	Unit       string
+	Refdes     string
+}
+
+func DefaultMapping() *Mapping {
+	return &Mapping{
+		Level:      "level",
+		PartNumber: "part_number",
+		Name:       "name",
+		Qty:        "qty",
+		Unit:       "unit",
+		Refdes:     "refdes",
+	}
+}
+
+// Set maps an attribute to a column, as given on the command line.
+func (m *Mapping) Set(key string, column string) error {
+	switch key {
+	case "level":
+		m.Level = column
+	case "part_number":
+		m.PartNumber = column
+	case "name":
+		m.Name = column
+	case "qty":
+		m.Qty = column
+	case "unit":
+		m.Unit = column
+	case "refdes":
+		m.Refdes = column
+	default:
+		return fmt.Errorf("unknown attribute %q, expected one of level, part_number, name, qty, unit, refdes", key)
+	}
+	return nil
+}
+
+func normalize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+}
+
+func (m *Mapping) columns() []string {
+	return []string{m.Level, m.PartNumber, m.Name, m.Qty, m.Unit, m.Refdes}
+}
+
+// splitRefdes reads designators separated by commas or spaces, with ranges
+// such as R1..R4.
+func splitRefdes(cell string) ([]string, error) {
+	fields := strings.FieldsFunc(cell, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t'
+	})
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return refdes.Expand(strings.Join(fields, ","))
+}
+
+// parseLevel reads a level as a number, or as an outline such as 1.2.1
+// whose depth is its number of segments.
+func parseLevel(cell string) (int, error) {
+	cell = strings.TrimSpace(cell)
+	if strings.Contains(strings.TrimSuffix(cell, "."), ".") {
+		return len(strings.Split(strings.TrimSuffix(cell, "."), ".")), nil
+	}
+	level, err := strconv.Atoi(strings.TrimSuffix(cell, "."))
+	if err != nil || level < 0 {
+		return 0, fmt.Errorf("invalid level %q", cell)
+	}
+	return level, nil
+}
+
+type csvRow struct {
+	cells  map[string]string
+	header []string
+	record []string
+}
+
+func (r *csvRow) get(column string) string {
+	return strings.TrimSpace(r.cells[normalize(column)])
+}
+
+// ReadCSV reads a spreadsheet BOM. With a level column, each row is a child
+// of the closest row above it with a lower level, and rows at the lowest
+// level are the top assemblies. Without one, every row is a child of root.
+// Unmapped columns become details of the part.
+func ReadCSV(r io.Reader, mapping *Mapping, root string) (*BOM, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty csv")
+	}
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for _, column := range mapping.columns() {
+		known[normalize(column)] = true
+	}
+	hasLevel := false
+	for _, column := range header {
+		if normalize(column) == normalize(mapping.Level) {
+			hasLevel = true
+		}
+	}
+
+	bom := NewBOM()
+	// A frame without part skips the rows below an assembly whose children
+	// were listed before.
+	type frame struct {
+		level int
+		part  *Part
+	}
+	stack := make([]frame, 0)
+	if !hasLevel {
+		if root == "" {
+			return nil, errors.New("a root name is required without a level column")
+		}
+		top, err := bom.Part("", root)
+		if err != nil {
+			return nil, err
+		}
+		stack = append(stack, frame{level: -1, part: top})
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		row := &csvRow{cells: make(map[string]string), header: header, record: record}
+		empty := true
+		for i, cell := range record {
+			if i < len(header) {
+				row.cells[normalize(header[i])] = cell
+			}
+			empty = empty && strings.TrimSpace(cell) == ""
+		}
+		if empty {
+			continue
+		}
+		level := 0
+		if hasLevel {
+			level, err = parseLevel(row.get(mapping.Level))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		for len(stack) > 0 && stack[len(stack)-1].level >= level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 && stack[len(stack)-1].part == nil {
+			stack = append(stack, frame{level: level})
+			continue
+		}
+		part, child, err := readRow(bom, row, mapping, known)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(stack) > 0 {
+			err = stack[len(stack)-1].part.AddLine(child)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		if len(part.Lines) > 0 {
+			stack = append(stack, frame{level: level})
+		} else {
+			stack = append(stack, frame{level: level, part: part})
+		}
+	}
+	return bom, nil
+}
+
+// readRow returns the part of a row, and the line adding it to its parent.
+func readRow(bom *BOM, row *csvRow, mapping *Mapping, known map[string]bool) (*Part, *Line, error) {
+	part, err := bom.Part(row.get(mapping.PartNumber), row.get(mapping.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, column := range row.header {
+		if known[normalize(column)] || i >= len(row.record) {
+			continue
+		}
+		part.SetDetail(column, row.record[i])
+	}
+	line := &Line{Part: part, Qty: 1}
+	line.Refdes, err = splitRefdes(row.get(mapping.Refdes))
+	if err != nil {
+		return nil, nil, err
+	}
+	if line.Refdes != nil {
+		line.Qty = float64(len(line.Refdes))
+	}
+	if cell := row.get(mapping.Qty); cell != "" {
+		line.HasQty = true
+		line.Qty, line.Unit, err = unit.ParseQuantity(cell)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if cell := row.get(mapping.Unit); cell != "" {
+		if line.Unit != "" && line.Unit != cell {
+			return nil, nil, fmt.Errorf("qty unit %s conflicts with unit %s", line.Unit, cell)
+		}
+		_, err = unit.Lookup(cell)
+		if err != nil {
+			return nil, nil, err
+		}
+		line.Unit = cell
+	}
+	return part, line, nil
+}
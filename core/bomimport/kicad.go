@@ -0,0 +1,115 @@
+package bomimport
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+type kicadExport struct {
+	XMLName    xml.Name    `xml:"export"`
+	Source     string      `xml:"design>source"`
+	Components []kicadComp `xml:"components>comp"`
+}
+
+type kicadField struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+type kicadProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type kicadComp struct {
+	Ref        string          `xml:"ref,attr"`
+	Value      string          `xml:"value"`
+	Footprint  string          `xml:"footprint"`
+	Datasheet  string          `xml:"datasheet"`
+	Fields     []kicadField    `xml:"fields>field"`
+	Properties []kicadProperty `xml:"property"`
+	LibSource  struct {
+		Description string `xml:"description,attr"`
+	} `xml:"libsource"`
+}
+
+// excluded reports components marked as not fitted or not in the BOM.
+func (c *kicadComp) excluded() bool {
+	for _, p := range c.Properties {
+		if p.Name == "exclude_from_bom" || p.Name == "dnp" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *kicadComp) field(name string) string {
+	for _, f := range c.Fields {
+		if normalize(f.Name) == normalize(name) {
+			return strings.TrimSpace(f.Value)
+		}
+	}
+	return ""
+}
+
+// name identifies parts without part number by value and footprint, e.g.
+// "10k R_0402_1005Metric".
+func (c *kicadComp) name() string {
+	_, footprint, _ := strings.Cut(c.Footprint, ":")
+	if footprint == "" {
+		footprint = c.Footprint
+	}
+	return strings.TrimSpace(c.Value + " " + footprint)
+}
+
+// ReadKiCad reads the XML netlist KiCad exports for BOM generation into one
+// assembly, with a line per part and the references of its components as
+// designators. The part number is read from the field mapped to
+// part_number. The assembly is named root, or after the schematic.
+func ReadKiCad(r io.Reader, mapping *Mapping, root string) (*BOM, error) {
+	export := &kicadExport{}
+	err := xml.NewDecoder(r).Decode(export)
+	if err != nil {
+		return nil, err
+	}
+	if root == "" {
+		base := path.Base(strings.ReplaceAll(export.Source, "\\", "/"))
+		root = strings.TrimSuffix(base, path.Ext(base))
+	}
+	if root == "" || root == "." {
+		return nil, errors.New("a root name is required")
+	}
+	bom := NewBOM()
+	top, err := bom.Part("", root)
+	if err != nil {
+		return nil, err
+	}
+	for _, comp := range export.Components {
+		if comp.excluded() {
+			continue
+		}
+		part, err := bom.Part(comp.field(mapping.PartNumber), comp.name())
+		if err != nil {
+			return nil, err
+		}
+		part.SetDetail("value", comp.Value)
+		part.SetDetail("footprint", comp.Footprint)
+		if comp.Datasheet != "~" {
+			part.SetDetail("datasheet", comp.Datasheet)
+		}
+		part.SetDetail("summary", comp.LibSource.Description)
+		for _, f := range comp.Fields {
+			if normalize(f.Name) != normalize(mapping.PartNumber) {
+				part.SetDetail(f.Name, f.Value)
+			}
+		}
+		err = top.AddLine(&Line{Part: part, Qty: 1, Refdes: []string{comp.Ref}})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bom, nil
+}
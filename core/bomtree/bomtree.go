@@ -2,8 +2,10 @@ package bomtree
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/tychonis/cyanotype/core/process"
+	"github.com/tychonis/cyanotype/internal/unit"
 	"github.com/tychonis/cyanotype/model"
 )
 
@@ -20,28 +22,65 @@ type Node struct {
 	Parent   *Node
 	Children []*Node
 	Qty      float64
+	Unit     string
+	Refdes   []string
 }
 
-func (node *Node) Count() map[string]float64 {
-	counter := make(map[string]float64)
-	count(node, 1, counter)
-	return counter
+// Entry is the total quantity of one item, in the item's own unit.
+type Entry struct {
+	Item *model.Item
+	Qty  float64
+	// Refdes collects the designators of the item across assemblies.
+	Refdes []string
 }
 
-func count(node *Node, multiplier float64, counter map[string]float64) {
-	item := node.Item
+func (e *Entry) Unit() string {
+	return itemUnit(e.Item)
+}
+
+type Counter = map[model.Qualifier]*Entry
 
-	if len(node.Children) == 0 {
-		counter[item.Qualifier] += multiplier
-		return
+func itemUnit(item *model.Item) string {
+	if item.Content == nil || item.Content.Unit == "" {
+		return unit.DEFAULT
 	}
+	return item.Content.Unit
+}
+
+func (node *Node) Count() (Counter, error) {
+	counter := make(Counter)
+	err := count(node, 1, counter)
+	return counter, err
+}
+
+func count(node *Node, multiplier float64, counter Counter) error {
+	item := node.Item
 
 	// also count assembly?
-	counter[item.Qualifier] += multiplier
+	entry, ok := counter[item.Qualifier]
+	if !ok {
+		entry = &Entry{Item: item}
+		counter[item.Qualifier] = entry
+	}
+	entry.Qty += multiplier
+	entry.Refdes = append(entry.Refdes, node.Refdes...)
 
 	for _, child := range node.Children {
-		count(child, child.Qty*multiplier, counter)
+		// A line without unit is in the unit of its item.
+		qty := child.Qty
+		if child.Unit != "" {
+			var err error
+			qty, err = unit.Convert(child.Qty, child.Unit, itemUnit(child.Item))
+			if err != nil {
+				return fmt.Errorf("%s in %s: %w", child.Name, item.Qualifier, err)
+			}
+		}
+		err := count(child, qty*multiplier, counter)
+		if err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 type NodeInfo struct {
@@ -54,6 +93,8 @@ type NodeInfo struct {
 	Parent    model.Digest   `json:"parent"`
 	Children  []model.Digest `json:"children"`
 	Qty       float64        `json:"qty"`
+	Unit      string         `json:"unit,omitempty"`
+	Refdes    []string       `json:"refdes,omitempty"`
 }
 
 type TreeDocument struct {
@@ -89,6 +130,8 @@ func export(node *Node, doc *TreeDocument) {
 		Parent:    parentID,
 		Children:  make([]model.Digest, 0, len(node.Children)),
 		Qty:       node.Qty,
+		Unit:      node.Unit,
+		Refdes:    node.Refdes,
 	}
 	doc.Nodes[node.ID] = info
 
@@ -33,6 +33,7 @@ func NewRemoteCatalog(endpoint string, token string, tag string) *Catalog {
 type IndexContent struct {
 	QualifierIndex map[Qualifier]QualifierIndexEntry    `json:"qualifier_index"`
 	ProcessIndex   map[model.ItemID]*ProcessIndexEntry  `json:"process_index"`
+	ContractIndex  map[model.Digest]*ContractIndexEntry `json:"contract_index,omitempty"`
 	RevisionIndex  map[model.RevisionID]*model.Revision `json:"revision_index"`
 }
 
@@ -68,10 +69,14 @@ func loadIndex(endpoint string, client *http.Client) (*LocalIndex, error) {
 	if err != nil {
 		return NewLocalIndex(false), err
 	}
+	if content.ContractIndex == nil {
+		content.ContractIndex = make(map[model.Digest]*ContractIndexEntry)
+	}
 	idx := &LocalIndex{
 		qualifierIndex: content.QualifierIndex,
 		digestIndex:    qualifierIndexToDigestIndex(content.QualifierIndex),
 		processIndex:   content.ProcessIndex,
+		contractIndex:  content.ContractIndex,
 		revisionIndex:  content.RevisionIndex,
 
 		persistent: false,
@@ -220,6 +225,10 @@ func (c *Catalog) Pull(other *Catalog) error {
 		if err != nil {
 			return err
 		}
+		err = c.pullArtifacts(other, sym)
+		if err != nil {
+			return err
+		}
 		metadata, err := other.GetMetadata(symDigest)
 		if err != nil {
 			return err
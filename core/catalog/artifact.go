@@ -0,0 +1,230 @@
+package catalog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/tychonis/cyanotype/model"
+)
+
+// CHUNK_SIZE is the size above which artifacts are stored in chunks, so
+// large CAD files are transferred piece by piece.
+const CHUNK_SIZE = 8 << 20
+
+// CHUNKS_SUFFIX names the list of chunks of an artifact stored in chunks.
+const CHUNKS_SUFFIX = ".chunks"
+
+// ErrNoArtifact is returned for artifacts whose content is not stored.
+var ErrNoArtifact = errors.New("artifact content not stored")
+
+type chunkList struct {
+	Size   int64          `json:"size"`
+	Chunks []model.Digest `json:"chunks"`
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// HasArtifact reports whether the content of an artifact is stored. Names
+// that are not digests, such as opaque digest: sources, are never stored.
+func (c *Catalog) HasArtifact(digest model.Digest) (bool, error) {
+	if !validDigest(digest) {
+		return false, nil
+	}
+	ok, err := c.storage.HasBlob(digest)
+	if err != nil || ok {
+		return ok, err
+	}
+	return c.storage.HasBlob(digest + CHUNKS_SUFFIX)
+}
+
+// SaveArtifact stores the content of an artifact read from r, which must
+// hash to digest. Content larger than CHUNK_SIZE is stored in chunks.
+func (c *Catalog) SaveArtifact(digest model.Digest, r io.Reader) error {
+	ok, err := c.HasArtifact(digest)
+	if err != nil || ok {
+		return err
+	}
+	buf := make([]byte, CHUNK_SIZE)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		data := bytes.Clone(buf[:n])
+		if got := hashBytes(data); got != digest {
+			return fmt.Errorf("artifact content changed: expected %s, got %s", digest, got)
+		}
+		return c.storage.SaveBlob(digest, data)
+	}
+	if err != nil {
+		return err
+	}
+	hasher := sha256.New()
+	list := &chunkList{}
+	for n > 0 {
+		chunk := bytes.Clone(buf[:n])
+		hasher.Write(chunk)
+		list.Size += int64(n)
+		list.Chunks = append(list.Chunks, hashBytes(chunk))
+		err = c.storage.SaveBlob(list.Chunks[len(list.Chunks)-1], chunk)
+		if err != nil {
+			return err
+		}
+		n, err = io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+	}
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != digest {
+		return fmt.Errorf("artifact content changed: expected %s, got %s", digest, got)
+	}
+	body, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return c.storage.SaveBlob(digest+CHUNKS_SUFFIX, body)
+}
+
+func (c *Catalog) loadChunkList(digest model.Digest) (*chunkList, error) {
+	body, err := c.storage.LoadBlob(digest + CHUNKS_SUFFIX)
+	if err != nil {
+		return nil, err
+	}
+	list := &chunkList{}
+	err = json.Unmarshal(body, list)
+	return list, err
+}
+
+// artifactBlobs lists the blobs holding an artifact.
+func (c *Catalog) artifactBlobs(digest model.Digest) ([]string, error) {
+	if !validDigest(digest) {
+		return nil, ErrNoArtifact
+	}
+	ok, err := c.storage.HasBlob(digest)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return []string{digest}, nil
+	}
+	ok, err = c.storage.HasBlob(digest + CHUNKS_SUFFIX)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNoArtifact
+	}
+	list, err := c.loadChunkList(digest)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{digest + CHUNKS_SUFFIX}, list.Chunks...), nil
+}
+
+// WriteArtifact writes the content of an artifact to w, checking every
+// chunk against its digest.
+func (c *Catalog) WriteArtifact(digest model.Digest, w io.Writer) error {
+	blobs, err := c.artifactBlobs(digest)
+	if err != nil {
+		return err
+	}
+	if len(blobs) > 1 {
+		blobs = blobs[1:]
+	}
+	hasher := sha256.New()
+	for _, blob := range blobs {
+		data, err := c.storage.LoadBlob(blob)
+		if err != nil {
+			return err
+		}
+		if len(blobs) > 1 && hashBytes(data) != blob {
+			return fmt.Errorf("chunk %s of artifact %s is corrupted", blob, digest)
+		}
+		hasher.Write(data)
+		_, err = w.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != digest {
+		return fmt.Errorf("artifact %s is corrupted", digest)
+	}
+	return nil
+}
+
+func symbolArtifacts(sym model.ConcreteSymbol) []*model.Artifact {
+	switch s := sym.(type) {
+	case *model.Item:
+		if s.Content != nil {
+			return s.Content.Artifacts
+		}
+	case *model.CoItem:
+		if s.Content != nil {
+			return s.Content.Artifacts
+		}
+	}
+	return nil
+}
+
+// pullArtifacts copies the artifact blobs of sym missing from c. Artifacts
+// other has no content for are skipped.
+func (c *Catalog) pullArtifacts(other *Catalog, sym model.ConcreteSymbol) error {
+	for _, a := range symbolArtifacts(sym) {
+		digests := []model.Digest{a.Digest}
+		for _, file := range a.Files {
+			digests = append(digests, file.Digest)
+		}
+		for _, d := range digests {
+			err := c.pullBlobs(other, d)
+			if errors.Is(err, ErrNoArtifact) {
+				slog.Warn("Artifact content not found in source catalog.",
+					"qualifier", sym.GetQualifier(), "artifact", a.Name)
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pullBlobs copies the blobs holding the content of digest missing from c.
+func (c *Catalog) pullBlobs(other *Catalog, digest model.Digest) error {
+	blobs, err := other.artifactBlobs(digest)
+	if err != nil {
+		return err
+	}
+	for _, blob := range blobs {
+		ok, err := c.storage.HasBlob(blob)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		if !validDigest(blob) {
+			return fmt.Errorf("incorrect blob name %q", blob)
+		}
+		data, err := other.storage.LoadBlob(blob)
+		if err != nil {
+			return err
+		}
+		// Chunk lists are checked through the chunks they list.
+		if !strings.HasSuffix(blob, CHUNKS_SUFFIX) && hashBytes(data) != blob {
+			return fmt.Errorf("blob %s of artifact %s is corrupted", blob, digest)
+		}
+		err = c.storage.SaveBlob(blob, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
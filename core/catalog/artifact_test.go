@@ -0,0 +1,104 @@
+package catalog_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tychonis/cyanotype/core/catalog"
+	"github.com/tychonis/cyanotype/internal/digest"
+	"github.com/tychonis/cyanotype/model"
+)
+
+func TestArtifactPull(t *testing.T) {
+	var err error
+	src := catalog.New("memory")
+	rev := src.NewRevision()
+
+	// Large enough to be stored in chunks.
+	content := bytes.Repeat([]byte("solid "), catalog.CHUNK_SIZE/3)
+	sum := sha256.Sum256(content)
+	drawing := hex.EncodeToString(sum[:])
+	small := []byte("notes")
+	sum = sha256.Sum256(small)
+	notes := hex.EncodeToString(sum[:])
+
+	item := &model.Item{}
+	item.Type = "item"
+	item.Qualifier = ".deck"
+	item.Content = &model.ItemContent{
+		Name: "deck",
+		Artifacts: []*model.Artifact{
+			{Name: "cad", Digest: drawing},
+			{Name: "notes", Digest: notes},
+			{Name: "external", Digest: "0000"},
+		},
+	}
+	item.Digest, err = digest.SHA256FromSymbol(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addSymbol(t, src, rev, item)
+	err = src.SaveArtifact(drawing, bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = src.SaveArtifact(notes, bytes.NewReader(small))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = src.SaveArtifact(notes, bytes.NewReader([]byte("changed")))
+	if err != nil {
+		t.Fatalf("stored artifacts should not be read again: %v", err)
+	}
+	err = src.SaveArtifact("0000", bytes.NewReader(small))
+	if err == nil {
+		t.Error("expected mismatching content to be rejected")
+	}
+	err = src.Commit(rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := catalog.New("memory")
+	err = dst.Pull(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d, want := range map[string][]byte{drawing: content, notes: small} {
+		got := &bytes.Buffer{}
+		err = dst.WriteArtifact(d, got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Bytes(), want) {
+			t.Errorf("artifact %s differs after pull", d)
+		}
+	}
+	if ok, _ := dst.HasArtifact("0000"); ok {
+		t.Error("unexpected artifact without content")
+	}
+}
+
+func TestArtifactNames(t *testing.T) {
+	t.Chdir(t.TempDir())
+	cat := catalog.New("local")
+	for _, name := range []string{"../../escape", "0000", strings.Repeat("A", 64)} {
+		ok, err := cat.HasArtifact(name)
+		if err != nil || ok {
+			t.Errorf("expected %q to be reported as not stored: %v %v", name, ok, err)
+		}
+		err = cat.WriteArtifact(name, &bytes.Buffer{})
+		if !errors.Is(err, catalog.ErrNoArtifact) {
+			t.Errorf("expected %q to have no content: %v", name, err)
+		}
+	}
+	sum := sha256.Sum256([]byte("notes"))
+	ok, err := cat.HasArtifact(hex.EncodeToString(sum[:]))
+	if err != nil || ok {
+		t.Errorf("unexpected artifact: %v %v", ok, err)
+	}
+}
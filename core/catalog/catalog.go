@@ -3,8 +3,10 @@ package catalog
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/tychonis/cyanotype/core/process"
@@ -171,12 +173,68 @@ func (c *Catalog) Get(digest model.Digest) (model.ConcreteSymbol, error) {
 		ret.Qualifier = qualifier
 		ret.Digest = digest
 		return ret, nil
+	case "contract":
+		ret, err := serializer.Deserialize[*model.Contract](body)
+		if err != nil {
+			return ret, err
+		}
+		ret.Qualifier = qualifier
+		ret.Digest = digest
+		return ret, nil
 	default:
 		slog.Warn("Unknown symbol type", "type", symType, "digest", digest)
 		return nil, errors.New("unknown type")
 	}
 }
 
+func (c *Catalog) GetContract(digest model.Digest) (*model.Contract, error) {
+	sym, err := c.Get(digest)
+	if err != nil {
+		return nil, err
+	}
+	contract, ok := sym.(*model.Contract)
+	if !ok {
+		return nil, errors.New("not a contract")
+	}
+	return contract, nil
+}
+
+// GetItemContracts returns the contracts implemented by an item.
+func (c *Catalog) GetItemContracts(item model.ItemID) ([]*model.Contract, error) {
+	entry, err := c.index.GetContractEntry(item)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return getSymbols[*model.Contract](c, entry.Implements)
+}
+
+// GetContractItems returns the items implementing a contract.
+func (c *Catalog) GetContractItems(contract model.ContractID) ([]*model.Item, error) {
+	entry, err := c.index.GetContractEntry(contract)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return getSymbols[*model.Item](c, entry.ImplementedBy)
+}
+
+// GetContractCoItems returns the coitems requiring a contract.
+func (c *Catalog) GetContractCoItems(contract model.ContractID) ([]*model.CoItem, error) {
+	entry, err := c.index.GetContractEntry(contract)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return getSymbols[*model.CoItem](c, entry.RequiredBy)
+}
+
 func (c *Catalog) GetSymbolMetadata(digest model.Digest) (*Metadata, error) {
 	body, err := c.storage.LoadMetadata(digest)
 	if err != nil {
@@ -297,6 +355,49 @@ func (c *Catalog) GetSymbols() (map[model.Digest]model.ConcreteSymbol, error) {
 	return ret, nil
 }
 
+// GetSymbolsAt returns the symbols current at a revision, keyed by
+// qualifier.
+func (c *Catalog) GetSymbolsAt(rev model.RevisionID) (map[Qualifier]model.ConcreteSymbol, error) {
+	digests, err := c.index.FindDigestsAt(rev)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[Qualifier]model.ConcreteSymbol, len(digests))
+	for qualifier, digest := range digests {
+		sym, err := c.Get(digest)
+		if err != nil {
+			return nil, err
+		}
+		ret[qualifier] = sym
+	}
+	return ret, nil
+}
+
+// FindRevision returns the revision whose id is, or starts with, prefix.
+func (c *Catalog) FindRevision(prefix string) (*model.Revision, error) {
+	all, err := c.index.GetAllRevisions()
+	if err != nil {
+		return nil, err
+	}
+	var found model.RevisionID
+	for _, id := range all {
+		if id == prefix {
+			return c.GetRevision(id)
+		}
+		if !strings.HasPrefix(id, prefix) {
+			continue
+		}
+		if found != "" {
+			return nil, fmt.Errorf("revision %s is ambiguous", prefix)
+		}
+		found = id
+	}
+	if found == "" {
+		return nil, ErrNotFound
+	}
+	return c.GetRevision(found)
+}
+
 func (c *Catalog) Commit(revision *model.Revision) error {
 	c.index.IndexRevision(revision)
 	body, err := serializer.Serialize(revision)
@@ -0,0 +1,134 @@
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/tychonis/cyanotype/model"
+)
+
+// ContractIndexEntry links a symbol to contracts. Items and coitems fill
+// Implements and Requires, contracts fill ImplementedBy and RequiredBy.
+type ContractIndexEntry struct {
+	Implements    []model.ContractID `json:"implements,omitempty"`
+	Requires      []model.ContractID `json:"requires,omitempty"`
+	ImplementedBy []model.ItemID     `json:"implemented_by,omitempty"`
+	RequiredBy    []model.ItemID     `json:"required_by,omitempty"`
+}
+
+func appendUnique(list []model.Digest, d model.Digest) ([]model.Digest, bool) {
+	if slices.Contains(list, d) {
+		return list, false
+	}
+	return append(list, d), true
+}
+
+func (idx *LocalIndex) contractEntry(key model.Digest) *ContractIndexEntry {
+	entry, ok := idx.contractIndex[key]
+	if !ok || entry == nil {
+		entry = &ContractIndexEntry{}
+		idx.contractIndex[key] = entry
+	}
+	return entry
+}
+
+// link records both directions of a relation and reports whether it is new.
+func (idx *LocalIndex) link(relation string, key model.Digest, contract model.ContractID) (bool, error) {
+	from := idx.contractEntry(key)
+	to := idx.contractEntry(contract)
+	var added bool
+	switch relation {
+	case "implement":
+		from.Implements, added = appendUnique(from.Implements, contract)
+		to.ImplementedBy, _ = appendUnique(to.ImplementedBy, key)
+	case "require":
+		from.Requires, added = appendUnique(from.Requires, contract)
+		to.RequiredBy, _ = appendUnique(to.RequiredBy, key)
+	default:
+		return false, errors.New("illegal contract relation")
+	}
+	return added, nil
+}
+
+func (idx *LocalIndex) loadContractIndex() error {
+	if !idx.persistent {
+		return nil
+	}
+
+	indexPath := filepath.Join(".bpc", "contract")
+	data, err := os.ReadFile(indexPath)
+	if errors.Is(err, os.ErrNotExist) {
+		// Catalogs created before contracts were indexed.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open index: %w", err)
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		parts := bytes.SplitN(line, []byte(":"), 3)
+		if len(parts) != 3 {
+			return fmt.Errorf("malformed part")
+		}
+		_, err := idx.link(string(parts[0]), model.Digest(parts[1]), model.ContractID(parts[2]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (idx *LocalIndex) addToContractIndex(relation string, key model.Digest, contract model.ContractID) error {
+	added, err := idx.link(relation, key, contract)
+	if err != nil || !added || !idx.persistent {
+		return err
+	}
+
+	indexPath := filepath.Join(".bpc", "contract")
+	f, err := os.OpenFile(indexPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open index: %w", err)
+	}
+	defer f.Close()
+	rec := relation + ":" + key + ":" + contract + "\n"
+	_, err = f.Write([]byte(rec))
+	if err != nil {
+		return fmt.Errorf("write index: %w", err)
+	}
+	return f.Sync()
+}
+
+func (idx *LocalIndex) indexContracts(sym model.ConcreteSymbol) error {
+	switch resolved := sym.(type) {
+	case *model.Item:
+		for _, contract := range resolved.Implement {
+			err := idx.addToContractIndex("implement", resolved.Digest, contract)
+			if err != nil {
+				return err
+			}
+		}
+	case *model.CoItem:
+		for _, contract := range resolved.Require {
+			err := idx.addToContractIndex("require", resolved.Digest, contract)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (idx *LocalIndex) GetContractEntry(d model.Digest) (*ContractIndexEntry, error) {
+	entry, ok := idx.contractIndex[d]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return entry, nil
+}
@@ -0,0 +1,72 @@
+package catalog_test
+
+import (
+	"testing"
+
+	"github.com/tychonis/cyanotype/core/catalog"
+	"github.com/tychonis/cyanotype/internal/digest"
+	"github.com/tychonis/cyanotype/model"
+)
+
+func addSymbol(t *testing.T, cat *catalog.Catalog, rev *model.Revision, sym model.ConcreteSymbol) {
+	t.Helper()
+	err := cat.Add(rev, sym)
+	if err != nil {
+		t.Fatalf("failed to add %s: %v", sym.GetQualifier(), err)
+	}
+}
+
+func TestContractIndex(t *testing.T) {
+	var err error
+	cat := catalog.New("memory")
+	rev := cat.NewRevision()
+
+	contract := &model.Contract{Type: "contract", Qualifier: ".m3", Name: "m3"}
+	contract.Digest, err = digest.SHA256FromSymbol(contract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := &model.Item{Implement: []model.ContractID{contract.Digest}}
+	item.Type = "item"
+	item.Qualifier = ".screw"
+	item.Digest, err = digest.SHA256FromSymbol(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coItem := &model.CoItem{Require: []model.ContractID{contract.Digest}}
+	coItem.Type = "coitem"
+	coItem.Qualifier = ".fastener"
+	coItem.Digest, err = digest.SHA256FromSymbol(coItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addSymbol(t, cat, rev, contract)
+	addSymbol(t, cat, rev, item)
+	addSymbol(t, cat, rev, coItem)
+	err = cat.Commit(rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := cat.FindCurrent(".m3")
+	if err != nil {
+		t.Fatalf("contract not loadable: %v", err)
+	}
+	if loaded.GetDigest() != contract.Digest {
+		t.Errorf("loaded digest %s, want %s", loaded.GetDigest(), contract.Digest)
+	}
+
+	contracts, err := cat.GetItemContracts(item.Digest)
+	if err != nil || len(contracts) != 1 || contracts[0].Qualifier != ".m3" {
+		t.Errorf("GetItemContracts = %v, %v", contracts, err)
+	}
+	items, err := cat.GetContractItems(contract.Digest)
+	if err != nil || len(items) != 1 || items[0].Qualifier != ".screw" {
+		t.Errorf("GetContractItems = %v, %v", items, err)
+	}
+	coItems, err := cat.GetContractCoItems(contract.Digest)
+	if err != nil || len(coItems) != 1 || coItems[0].Qualifier != ".fastener" {
+		t.Errorf("GetContractCoItems = %v, %v", coItems, err)
+	}
+}
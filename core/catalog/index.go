@@ -28,6 +28,8 @@ type SymbolIndex interface {
 
 	FindAllDigests(q Qualifier) ([]model.Digest, error)
 	FindCurrentDigest(q Qualifier) (model.Digest, error)
+	// FindDigestsAt returns the digest of every qualifier as of revision r.
+	FindDigestsAt(r model.RevisionID) (map[Qualifier]model.Digest, error)
 
 	FindAllQualifiers(d model.Digest) ([]Qualifier, error)
 	FindCurrentQualifier(d model.Digest) (Qualifier, error)
@@ -35,6 +37,8 @@ type SymbolIndex interface {
 	GetItemProcesses(item model.ItemID) ([]process.ProcessID, error)
 	GetItemCoProcesses(item model.ItemID) ([]process.ProcessID, error)
 
+	GetContractEntry(d model.Digest) (*ContractIndexEntry, error)
+
 	GetContent() *IndexContent
 }
 
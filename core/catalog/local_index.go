@@ -20,6 +20,7 @@ type LocalIndex struct {
 	qualifierIndex map[Qualifier]QualifierIndexEntry
 	digestIndex    map[model.Digest]DigestIndexEntry
 	processIndex   map[model.ItemID]*ProcessIndexEntry
+	contractIndex  map[model.Digest]*ContractIndexEntry
 	revisionIndex  map[model.RevisionID]*model.Revision
 
 	persistent bool
@@ -35,6 +36,7 @@ func NewLocalIndex(persistent bool) *LocalIndex {
 		qualifierIndex: make(map[Qualifier]QualifierIndexEntry),
 		digestIndex:    make(map[model.Digest]DigestIndexEntry),
 		processIndex:   make(map[model.ItemID]*ProcessIndexEntry),
+		contractIndex:  make(map[model.Digest]*ContractIndexEntry),
 		revisionIndex:  make(map[model.RevisionID]*model.Revision),
 
 		persistent: persistent,
@@ -53,6 +55,10 @@ func (idx *LocalIndex) load() error {
 	if err != nil {
 		return err
 	}
+	err = idx.loadContractIndex()
+	if err != nil {
+		return err
+	}
 	return idx.loadRevisionIndex()
 }
 
@@ -78,8 +84,9 @@ func (idx *LocalIndex) loadMainIndex() error {
 			continue
 		}
 
-		parts := bytes.SplitN(line, []byte(":"), 3)
-		if len(parts) != 3 {
+		// Qualifiers of git imports contain colons, digests never do.
+		parts := splitLast(line, ':', 3)
+		if parts == nil {
 			return fmt.Errorf("malformed part")
 		}
 
@@ -102,6 +109,21 @@ func (idx *LocalIndex) loadMainIndex() error {
 	return nil
 }
 
+// splitLast splits b into n parts around the last n-1 separators.
+func splitLast(b []byte, sep byte, n int) [][]byte {
+	parts := make([][]byte, n)
+	for i := n - 1; i > 0; i-- {
+		j := bytes.LastIndexByte(b, sep)
+		if j < 0 {
+			return nil
+		}
+		parts[i] = b[j+1:]
+		b = b[:j]
+	}
+	parts[0] = b
+	return parts
+}
+
 func (idx *LocalIndex) addToMainIndex(qualifier string, revision model.RevisionID, symDigest model.Digest) error {
 	qEntry, ok := idx.qualifierIndex[qualifier]
 	if !ok {
@@ -167,19 +189,9 @@ func (idx *LocalIndex) loadProcessIndex() error {
 		}
 		switch pType {
 		case "process":
-			for _, p := range entry.Processes {
-				if p == val {
-					return nil
-				}
-			}
-			entry.Processes = append(entry.Processes, val)
+			entry.Processes, _ = appendUnique(entry.Processes, val)
 		case "coprocess":
-			for _, p := range entry.CoProcesses {
-				if p == val {
-					return nil
-				}
-			}
-			entry.CoProcesses = append(entry.CoProcesses, val)
+			entry.CoProcesses, _ = appendUnique(entry.CoProcesses, val)
 		default:
 			return errors.New("illegal process type")
 		}
@@ -256,7 +268,11 @@ func (idx *LocalIndex) IndexSymbol(rev *model.Revision, sym model.ConcreteSymbol
 	if err != nil {
 		return err
 	}
-	return idx.indexProcess(sym)
+	err = idx.indexProcess(sym)
+	if err != nil {
+		return err
+	}
+	return idx.indexContracts(sym)
 }
 
 func (idx *LocalIndex) GetAllSymbols() ([]model.Digest, error) {
@@ -332,6 +348,30 @@ func (idx *LocalIndex) FindCurrentDigest(q Qualifier) (model.Digest, error) {
 	return latestRevision, nil
 }
 
+// FindDigestsAt picks, for every qualifier, the digest added by r or by the
+// latest revision before it. Qualifiers added after r are left out.
+func (idx *LocalIndex) FindDigestsAt(r model.RevisionID) (map[Qualifier]model.Digest, error) {
+	if _, ok := idx.revisionIndex[r]; !ok {
+		return nil, ErrNotFound
+	}
+	ret := make(map[Qualifier]model.Digest)
+	for q, entry := range idx.qualifierIndex {
+		var current model.RevisionID
+		for rev := range entry {
+			if idx.CompareRevisions(rev, r) > 0 {
+				continue
+			}
+			if current == "" || idx.CompareRevisions(rev, current) > 0 {
+				current = rev
+			}
+		}
+		if current != "" {
+			ret[q] = entry[current]
+		}
+	}
+	return ret, nil
+}
+
 func (idx *LocalIndex) FindCurrentQualifier(d model.Digest) (Qualifier, error) {
 	entry, ok := idx.digestIndex[d]
 	if !ok {
@@ -440,6 +480,7 @@ func (idx *LocalIndex) GetContent() *IndexContent {
 	return &IndexContent{
 		QualifierIndex: idx.qualifierIndex,
 		ProcessIndex:   idx.processIndex,
+		ContractIndex:  idx.contractIndex,
 		RevisionIndex:  idx.revisionIndex,
 	}
 }
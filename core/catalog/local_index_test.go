@@ -0,0 +1,43 @@
+package catalog_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tychonis/cyanotype/core/catalog"
+)
+
+func TestLoadProcessIndexDuplicates(t *testing.T) {
+	t.Chdir(t.TempDir())
+	files := map[string]string{
+		"index":    "",
+		"revision": "",
+		"process": "process:item:p1\n" +
+			"process:item:p1\n" +
+			"process:item:p2\n" +
+			"coprocess:coitem:c1\n" +
+			"coprocess:coitem:c1\n",
+	}
+	err := os.Mkdir(".bpc", 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		err = os.WriteFile(filepath.Join(".bpc", name), []byte(content), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx := catalog.NewLocalIndex(true)
+	processes, err := idx.GetItemProcesses("item")
+	if err != nil || !slices.Equal(processes, []string{"p1", "p2"}) {
+		t.Errorf("entries after a duplicate should still be loaded: %v %v", processes, err)
+	}
+	coProcesses, err := idx.GetItemCoProcesses("coitem")
+	if err != nil || !slices.Equal(coProcesses, []string{"c1"}) {
+		t.Errorf("unexpected coprocesses: %v %v", coProcesses, err)
+	}
+}
@@ -18,13 +18,34 @@ type Storage interface {
 	SaveMetadata(digest model.Digest, metadata []byte) error
 	Load(digest model.Digest) ([]byte, error)
 	LoadMetadata(digest model.Digest) ([]byte, error)
+
+	// Blobs hold artifact content, or chunks of it, by name.
+	SaveBlob(name string, data []byte) error
+	LoadBlob(name string) ([]byte, error)
+	HasBlob(name string) (bool, error)
 }
 
 type LocalStorage struct{}
 
+// validDigest reports whether name is a sha256 digest in lowercase hex,
+// optionally naming the chunk list of an artifact. Names may come from a
+// remote catalog and end up in paths, so nothing else is accepted.
+func validDigest(name string) bool {
+	name = strings.TrimSuffix(name, CHUNKS_SUFFIX)
+	if len(name) != 64 {
+		return false
+	}
+	for _, c := range name {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
 func digestToPath(digest string) (string, error) {
-	if len(digest) < 2 {
-		return "", errors.New("incorrect digest")
+	if !validDigest(digest) {
+		return "", fmt.Errorf("incorrect digest %q", digest)
 	}
 	folder := digest[:2]
 	return filepath.Join(".bpc", "objects", folder, digest), nil
@@ -78,6 +99,28 @@ func (ls *LocalStorage) LoadMetadata(digest model.Digest) ([]byte, error) {
 	return os.ReadFile(path)
 }
 
+// Blobs are content addressed like symbols, so they share the objects
+// folder.
+func (ls *LocalStorage) SaveBlob(name string, data []byte) error {
+	return ls.Save(name, data)
+}
+
+func (ls *LocalStorage) LoadBlob(name string) ([]byte, error) {
+	return ls.Load(name)
+}
+
+func (ls *LocalStorage) HasBlob(name string) (bool, error) {
+	path, err := digestToPath(name)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 type MemoryStore struct {
 	storage map[string][]byte
 }
@@ -114,6 +157,23 @@ func (m *MemoryStore) LoadMetadata(digest model.Digest) ([]byte, error) {
 	return metadata, nil
 }
 
+func (m *MemoryStore) SaveBlob(name string, data []byte) error {
+	return m.Save(name, data)
+}
+
+func (m *MemoryStore) LoadBlob(name string) ([]byte, error) {
+	data, ok := m.storage[name]
+	if !ok {
+		return nil, errors.New("blob not found")
+	}
+	return data, nil
+}
+
+func (m *MemoryStore) HasBlob(name string) (bool, error) {
+	_, ok := m.storage[name]
+	return ok, nil
+}
+
 type APIStore struct {
 	endpoint string
 
@@ -188,3 +248,52 @@ func (a *APIStore) LoadMetadata(digest model.Digest) ([]byte, error) {
 	}
 	return io.ReadAll(resp.Body)
 }
+
+func (a *APIStore) SaveBlob(name string, data []byte) error {
+	url := fmt.Sprintf("%s/blob/%s", a.endpoint, name)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return errors.New("error response saving blob: " + resp.Status)
+	}
+	return nil
+}
+
+func (a *APIStore) LoadBlob(name string) ([]byte, error) {
+	url := fmt.Sprintf("%s/blob/%s", a.endpoint, name)
+	resp, err := a.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("error response loading blob: " + resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (a *APIStore) HasBlob(name string) (bool, error) {
+	url := fmt.Sprintf("%s/blob/%s", a.endpoint, name)
+	resp, err := a.client.Head(url)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, errors.New("error response checking blob: " + resp.Status)
+	}
+}
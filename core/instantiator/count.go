@@ -2,10 +2,15 @@ package instantiator
 
 import (
 	"encoding/csv"
-	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/tychonis/cyanotype/core/bomtree"
 	"github.com/tychonis/cyanotype/core/catalog"
+	"github.com/tychonis/cyanotype/internal/refdes"
 )
 
 type Component struct {
@@ -14,25 +19,43 @@ type Component struct {
 	Qty  float64
 }
 
-func (i *Instantiator) Count(cat *catalog.Catalog, root string) (map[string]float64, error) {
+func (i *Instantiator) Count(cat *catalog.Catalog, root string) (bomtree.Counter, error) {
 	tree, err := i.TreeFromQualifier(cat, root)
 	if err != nil {
 		return nil, err
 	}
 
-	return tree.Count(), nil
+	return tree.Count()
 }
 
 func getHeader() []string {
-	return []string{"Part ID", "Part Number", "Name", "Quantity"}
+	return []string{"Part ID", "Part Number", "Name", "Quantity", "Unit", "Refdes"}
 }
 
-func (i *Instantiator) CounterToCSV(counter map[string]float64) {
+// formatQty drops the rounding noise left over from unit conversions.
+func formatQty(qty float64) string {
+	ret := strconv.FormatFloat(qty, 'f', 6, 64)
+	ret = strings.TrimRight(ret, "0")
+	return strings.TrimSuffix(ret, ".")
+}
+
+// CounterToCSV writes one line per item, sorted by qualifier, with the
+// quantity expressed in the item's unit.
+func (i *Instantiator) CounterToCSV(counter bomtree.Counter) {
 	writer := csv.NewWriter(os.Stdout)
 	writer.Write(getHeader())
-	for name, qty := range counter {
-		line := []string{name,
-			fmt.Sprintf("%.2f", qty),
+	for _, q := range slices.Sorted(maps.Keys(counter)) {
+		entry := counter[q]
+		pn := ""
+		if entry.Item.Content != nil {
+			pn = entry.Item.Content.PartNumber
+		}
+		line := []string{q,
+			pn,
+			entry.Item.GetName(),
+			formatQty(entry.Qty),
+			entry.Unit(),
+			refdes.Format(entry.Refdes),
 		}
 		writer.Write(line)
 	}
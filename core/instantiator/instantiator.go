@@ -2,51 +2,57 @@ package instantiator
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/tychonis/cyanotype/core/bomtree"
 	"github.com/tychonis/cyanotype/core/catalog"
+	"github.com/tychonis/cyanotype/core/process"
 	"github.com/tychonis/cyanotype/core/qualifier"
 	"github.com/tychonis/cyanotype/core/ranker"
 	"github.com/tychonis/cyanotype/internal/digest"
 	"github.com/tychonis/cyanotype/model"
 )
 
+var ErrCycle = errors.New("cyclic bom")
+
 type Instantiator struct {
 	Ranker ranker.Ranker
 }
 
 func New() *Instantiator {
 	return &Instantiator{
-		Ranker: &ranker.NaiveRanker{},
+		Ranker: ranker.NewPriorityRanker(&ranker.NaiveRanker{}),
 	}
 }
 
-func (i *Instantiator) instantiateNode(cat *catalog.Catalog, name string, coitem *model.CoItem, qty float64) (*bomtree.Node, error) {
+func (i *Instantiator) instantiateNode(cat *catalog.Catalog, name string, coitem *model.CoItem, qty float64, unit string) (*bomtree.Node, error) {
 	node := &bomtree.Node{
 		Name:     name,
 		CoItem:   coitem,
 		Children: make([]*bomtree.Node, 0),
 		Qty:      qty,
+		Unit:     unit,
 	}
 	cp, err := cat.GetItemCoProcesses(coitem.Digest)
 	if err != nil {
 		return nil, err
 	}
-	coProcess, err := i.Ranker.TopCoProcess(cp)
+	candidates, err := i.fulfilling(cat, coitem, cp)
+	if err != nil {
+		return nil, err
+	}
+	coProcess, err := i.Ranker.TopCoProcess(candidates)
 	if err != nil {
 		return nil, err
 	}
 	node.CoProcess = coProcess
 
-	itemID := coProcess.Input()[0].Item
-	itemSym, err := cat.Get(itemID)
+	item, err := coProcessItem(cat, coProcess)
 	if err != nil {
 		return nil, err
 	}
-	item, ok := itemSym.(*model.Item)
-	if !ok {
-		return nil, errors.New("invalid input")
-	}
 	node.Item = item
 
 	p, err := cat.GetItemProcesses(item.Digest)
@@ -62,11 +68,86 @@ func (i *Instantiator) instantiateNode(cat *catalog.Catalog, name string, coitem
 	return node, nil
 }
 
-func (i *Instantiator) instantiate(cat *catalog.Catalog, name string, coitem *model.CoItem, qty float64) (*bomtree.Node, error) {
-	node, err := i.instantiateNode(cat, name, coitem, qty)
+// fulfilling keeps the coprocesses leading to coitem whose input item
+// fulfils the contracts required by coitem. Without any, the error lists
+// what each candidate lacks.
+func (i *Instantiator) fulfilling(cat *catalog.Catalog, coitem *model.CoItem, cps []*process.CoProcess) ([]*process.CoProcess, error) {
+	if len(coitem.Require) == 0 {
+		return cps, nil
+	}
+	ret := make([]*process.CoProcess, 0, len(cps))
+	lacks := make([]string, 0, len(cps))
+	for _, cp := range cps {
+		item, err := coProcessItem(cat, cp)
+		if err != nil {
+			return nil, err
+		}
+		missing, err := model.Unfulfilled(item.Implement, coitem.Require, cat.GetContract)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) == 0 {
+			ret = append(ret, cp)
+			continue
+		}
+		contracts := make([]string, 0, len(missing))
+		for _, c := range missing {
+			contracts = append(contracts, c.Qualifier)
+		}
+		lacks = append(lacks, fmt.Sprintf("%s lacks %s", item.GetQualifier(), strings.Join(contracts, ", ")))
+	}
+	if len(ret) == 0 && len(lacks) > 0 {
+		return nil, fmt.Errorf("no item fulfils the contracts required by %s: %s",
+			coitem.GetQualifier(), strings.Join(lacks, "; "))
+	}
+	return ret, nil
+}
+
+func coProcessItem(cat *catalog.Catalog, cp *process.CoProcess) (*model.Item, error) {
+	input := cp.Input()
+	if len(input) == 0 {
+		return nil, errors.New("coprocess has no input")
+	}
+	itemSym, err := cat.Get(input[0].Item)
 	if err != nil {
 		return nil, err
 	}
+	item, ok := itemSym.(*model.Item)
+	if !ok {
+		return nil, errors.New("invalid input")
+	}
+	return item, nil
+}
+
+// checkCycle fails if coitem already appears on the path from the root to
+// parent. Catalogs built by the parser have no cycles, pulled ones might.
+func checkCycle(parent *bomtree.Node, coitem *model.CoItem) error {
+	path := []string{coitem.GetQualifier()}
+	for n := parent; n != nil; n = n.Parent {
+		q := n.CoItem.GetQualifier()
+		if n.Item != nil {
+			q = n.Item.GetQualifier()
+		}
+		path = append(path, q)
+		if n.CoItem.Digest == coitem.Digest {
+			path[0] = q
+			slices.Reverse(path)
+			return fmt.Errorf("%w: %s", ErrCycle, strings.Join(path, " -> "))
+		}
+	}
+	return nil
+}
+
+func (i *Instantiator) instantiate(cat *catalog.Catalog, parent *bomtree.Node, name string, coitem *model.CoItem, qty float64, unit string) (*bomtree.Node, error) {
+	err := checkCycle(parent, coitem)
+	if err != nil {
+		return nil, err
+	}
+	node, err := i.instantiateNode(cat, name, coitem, qty, unit)
+	if err != nil {
+		return nil, err
+	}
+	node.Parent = parent
 	for _, input := range node.Process.Input() {
 		child, err := cat.Get(input.Item)
 		if err != nil {
@@ -76,11 +157,11 @@ func (i *Instantiator) instantiate(cat *catalog.Catalog, name string, coitem *mo
 		if !ok {
 			return nil, errors.New("invalid input")
 		}
-		childNode, err := i.instantiate(cat, input.Name, childCoItem, input.Qty)
+		childNode, err := i.instantiate(cat, node, input.Name, childCoItem, input.Qty, input.Unit)
 		if err != nil {
 			return nil, err
 		}
-		childNode.Parent = node
+		childNode.Refdes = input.Refdes
 		node.Children = append(node.Children, childNode)
 	}
 	node.ID, err = digest.RandomSHA256()
@@ -91,7 +172,7 @@ func (i *Instantiator) instantiate(cat *catalog.Catalog, name string, coitem *mo
 }
 
 func (i *Instantiator) InstantiateTree(cat *catalog.Catalog, name string, coItem *model.CoItem) (*bomtree.Node, error) {
-	return i.instantiate(cat, name, coItem, 1)
+	return i.instantiate(cat, nil, name, coItem, 1, "")
 }
 
 // InstantiateTreeFromItem provides a shortcut. Trees should be instantiated from a coitem.
@@ -109,7 +190,7 @@ func (i *Instantiator) InstantiateTreeFromItem(cat *catalog.Catalog, name string
 }
 
 func (i *Instantiator) ExpandNode(cat *catalog.Catalog, name string, coitem *model.CoItem) (*bomtree.Node, error) {
-	node, err := i.instantiateNode(cat, name, coitem, 1)
+	node, err := i.instantiateNode(cat, name, coitem, 1, "")
 	if err != nil {
 		return nil, err
 	}
@@ -122,11 +203,12 @@ func (i *Instantiator) ExpandNode(cat *catalog.Catalog, name string, coitem *mod
 		if !ok {
 			return nil, errors.New("invalid input")
 		}
-		childNode, err := i.instantiateNode(cat, input.Name, childCoItem, input.Qty)
+		childNode, err := i.instantiateNode(cat, input.Name, childCoItem, input.Qty, input.Unit)
 		if err != nil {
 			return nil, err
 		}
 		childNode.Parent = node
+		childNode.Refdes = input.Refdes
 		node.Children = append(node.Children, childNode)
 	}
 	node.ID, err = digest.RandomSHA256()
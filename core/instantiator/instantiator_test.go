@@ -0,0 +1,175 @@
+package instantiator_test
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tychonis/cyanotype/core/catalog"
+	"github.com/tychonis/cyanotype/core/instantiator"
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+)
+
+// A coprocess can close a loop the parser cannot see: frame needs a slot,
+// which is filled by motor, which is made from frame.
+const cyclicSource = `
+coitem "slot" {}
+
+item "frame" {}
+
+process "frame_build" {
+  input    = [{ ref = slot }]
+  output   = [{ ref = frame }]
+  priority = 1
+}
+
+item "motor" {
+  from = [{ ref = frame }]
+}
+
+coprocess "motor_for_slot" {
+  from = motor
+  to   = slot
+}
+`
+
+func TestInstantiateCycle(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "main.bpo"), []byte(cyclicSource), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := hcl.NewParser()
+	p.Options.NoLock = true
+	err = p.Build(dir)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	cat := catalog.New("memory")
+	err = p.Commit(cat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = instantiator.New().TreeFromQualifier(cat, ".frame")
+	if !errors.Is(err, instantiator.ErrCycle) {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+	want := "cyclic bom: .frame -> .motor -> .frame"
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+}
+
+const unitSource = `
+item "cable" {
+  unit = "m"
+}
+
+item "harness" {
+  from = [
+    { name = "a", ref = cable, qty = "250 mm" },
+    { name = "b", ref = cable, qty = 2 },
+  ]
+}
+`
+
+func TestCountUnits(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "main.bpo"), []byte(unitSource), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := hcl.NewParser()
+	p.Options.NoLock = true
+	err = p.Build(dir)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	cat := catalog.New("memory")
+	err = p.Commit(cat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter, err := instantiator.New().Count(cat, ".harness")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := counter[".cable"]
+	if entry == nil || entry.Qty != 2.25 || entry.Unit() != "m" {
+		t.Errorf("unexpected cable count: %+v", entry)
+	}
+}
+
+// Commit rejects coprocesses to coitems their item does not fulfil, pulled
+// catalogs may still hold them.
+const contractSource = `
+contract "m3" {
+  thread = "M3"
+}
+
+contract "steel" {
+  material = "steel"
+}
+
+item "screw" {
+  impl = [steel]
+}
+
+item "bolt" {}
+
+coitem "fastener" {
+  req = [m3, steel]
+}
+
+coprocess "screw_for_fastener" {
+  from = screw
+  to   = fastener
+}
+
+coprocess "bolt_for_fastener" {
+  from = bolt
+  to   = fastener
+}
+`
+
+func TestInstantiateUnfulfilled(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "main.bpo"), []byte(contractSource), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := hcl.NewParser()
+	p.Options.NoLock = true
+	err = p.Build(dir)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	cat := catalog.New("memory")
+	rev := cat.NewRevision()
+	for _, q := range slices.Sorted(maps.Keys(p.Symbols.QualifierIndex)) {
+		err = cat.Add(rev, p.Symbols.ConcreteSymbols[p.Symbols.QualifierIndex[q]])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = cat.Commit(rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = instantiator.New().TreeFromQualifier(cat, ".fastener")
+	if err == nil {
+		t.Fatal("expected no candidate to fulfil .fastener")
+	}
+	for _, want := range []string{".bolt lacks .m3, .steel", ".screw lacks .m3"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should report %q: %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/tychonis/cyanotype/internal/cerror"
+)
+
+const CONFIG string = "cyanotype-lint.hcl"
+
+const (
+	WARNING = "warning"
+	ERROR   = "error"
+)
+
+// Config selects the rules to run and how severe their findings are:
+//
+//	roots = [".cart"]
+//
+//	rule "missing-part-number" {
+//	  severity = "error"
+//	}
+//
+//	rule "unreachable-item" {
+//	  enabled = false
+//	}
+//
+// Every rule is enabled as a warning unless configured otherwise.
+type Config struct {
+	// Roots are the qualifiers of the top level assemblies, for the
+	// unreachable-item rule.
+	Roots []string
+	Rules map[string]*RuleConfig
+}
+
+type RuleConfig struct {
+	Enabled  bool
+	Severity string
+}
+
+func DefaultConfig() *Config {
+	ret := &Config{
+		Roots: make([]string, 0),
+		Rules: make(map[string]*RuleConfig),
+	}
+	for _, rule := range RULES {
+		ret.Rules[rule.Name] = &RuleConfig{Enabled: true, Severity: WARNING}
+	}
+	return ret
+}
+
+// LoadConfig reads the config at path over the defaults. A missing file
+// leaves the defaults.
+func LoadConfig(path string) (*Config, error) {
+	ret := DefaultConfig()
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return ret, nil
+	}
+	file, diags := hclparse.NewParser().ParseHCLFile(path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, errors.New("failed to parse lint config")
+	}
+	for name, attr := range body.Attributes {
+		if name != "roots" {
+			return nil, cerror.ErrorWithRange("unknown lint attribute "+name, attr.NameRange)
+		}
+		roots, err := stringList(attr)
+		if err != nil {
+			return nil, err
+		}
+		ret.Roots = roots
+	}
+	for _, block := range body.Blocks {
+		err := ret.readRule(block)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (c *Config) readRule(block *hclsyntax.Block) error {
+	if block.Type != "rule" || len(block.Labels) != 1 {
+		return cerror.ErrorWithRange("expected a rule block with one label", block.DefRange())
+	}
+	rule, ok := c.Rules[block.Labels[0]]
+	if !ok {
+		return cerror.ErrorWithRange("unknown rule "+block.Labels[0], block.LabelRanges[0])
+	}
+	for name, attr := range block.Body.Attributes {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return diags
+		}
+		switch {
+		case name == "enabled" && val.Type() == cty.Bool && !val.IsNull():
+			rule.Enabled = val.True()
+		case name == "severity" && val.Type() == cty.String && !val.IsNull():
+			rule.Severity = val.AsString()
+			if rule.Severity != WARNING && rule.Severity != ERROR {
+				return cerror.ErrorWithRange(fmt.Sprintf("severity must be %s or %s", WARNING, ERROR), attr.Expr.Range())
+			}
+		case name == "enabled" || name == "severity":
+			return cerror.ErrorWithRange("incorrect type for "+name, attr.Expr.Range())
+		default:
+			return cerror.ErrorWithRange("unknown rule attribute "+name, attr.NameRange)
+		}
+	}
+	return nil
+}
+
+func stringList(attr *hclsyntax.Attribute) ([]string, error) {
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	if val.IsNull() || !(val.Type().IsTupleType() || val.Type().IsListType()) {
+		return nil, cerror.ErrorWithRange(attr.Name+" must be a list of strings", attr.Expr.Range())
+	}
+	ret := make([]string, 0, val.LengthInt())
+	for _, elem := range val.AsValueSlice() {
+		if elem.Type() != cty.String || elem.IsNull() {
+			return nil, cerror.ErrorWithRange(attr.Name+" must be a list of strings", attr.Expr.Range())
+		}
+		ret = append(ret, elem.AsString())
+	}
+	return ret, nil
+}
+
+// enabled lists the enabled rules, in the order of RULES.
+func (c *Config) enabled() []*Rule {
+	return slices.DeleteFunc(slices.Clone(RULES), func(r *Rule) bool {
+		return !c.Rules[r.Name].Enabled
+	})
+}
@@ -0,0 +1,181 @@
+package lint
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/tychonis/cyanotype/core/catalog"
+	parser "github.com/tychonis/cyanotype/core/parser/hcl"
+	"github.com/tychonis/cyanotype/core/process"
+	"github.com/tychonis/cyanotype/core/qualifier"
+	"github.com/tychonis/cyanotype/model"
+)
+
+// Finding is a problem reported by a rule, at the definition of the symbol
+// concerned when it is known.
+type Finding struct {
+	Rule     string
+	Severity string
+	Message  string
+	Range    *hcl.Range
+}
+
+type Rule struct {
+	Name        string
+	Description string
+	check       func(l *linter) []*Finding
+}
+
+// linter holds what rules look at: a successful build and, optionally, the
+// latest revision of a catalog.
+type linter struct {
+	parser  *parser.Parser
+	config  *Config
+	symbols []model.ConcreteSymbol
+	// catalog is nil unless a catalog was given.
+	catalog map[model.Qualifier]model.ConcreteSymbol
+}
+
+// Run checks the symbols built by p with the rules enabled in config. cat
+// may be nil.
+func Run(p *parser.Parser, cat *catalog.Catalog, config *Config) ([]*Finding, error) {
+	l := &linter{
+		parser:  p,
+		config:  config,
+		symbols: make([]model.ConcreteSymbol, 0, len(p.Symbols.QualifierIndex)),
+	}
+	for _, q := range slices.Sorted(maps.Keys(p.Symbols.QualifierIndex)) {
+		l.symbols = append(l.symbols, p.Symbols.ConcreteSymbols[p.Symbols.QualifierIndex[q]])
+	}
+	if cat != nil {
+		latest, err := cat.GetLatestRevision()
+		if err != nil {
+			return nil, err
+		}
+		l.catalog = make(map[model.Qualifier]model.ConcreteSymbol)
+		if latest != nil {
+			l.catalog, err = cat.GetSymbolsAt(latest.Digest)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ret := make([]*Finding, 0)
+	for _, rule := range config.enabled() {
+		for _, f := range rule.check(l) {
+			f.Rule = rule.Name
+			f.Severity = config.Rules[rule.Name].Severity
+			ret = append(ret, f)
+		}
+	}
+	slices.SortStableFunc(ret, compareFindings)
+	return ret, nil
+}
+
+// compareFindings orders findings by file and position, then by rule.
+func compareFindings(a, b *Finding) int {
+	switch {
+	case a.Range == nil || b.Range == nil:
+		if c := cmp.Compare(btoi(a.Range != nil), btoi(b.Range != nil)); c != 0 {
+			return c
+		}
+	case a.Range.Filename != b.Range.Filename:
+		return strings.Compare(a.Range.Filename, b.Range.Filename)
+	case a.Range.Start.Byte != b.Range.Start.Byte:
+		return cmp.Compare(a.Range.Start.Byte, b.Range.Start.Byte)
+	}
+	return strings.Compare(a.Rule, b.Rule)
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// HasErrors reports whether any finding has the error severity.
+func HasErrors(findings []*Finding) bool {
+	return slices.ContainsFunc(findings, func(f *Finding) bool {
+		return f.Severity == ERROR
+	})
+}
+
+// Diagnostics converts findings for printing with the diagnostics of a
+// build.
+func Diagnostics(findings []*Finding) hcl.Diagnostics {
+	ret := make(hcl.Diagnostics, 0, len(findings))
+	for _, f := range findings {
+		severity := hcl.DiagWarning
+		if f.Severity == ERROR {
+			severity = hcl.DiagError
+		}
+		ret = append(ret, &hcl.Diagnostic{
+			Severity: severity,
+			Summary:  f.Message,
+			Detail:   "Reported by rule " + f.Rule + ".",
+			Subject:  f.Range,
+		})
+	}
+	return ret
+}
+
+// definition returns where the symbol named q is written. Companions are
+// written as part of their item.
+func (l *linter) definition(q model.Qualifier) *hcl.Range {
+	d, ok := l.parser.Symbols.QualifierIndex[qualifier.Owner(q)]
+	if !ok {
+		return nil
+	}
+	r, ok := l.parser.Definition(l.parser.Symbols.ConcreteSymbols[d])
+	if !ok {
+		return nil
+	}
+	return &r
+}
+
+func (l *linter) finding(q model.Qualifier, msg string) *Finding {
+	return &Finding{Message: msg, Range: l.definition(q)}
+}
+
+// inRoot reports whether the symbol named q is written in the root module,
+// rather than in an import.
+func (l *linter) inRoot(q model.Qualifier) bool {
+	r := l.definition(q)
+	return r != nil && slices.Contains(l.parser.ModuleFiles("."), r.Filename)
+}
+
+// processes returns the processes and coprocesses built, by the digest of
+// their output.
+func (l *linter) processes() (map[model.Digest][]*process.Process, map[model.Digest][]*process.CoProcess) {
+	producers := make(map[model.Digest][]*process.Process)
+	fillers := make(map[model.Digest][]*process.CoProcess)
+	for _, sym := range l.symbols {
+		switch s := sym.(type) {
+		case *process.Process:
+			for _, line := range s.Output() {
+				producers[line.Item] = append(producers[line.Item], s)
+			}
+		case *process.CoProcess:
+			for _, line := range s.Output() {
+				fillers[line.Item] = append(fillers[line.Item], s)
+			}
+		}
+	}
+	return producers, fillers
+}
+
+// name returns the qualifier of the symbol with digest d, companions being
+// named after their item.
+func (l *linter) name(d model.Digest) string {
+	sym, ok := l.parser.Symbols.ConcreteSymbols[d]
+	if !ok {
+		return d
+	}
+	return qualifier.Owner(sym.GetQualifier())
+}
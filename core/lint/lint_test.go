@@ -0,0 +1,283 @@
+package lint_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tychonis/cyanotype/core/catalog"
+	"github.com/tychonis/cyanotype/core/lint"
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+)
+
+const lintSource = `
+contract "drive" {
+  voltage = 24
+}
+
+item "motor" {
+  part_number = "MT-1"
+  impl        = [drive]
+
+  artifact "spec" {
+    filename = "spec.pdf"
+    tag      = "spec"
+    source   = "digest:abc"
+  }
+}
+
+coitem "any_motor" {
+  req = [drive]
+}
+
+coprocess "motor_for_any" {
+  from = motor
+  to   = any_motor
+}
+
+item "bolt" {
+  part_number = "MT-1"
+}
+
+item "wheel" {}
+
+item "spare" {
+  part_number = "SP-1"
+}
+
+item "cart" {
+  part_number = "C-1"
+  from = [
+    { ref = wheel, placement = [0, 0, 0, 1, 0, 0, 0] },
+    { ref = bolt },
+  ]
+}
+
+process "cart_motor" {
+  input  = [{ ref = any_motor, qty = 0 }]
+  output = [{ ref = cart }]
+}
+`
+
+const lintConfig = `
+roots = [".cart"]
+
+rule "missing-part-number" {
+  severity = "error"
+}
+`
+
+func build(t *testing.T, files map[string]string) (*hcl.Parser, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := hcl.NewParser()
+	p.Options.NoLock = true
+	err := p.Build(dir)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	return p, dir
+}
+
+type result struct {
+	rule     string
+	severity string
+	line     int
+}
+
+func results(findings []*lint.Finding) []result {
+	ret := make([]result, 0, len(findings))
+	for _, f := range findings {
+		line := 0
+		if f.Range != nil {
+			line = f.Range.Start.Line
+		}
+		ret = append(ret, result{f.Rule, f.Severity, line})
+	}
+	return ret
+}
+
+func TestRun(t *testing.T) {
+	p, dir := build(t, map[string]string{
+		"main.bpo":  lintSource,
+		"spec.pdf":  "hello",
+		lint.CONFIG: lintConfig,
+	})
+	cfg, err := lint.LoadConfig(filepath.Join(dir, lint.CONFIG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings, err := lint.Run(p, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []result{
+		{"artifact-drift", "warning", 6},
+		{"duplicate-part-number", "warning", 6},
+		{"single-implementation-req", "warning", 17},
+		{"duplicate-part-number", "warning", 26},
+		{"missing-part-number", "error", 30},
+		{"unreachable-item", "warning", 32},
+		{"unplaced-component", "warning", 40},
+		{"non-positive-qty", "warning", 44},
+	}
+	got := results(findings)
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("finding %d: got %v, want %v (%s)", i, got[i], want[i], findings[i].Message)
+		}
+	}
+	if !lint.HasErrors(findings) {
+		t.Error("expected an error finding")
+	}
+}
+
+func TestRunWithCatalog(t *testing.T) {
+	cat := catalog.New("memory")
+	old, _ := build(t, map[string]string{"main.bpo": `
+item "gear" {
+  part_number = "G-1"
+}
+`})
+	err := old.Commit(cat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, _ := build(t, map[string]string{"main.bpo": `
+item "pinion" {
+  part_number = "G-1"
+}
+`})
+	cfg := lint.DefaultConfig()
+	findings, err := lint.Run(p, nil, cfg)
+	if err != nil || len(findings) != 0 {
+		t.Fatalf("unexpected findings without catalog: %v %v", results(findings), err)
+	}
+	findings, err = lint.Run(p, cat, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].Message != "part number G-1 of .pinion is also used by .gear" {
+		t.Fatalf("unexpected findings: %v", results(findings))
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, lint.CONFIG)
+	cfg, err := lint.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range lint.RULES {
+		if !cfg.Rules[rule.Name].Enabled || cfg.Rules[rule.Name].Severity != lint.WARNING {
+			t.Errorf("rule %s should default to an enabled warning", rule.Name)
+		}
+	}
+
+	for _, tc := range []struct {
+		src string
+		err string
+	}{
+		{`rule "nope" {}`, "unknown rule nope"},
+		{`rule "artifact-drift" { severity = "fatal" }`, "severity must be"},
+		{`rule "artifact-drift" { enabled = "no" }`, "incorrect type for enabled"},
+		{`roots = ".cart"`, "roots must be a list of strings"},
+	} {
+		err := os.WriteFile(path, []byte(tc.src), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = lint.LoadConfig(path)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: expected %q, got %v", tc.src, tc.err, err)
+		}
+	}
+
+	err = os.WriteFile(path, []byte(`rule "unplaced-component" { enabled = false }`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = lint.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := build(t, map[string]string{"main.bpo": `
+item "wheel" {
+  part_number = "W-1"
+}
+
+item "cart" {
+  part_number = "C-1"
+  from = [
+    { ref = wheel, placement = [0, 0, 0, 1, 0, 0, 0] },
+    { ref = wheel },
+  ]
+}
+`})
+	findings, err := lint.Run(p, nil, cfg)
+	if err != nil || len(findings) != 0 {
+		t.Errorf("disabled rule still reported: %v %v", results(findings), err)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	p, _ := build(t, map[string]string{"main.bpo": `item "wheel" {}`})
+	findings, err := lint.Run(p, nil, lint.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = lint.WriteSARIF(&buf, findings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID    string
+				Level     string
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine int }
+					}
+				}
+			}
+		}
+	}
+	err = json.Unmarshal(buf.Bytes(), &log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Tool.Driver.Rules) != len(lint.RULES) {
+		t.Fatalf("unexpected log: %s", buf.String())
+	}
+	results := log.Runs[0].Results
+	if len(results) != 1 || results[0].RuleID != "missing-part-number" || results[0].Level != "warning" {
+		t.Fatalf("unexpected results: %s", buf.String())
+	}
+	loc := results[0].Locations[0].PhysicalLocation
+	if !strings.HasSuffix(loc.ArtifactLocation.URI, "/main.bpo") || loc.Region.StartLine != 1 {
+		t.Errorf("unexpected location: %+v", loc)
+	}
+}
@@ -0,0 +1,249 @@
+package lint
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tychonis/cyanotype/core/process"
+	"github.com/tychonis/cyanotype/core/qualifier"
+	"github.com/tychonis/cyanotype/internal/digest"
+	"github.com/tychonis/cyanotype/model"
+)
+
+// RULES lists every rule, in the order they run.
+var RULES = []*Rule{
+	{
+		Name:        "missing-part-number",
+		Description: "Items should have a part_number.",
+		check:       missingPartNumber,
+	},
+	{
+		Name:        "duplicate-part-number",
+		Description: "A part number should belong to a single item, in the build and in the catalog.",
+		check:       duplicatePartNumber,
+	},
+	{
+		Name:        "non-positive-qty",
+		Description: "BOM lines should have a positive qty.",
+		check:       nonPositiveQty,
+	},
+	{
+		Name:        "unplaced-component",
+		Description: "Every component of a drawing should have a placement.",
+		check:       unplacedComponent,
+	},
+	{
+		Name:        "unreachable-item",
+		Description: "Items of the root module should be used by one of the configured roots.",
+		check:       unreachableItem,
+	},
+	{
+		Name:        "single-implementation-req",
+		Description: "Coitems declaring req should have more than one implementation.",
+		check:       singleImplementationReq,
+	},
+	{
+		Name:        "artifact-drift",
+		Description: "Artifacts pinned by digest should match their local file.",
+		check:       artifactDrift,
+	},
+}
+
+func (l *linter) items() []*model.Item {
+	ret := make([]*model.Item, 0)
+	for _, sym := range l.symbols {
+		if item, ok := sym.(*model.Item); ok {
+			ret = append(ret, item)
+		}
+	}
+	return ret
+}
+
+func missingPartNumber(l *linter) []*Finding {
+	ret := make([]*Finding, 0)
+	for _, item := range l.items() {
+		if item.Content == nil || item.Content.PartNumber == "" {
+			ret = append(ret, l.finding(item.Qualifier, fmt.Sprintf("item %s has no part_number", item.Qualifier)))
+		}
+	}
+	return ret
+}
+
+func duplicatePartNumber(l *linter) []*Finding {
+	owners := make(map[string][]model.Qualifier)
+	for _, item := range l.items() {
+		if item.Content != nil && item.Content.PartNumber != "" {
+			owners[item.Content.PartNumber] = append(owners[item.Content.PartNumber], item.Qualifier)
+		}
+	}
+	// Items left out of the build keep their part number in the catalog.
+	for q, sym := range l.catalog {
+		item, ok := sym.(*model.Item)
+		if !ok || item.Content == nil || item.Content.PartNumber == "" {
+			continue
+		}
+		if _, ok := l.parser.Symbols.QualifierIndex[q]; !ok {
+			owners[item.Content.PartNumber] = append(owners[item.Content.PartNumber], q)
+		}
+	}
+
+	ret := make([]*Finding, 0)
+	for _, item := range l.items() {
+		if item.Content == nil {
+			continue
+		}
+		others := slices.DeleteFunc(slices.Clone(owners[item.Content.PartNumber]), func(q model.Qualifier) bool {
+			return q == item.Qualifier
+		})
+		if len(others) == 0 {
+			continue
+		}
+		slices.Sort(others)
+		ret = append(ret, l.finding(item.Qualifier, fmt.Sprintf("part number %s of %s is also used by %s",
+			item.Content.PartNumber, item.Qualifier, strings.Join(others, ", "))))
+	}
+	return ret
+}
+
+func nonPositiveQty(l *linter) []*Finding {
+	ret := make([]*Finding, 0)
+	for _, sym := range l.symbols {
+		var lines []*model.BOMLine
+		switch s := sym.(type) {
+		case *process.Process:
+			lines = slices.Concat(s.Input(), s.Output())
+		case *process.CoProcess:
+			lines = slices.Concat(s.Input(), s.Output())
+		}
+		for _, line := range lines {
+			if line.Qty > 0 {
+				continue
+			}
+			owner := qualifier.Owner(sym.GetQualifier())
+			ret = append(ret, l.finding(owner, fmt.Sprintf("%s has qty %g of %s", owner, line.Qty, l.name(line.Item))))
+		}
+	}
+	return ret
+}
+
+func unplacedComponent(l *linter) []*Finding {
+	ret := make([]*Finding, 0)
+	for _, r := range l.parser.Unplaced() {
+		ret = append(ret, &Finding{
+			Message: "component of a drawing has no placement, it is placed at the origin",
+			Range:   &r,
+		})
+	}
+	return ret
+}
+
+// unreachableItem walks from the roots through the processes making each
+// item and the coprocesses filling each coitem.
+func unreachableItem(l *linter) []*Finding {
+	if len(l.config.Roots) == 0 {
+		return nil
+	}
+	ret := make([]*Finding, 0)
+	producers, fillers := l.processes()
+	reached := make(map[model.Digest]bool)
+	var visit func(d model.Digest)
+	visit = func(d model.Digest) {
+		if reached[d] {
+			return
+		}
+		reached[d] = true
+		for _, p := range producers[d] {
+			for _, line := range p.Input() {
+				visit(line.Item)
+			}
+		}
+		for _, cp := range fillers[d] {
+			for _, line := range cp.Input() {
+				visit(line.Item)
+			}
+		}
+	}
+	for _, root := range l.config.Roots {
+		d, ok := l.parser.Symbols.QualifierIndex[root]
+		if !ok {
+			ret = append(ret, &Finding{Message: fmt.Sprintf("root %s is not defined", root)})
+			continue
+		}
+		visit(d)
+	}
+
+	for _, item := range l.items() {
+		if !reached[item.Digest] && l.inRoot(item.Qualifier) {
+			ret = append(ret, l.finding(item.Qualifier, fmt.Sprintf("item %s is not used by any root", item.Qualifier)))
+		}
+	}
+	return ret
+}
+
+func singleImplementationReq(l *linter) []*Finding {
+	ret := make([]*Finding, 0)
+	_, fillers := l.processes()
+	for _, sym := range l.symbols {
+		coItem, ok := sym.(*model.CoItem)
+		if !ok || len(coItem.Require) == 0 || qualifier.IsImplicit(coItem.Qualifier) {
+			continue
+		}
+		cps := fillers[coItem.Digest]
+		if len(cps) != 1 {
+			continue
+		}
+		impl := "nothing"
+		if input := cps[0].Input(); len(input) > 0 {
+			impl = l.name(input[0].Item)
+		}
+		ret = append(ret, l.finding(coItem.Qualifier, fmt.Sprintf("coitem %s declares req but has a single implementation, %s",
+			coItem.Qualifier, impl)))
+	}
+	return ret
+}
+
+// artifactDrift compares artifacts with a digest source to the file named
+// by their filename, relative to the source file of their symbol.
+func artifactDrift(l *linter) []*Finding {
+	ret := make([]*Finding, 0)
+	for _, sym := range l.symbols {
+		var content *model.ItemContent
+		switch s := sym.(type) {
+		case *model.Item:
+			content = s.Content
+		case *model.CoItem:
+			content = s.Content
+		}
+		r := l.definition(sym.GetQualifier())
+		if content == nil || r == nil || qualifier.IsImplicit(sym.GetQualifier()) {
+			continue
+		}
+		for _, a := range content.Artifacts {
+			source, err := url.Parse(a.Source)
+			if err != nil || source.Scheme != "digest" || a.Filename == "" {
+				continue
+			}
+			path := a.Filename
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(r.Filename), path)
+			}
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			d, err := digest.SHA256FromFile(path)
+			if err != nil {
+				ret = append(ret, l.finding(sym.GetQualifier(), fmt.Sprintf("artifact %s of %s: %v", a.Name, sym.GetQualifier(), err)))
+				continue
+			}
+			if d != a.Digest {
+				ret = append(ret, l.finding(sym.GetQualifier(), fmt.Sprintf("artifact %s of %s: %s has drifted from digest %s",
+					a.Name, sym.GetQualifier(), a.Filename, a.Digest)))
+			}
+		}
+	}
+	return ret
+}
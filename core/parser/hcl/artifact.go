@@ -1,11 +1,20 @@
 package hcl
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/url"
+	"os"
+	"path/filepath"
 
 	"github.com/hashicorp/hcl/v2/hclsyntax"
-	"github.com/tychonis/cyanotype/internal/digest"
+
+	"github.com/tychonis/cyanotype/core/artifact"
+	"github.com/tychonis/cyanotype/core/catalog"
+	"github.com/tychonis/cyanotype/internal/cerror"
 	"github.com/tychonis/cyanotype/model"
 )
 
@@ -13,6 +22,9 @@ func (p *Parser) ParseArtifacts(ctx *ParserContext, block *hclsyntax.Block) ([]*
 	if block == nil {
 		return nil, fmt.Errorf("block is nil")
 	}
+	if p.Options.IgnoreArtifacts {
+		return nil, nil
+	}
 
 	var artifacts []*model.Artifact
 
@@ -32,24 +44,75 @@ func (p *Parser) ParseArtifacts(ctx *ParserContext, block *hclsyntax.Block) ([]*
 	return artifacts, nil
 }
 
+// localArtifactPath returns the path of a file:// source.
+func localArtifactPath(source string) (string, bool) {
+	parsed, err := url.Parse(source)
+	if err != nil || parsed.Scheme != "file" {
+		return "", false
+	}
+	return parsed.Host + parsed.Path, true
+}
+
+// digestArtifact reports whether source only names its content by digest,
+// as in digest:<sha256>, with nothing to read it from.
+func digestArtifact(source string) bool {
+	parsed, err := url.Parse(source)
+	return err == nil && parsed.Scheme == "digest"
+}
+
+// resolveArtifact sets the digest of an artifact. Local directories and
+// globs are described by a manifest of their files.
+func (p *Parser) resolveArtifact(ctx *ParserContext, a *model.Artifact) error {
+	local, ok := localArtifactPath(a.Source)
+	if !ok || !artifact.IsManifest(local) {
+		var err error
+		a.Digest, err = p.getDigest(ctx, a.Source)
+		return err
+	}
+	manifest, err := artifact.BuildManifest(local)
+	if err != nil {
+		return err
+	}
+	a.Files = manifest.Files
+	a.Digest, err = manifest.Digest()
+	return err
+}
+
 func (p *Parser) getDigest(_ *ParserContext, source string) (string, error) {
+	// Remote sources, such as git://host/repo@commit:path, are not always
+	// valid URLs.
+	if scheme := artifact.Scheme(source); scheme != "" && scheme != "file" {
+		return p.Artifacts().Digest(source)
+	}
 	parsed, err := url.Parse(source)
 	if err != nil {
 		return "", err
 	}
 	switch parsed.Scheme {
 	case "file":
-		filepath := parsed.Host + parsed.Path
-		return digest.SHA256FromFile(filepath)
+		return p.fileDigest(parsed.Host + parsed.Path)
 	case "digest":
 		return parsed.Opaque, nil
-	case "s3":
-		return "", fmt.Errorf("s3 scheme not supported yet")
 	default:
 		return "", fmt.Errorf("scheme not supported: %s", parsed.Scheme)
 	}
 }
 
+// Artifacts returns the cache of remote artifacts.
+func (p *Parser) Artifacts() *artifact.Cache {
+	if p.artifacts != nil {
+		return p.artifacts
+	}
+	dir := p.Options.ArtifactCache
+	if dir == "" {
+		if stat, err := os.Stat(".bpc"); err == nil && stat.IsDir() {
+			dir = filepath.Join(".bpc", "artifacts")
+		}
+	}
+	p.artifacts = artifact.NewCache(dir)
+	return p.artifacts
+}
+
 func (p *Parser) parseArtifactBlock(ctx *ParserContext, block *hclsyntax.Block) (*model.Artifact, error) {
 	if len(block.Labels) != 1 {
 		return nil, fmt.Errorf(
@@ -67,24 +130,144 @@ func (p *Parser) parseArtifactBlock(ctx *ParserContext, block *hclsyntax.Block)
 		return nil, diags
 	}
 
-	filename, err := getString(attrs, "filename")
+	filename, err := getString(ctx, attrs, "filename")
 	if err != nil {
 		return nil, err
 	}
 	artifact.Filename = filename
-	tag, err := getString(attrs, "tag")
+	tag, err := getString(ctx, attrs, "tag")
 	if err != nil {
 		return nil, err
 	}
 	artifact.Tag = tag
-	source, err := getString(attrs, "source")
+	source, err := getString(ctx, attrs, "source")
 	if err != nil {
 		return nil, err
 	}
 	artifact.Source = source
-	artifact.Digest, err = p.getDigest(ctx, source)
+	err = p.resolveArtifact(ctx, artifact)
 	if err != nil {
-		return nil, err
+		return nil, cerror.ErrorWithRange("source: "+err.Error(), attrs["source"].Expr.Range())
 	}
 	return artifact, nil
 }
+
+// openArtifact returns the content of an artifact. Artifacts known only by
+// digest have none.
+func (p *Parser) openArtifact(a *model.Artifact) (io.ReadCloser, error) {
+	if scheme := artifact.Scheme(a.Source); scheme != "" && scheme != "file" {
+		return p.Artifacts().Open(a.Source)
+	}
+	parsed, err := url.Parse(a.Source)
+	if err != nil {
+		return nil, err
+	}
+	switch parsed.Scheme {
+	case "file":
+		return os.Open(parsed.Host + parsed.Path)
+	case "digest":
+		return nil, catalog.ErrNoArtifact
+	default:
+		return nil, fmt.Errorf("scheme not supported: %s", parsed.Scheme)
+	}
+}
+
+func artifactsOf(sym model.ConcreteSymbol) []*model.Artifact {
+	switch s := sym.(type) {
+	case *model.Item:
+		if s.Content != nil {
+			return s.Content.Artifacts
+		}
+	case *model.CoItem:
+		if s.Content != nil {
+			return s.Content.Artifacts
+		}
+	}
+	return nil
+}
+
+// reportArtifactChanges logs the files changed in the directory and glob
+// artifacts of a symbol.
+func reportArtifactChanges(old model.ConcreteSymbol, new model.ConcreteSymbol) {
+	before := make(map[string]*model.Artifact)
+	for _, a := range artifactsOf(old) {
+		before[a.Name] = a
+	}
+	for _, a := range artifactsOf(new) {
+		prev, ok := before[a.Name]
+		if !ok || prev.Digest == a.Digest || (len(prev.Files) == 0 && len(a.Files) == 0) {
+			continue
+		}
+		for _, change := range artifact.DiffFiles(prev.Files, a.Files) {
+			slog.Info("Artifact file "+change.Change,
+				"qualifier", new.GetQualifier(), "artifact", a.Name, "path", change.Path)
+		}
+	}
+}
+
+// storeArtifacts copies the content of the artifacts of sym into the
+// catalog, unless it is there already.
+func (p *Parser) storeArtifacts(cat *catalog.Catalog, sym model.ConcreteSymbol) error {
+	for _, a := range artifactsOf(sym) {
+		if len(a.Files) > 0 {
+			err := p.storeManifest(cat, a)
+			if err != nil {
+				return fmt.Errorf("%s artifact %s: %w", sym.GetQualifier(), a.Name, err)
+			}
+			continue
+		}
+		if digestArtifact(a.Source) {
+			continue
+		}
+		ok, err := cat.HasArtifact(a.Digest)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		r, err := p.openArtifact(a)
+		if errors.Is(err, catalog.ErrNoArtifact) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s artifact %s: %w", sym.GetQualifier(), a.Name, err)
+		}
+		err = cat.SaveArtifact(a.Digest, r)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("%s artifact %s: %w", sym.GetQualifier(), a.Name, err)
+		}
+	}
+	return nil
+}
+
+// storeManifest stores every file of a directory or glob artifact, then
+// the manifest as the content of the artifact.
+func (p *Parser) storeManifest(cat *catalog.Catalog, a *model.Artifact) error {
+	local, ok := localArtifactPath(a.Source)
+	if !ok {
+		return fmt.Errorf("manifest of %s cannot be read", a.Source)
+	}
+	base := artifact.ManifestBase(local)
+	for _, file := range a.Files {
+		path, err := artifact.LocalPath(base, file)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = cat.SaveArtifact(file.Digest, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Path, err)
+		}
+	}
+	data, err := (&artifact.Manifest{Files: a.Files}).Encode()
+	if err != nil {
+		return err
+	}
+	return cat.SaveArtifact(a.Digest, bytes.NewReader(data))
+}
@@ -0,0 +1,212 @@
+package hcl
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/tychonis/cyanotype/internal/cerror"
+	"github.com/tychonis/cyanotype/internal/stable"
+	"github.com/tychonis/cyanotype/model"
+)
+
+// AttributeRef is a reference into the attributes of a symbol not parsed
+// yet, e.g. `deck.part_number`.
+type AttributeRef struct {
+	Symbol *UnprocessedSymbol
+	Path   []string
+}
+
+func (r *AttributeRef) Resolve(path []string) (model.Symbol, error) {
+	return &AttributeRef{Symbol: r.Symbol, Path: append(r.Path[:len(r.Path):len(r.Path)], path...)}, nil
+}
+
+// goToCty is the inverse of ctyToGo.
+func goToCty(val any) (cty.Value, error) {
+	switch v := val.(type) {
+	case nil:
+		return cty.NullVal(cty.DynamicPseudoType), nil
+	case string:
+		return cty.StringVal(v), nil
+	case float64:
+		return cty.NumberFloatVal(v), nil
+	case int:
+		return cty.NumberIntVal(int64(v)), nil
+	case bool:
+		return cty.BoolVal(v), nil
+	case []any:
+		if len(v) == 0 {
+			return cty.EmptyTupleVal, nil
+		}
+		elems := make([]cty.Value, 0, len(v))
+		for _, elem := range v {
+			converted, err := goToCty(elem)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elems = append(elems, converted)
+		}
+		return cty.TupleVal(elems), nil
+	case stable.Map:
+		if len(v) == 0 {
+			return cty.EmptyObjectVal, nil
+		}
+		attrs := make(map[string]cty.Value, len(v))
+		for key, elem := range v {
+			converted, err := goToCty(elem)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			attrs[key] = converted
+		}
+		return cty.ObjectVal(attrs), nil
+	default:
+		return cty.NilVal, fmt.Errorf("unsupported value of type %T", val)
+	}
+}
+
+// attrTree collects the symbols referenced by a block under the names they
+// are referenced with, e.g. `mod.deck` or `bolt["M3"]`.
+type attrTree struct {
+	value    *cty.Value
+	children map[string]*attrTree
+}
+
+func (t *attrTree) insert(names []string, val cty.Value) {
+	node := t
+	for _, name := range names {
+		if node.value != nil {
+			return
+		}
+		if node.children == nil {
+			node.children = make(map[string]*attrTree)
+		}
+		child, ok := node.children[name]
+		if !ok {
+			child = &attrTree{}
+			node.children[name] = child
+		}
+		node = child
+	}
+	node.value = &val
+	node.children = nil
+}
+
+func (t *attrTree) toCty() cty.Value {
+	if t.value != nil {
+		return *t.value
+	}
+	attrs := make(map[string]cty.Value, len(t.children))
+	for name, child := range t.children {
+		attrs[name] = child.toCty()
+	}
+	return cty.ObjectVal(attrs)
+}
+
+// traverserKey names a step of a traversal as an object attribute. Instance
+// keys lose their brackets, so `bolt["M3"]` and `bolt[0]` index objects.
+func traverserKey(t hcl.Traverser) string {
+	switch t := t.(type) {
+	case hcl.TraverseRoot:
+		return t.Name
+	case hcl.TraverseAttr:
+		return t.Name
+	case hcl.TraverseIndex:
+		key, _ := pathKeyOf(t.Key)
+		return key
+	default:
+		return ""
+	}
+}
+
+func pathKeyOf(key cty.Value) (string, bool) {
+	if key.IsNull() || !key.IsKnown() {
+		return "", false
+	}
+	switch key.Type() {
+	case cty.String:
+		return key.AsString(), true
+	case cty.Number:
+		return key.AsBigFloat().Text('f', -1), true
+	default:
+		return "", false
+	}
+}
+
+// isEvalVariable reports whether name is defined by the evaluation context,
+// such as var, local, each or count.
+func isEvalVariable(eval *hcl.EvalContext, name string) bool {
+	for ; eval != nil; eval = eval.Parent() {
+		if _, ok := eval.Variables[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// symbolContext returns the context a block is parsed in, with the
+// attributes of the symbols it references available to expressions. Plain
+// references such as `ref = bolt` are left to the block parsers.
+func (p *Parser) symbolContext(s *UnprocessedSymbol) (*ParserContext, error) {
+	tree := &attrTree{}
+	var err error
+	hclsyntax.VisitAll(s.Block.Body, func(node hclsyntax.Node) hcl.Diagnostics {
+		expr, ok := node.(*hclsyntax.ScopeTraversalExpr)
+		if !ok || err != nil || isEvalVariable(s.Context.Eval, expr.Traversal.RootName()) {
+			return nil
+		}
+		ref, refErr := exprToRef(s.Context, expr)
+		if refErr != nil {
+			return nil
+		}
+		resolved, refErr := p.Resolve(s.Context, ref)
+		if refErr != nil {
+			// Reported by whoever evaluates the expression.
+			return nil
+		}
+		attrRef, ok := resolved.(*AttributeRef)
+		if !ok {
+			return nil
+		}
+		var val cty.Value
+		val, err = p.attributeValue(attrRef.Symbol)
+		if err != nil {
+			if !cerror.HasRange(err) {
+				err = cerror.ErrorWithRange(err.Error(), expr.Range())
+			}
+			return nil
+		}
+		names := make([]string, 0, len(expr.Traversal))
+		for _, t := range expr.Traversal[:len(expr.Traversal)-len(attrRef.Path)] {
+			names = append(names, traverserKey(t))
+		}
+		tree.insert(names, val)
+		return nil
+	})
+	if err != nil || tree.children == nil {
+		return s.Context, err
+	}
+	eval := s.Context.Eval.NewChild()
+	eval.Variables = make(map[string]cty.Value, len(tree.children))
+	for name, child := range tree.children {
+		eval.Variables[name] = child.toCty()
+	}
+	ctx := *s.Context
+	ctx.Eval = eval
+	return &ctx, nil
+}
+
+// attributeValue parses s and returns its attributes.
+func (p *Parser) attributeValue(s *UnprocessedSymbol) (cty.Value, error) {
+	sym, err := p.ParseSymbol(s)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	attributed, ok := sym.(model.Attributed)
+	if !ok {
+		return cty.NilVal, fmt.Errorf("%s has no attributes", sym.GetQualifier())
+	}
+	return goToCty(attributed.Attributes())
+}
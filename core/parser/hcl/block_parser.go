@@ -10,6 +10,7 @@ import (
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 
 	"github.com/tychonis/cyanotype/core/process"
+	"github.com/tychonis/cyanotype/core/qualifier"
 	"github.com/tychonis/cyanotype/internal/cerror"
 	"github.com/tychonis/cyanotype/internal/digest"
 	"github.com/tychonis/cyanotype/internal/stable"
@@ -26,37 +27,65 @@ func (p *Parser) ParseSymbol(s *UnprocessedSymbol) (sym model.ConcreteSymbol, er
 	if s.Block == nil || s.Context == nil {
 		return nil, errors.New("illegal nil symbol")
 	}
+	if p.cyclic[s] {
+		// Reported once for the whole cycle, the error is for dependents.
+		return nil, errors.New("cyclic reference to " + s.displayQualifier())
+	}
+	if p.parsing[s] {
+		return nil, cerror.ErrorWithRange("cyclic reference to "+s.Context.BlockName(s.Block), s.Block.DefRange())
+	}
+	p.parsing[s] = true
+	defer delete(p.parsing, s)
+
+	ctx, err := p.symbolContext(s)
+	if err != nil {
+		return nil, err
+	}
 	switch s.Block.Type {
 	case "item":
-		sym, err = p.parseItemBlock(s.Context, s.Block)
+		sym, err = p.parseItemBlock(ctx, s.Block)
 	case "coitem":
-		sym, err = p.parseCoItemBlock(s.Context, s.Block)
+		sym, err = p.parseCoItemBlock(ctx, s.Block)
 	case "process":
-		sym, err = p.parseProcessBlock(s.Context, s.Block)
+		sym, err = p.parseProcessBlock(ctx, s.Block)
 	case "coprocess":
-		sym, err = p.parseCoProcessBlock(s.Context, s.Block)
+		sym, err = p.parseCoProcessBlock(ctx, s.Block)
 	case "contract":
-		sym, err = p.parseContractBlock(s.Context, s.Block)
+		sym, err = p.parseContractBlock(ctx, s.Block)
 	default:
 		return nil, cerror.ErrorWithRange("unknown block type", s.Block.Range())
 	}
 	if err == nil {
 		// TODO: remove side effects here.
 		s.qualifier = sym.GetQualifier()
+		p.ranges[sym.GetDigest()] = s.Block.DefRange()
 		slog.Debug("saving symbol",
 			"qualifier", sym.GetQualifier(), "digest", sym.GetDigest())
 		p.Symbols.RegisterConcreteSymbol(sym)
-	} else {
-		err = cerror.ErrorWithRange(err.Error(), s.Block.Range())
+	} else if !cerror.HasRange(err) {
+		err = cerror.ErrorWithRange(err.Error(), s.Block.DefRange())
 	}
 	return
 }
 
+// joinRef joins ref components with dots, except for instance keys which
+// are appended directly, e.g. `bolt["M3x10"]`.
+func joinRef(ref []string) string {
+	sb := &strings.Builder{}
+	for i, part := range ref {
+		if i > 0 && !strings.HasPrefix(part, "[") {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(part)
+	}
+	return sb.String()
+}
+
 func refToQualifier(ctx *ParserContext, ref []string) string {
 	if ctx.CurrentModule() == "." {
-		return "." + strings.Join(ref, ".")
+		return "." + joinRef(ref)
 	} else {
-		return strings.Join(append([]string{ctx.CurrentModule()}, ref...), ".")
+		return joinRef(append([]string{ctx.CurrentModule()}, ref...))
 	}
 }
 
@@ -71,43 +100,40 @@ func (p *Parser) resolveContractsLinesAttr(ctx *ParserContext, attr *hcl.Attribu
 var RESERVED = map[string]struct{}{
 	"part_number": {},
 	"source":      {},
+	"unit":        {},
 	"from":        {},
 	"impl":        {},
+	"req":         {},
+	"for_each":    {},
+	"count":       {},
 }
 
 func (p *Parser) getDetails(ctx *ParserContext, attrs hcl.Attributes) (stable.Map, error) {
-	keys := make([]string, 0)
-	for key := range attrs {
-		_, ok := RESERVED[key]
-		if !ok {
-			keys = append(keys, key)
-		}
-	}
-	ret := make(stable.Map)
-	for i := 0; i < len(keys); i++ {
-		key := keys[i]
-		val, err := getString(attrs, key)
-		if err != nil {
-			return ret, err
-		}
-		ret[key] = val
-	}
-	return ret, nil
+	return evalAttributes(ctx, attrs, RESERVED)
 }
 
 func (p *Parser) parseItemBlock(ctx *ParserContext, block *hclsyntax.Block) (*model.Item, error) {
-	name := block.Labels[0]
+	name := ctx.BlockName(block)
 	attrs, err := extractAttributes(block.Body)
 	if err != nil {
 		return nil, err
 	}
-	pn, _ := getString(attrs, "part_number")
-	src, _ := getString(attrs, "source")
+	pn, err := getOptionalString(ctx, attrs, "part_number")
+	if err != nil {
+		return nil, err
+	}
+	src, err := getOptionalString(ctx, attrs, "source")
+	if err != nil {
+		return nil, err
+	}
 
 	var pc process.ProcessContent
 	fromAttr, ok := attrs["from"]
 	if ok {
-		from := parseBOMLinesAttr(ctx, fromAttr)
+		from, err := parseBOMLinesAttr(ctx, fromAttr)
+		if err != nil {
+			return nil, err
+		}
 		pc, err = p.processKeywordFROM(ctx, from)
 		if err != nil {
 			return nil, err
@@ -124,10 +150,17 @@ func (p *Parser) parseItemBlock(ctx *ParserContext, block *hclsyntax.Block) (*mo
 		Source:     src,
 		PartNumber: pn,
 	}
+	item.Content.Unit, err = getUnit(ctx, attrs)
+	if err != nil {
+		return nil, err
+	}
 
 	implAttr, ok := attrs["impl"]
 	if ok {
-		item.Implement, _ = p.resolveContractsLinesAttr(ctx, implAttr)
+		item.Implement, err = p.resolveContractsLinesAttr(ctx, implAttr)
+		if err != nil {
+			return nil, cerror.ErrorWithRange(implAttr.Name+": "+err.Error(), implAttr.Expr.Range())
+		}
 	}
 
 	item.Content.Details, err = p.getDetails(ctx, attrs)
@@ -155,13 +188,19 @@ func (p *Parser) parseItemBlock(ctx *ParserContext, block *hclsyntax.Block) (*mo
 }
 
 func (p *Parser) parseCoItemBlock(ctx *ParserContext, block *hclsyntax.Block) (*model.CoItem, error) {
-	name := block.Labels[0]
+	name := ctx.BlockName(block)
 	attrs, err := extractAttributes(block.Body)
 	if err != nil {
 		return nil, err
 	}
-	pn, _ := getString(attrs, "part_number")
-	src, _ := getString(attrs, "source")
+	pn, err := getOptionalString(ctx, attrs, "part_number")
+	if err != nil {
+		return nil, err
+	}
+	src, err := getOptionalString(ctx, attrs, "source")
+	if err != nil {
+		return nil, err
+	}
 
 	coItem := &model.CoItem{}
 	coItem.Type = "coitem"
@@ -171,10 +210,17 @@ func (p *Parser) parseCoItemBlock(ctx *ParserContext, block *hclsyntax.Block) (*
 		Source:     src,
 		PartNumber: pn,
 	}
+	coItem.Content.Unit, err = getUnit(ctx, attrs)
+	if err != nil {
+		return nil, err
+	}
 
 	reqAttr, ok := attrs["req"]
 	if ok {
-		coItem.Require, _ = p.resolveContractsLinesAttr(ctx, reqAttr)
+		coItem.Require, err = p.resolveContractsLinesAttr(ctx, reqAttr)
+		if err != nil {
+			return nil, cerror.ErrorWithRange(reqAttr.Name+": "+err.Error(), reqAttr.Expr.Range())
+		}
 	}
 
 	coItem.Content.Details, err = p.getDetails(ctx, attrs)
@@ -198,7 +244,10 @@ func (p *Parser) createProcessContract(process *process.Process, mode string, li
 }
 
 func (p *Parser) resolveBOMLinesAttr(ctx *ParserContext, attr *hcl.Attribute) ([]*model.BOMLine, error) {
-	lines := parseBOMLinesAttr(ctx, attr)
+	lines, err := parseBOMLinesAttr(ctx, attr)
+	if err != nil {
+		return nil, err
+	}
 	ret := make([]*model.BOMLine, 0, len(lines))
 	for _, line := range lines {
 		resolved, err := p.ResolveBOMLine(ctx, line)
@@ -210,27 +259,237 @@ func (p *Parser) resolveBOMLinesAttr(ctx *ParserContext, attr *hcl.Attribute) ([
 	return ret, nil
 }
 
+var PROCESS_RESERVED = map[string]struct{}{
+	"type":   {},
+	"input":  {},
+	"output": {},
+}
+
+// resolveProcessInput resolves an input line of a process block. Lines may
+// reference a coitem directly, or an item which stands for its companion
+// coitem. The unit the referenced symbol is counted in is returned along.
+func (p *Parser) resolveProcessInput(ctx *ParserContext, line *UnresolvedBOMLine) (*model.BOMLine, string, error) {
+	sym, err := p.resolveConcreteRef(ctx, line.Ref)
+	if err != nil {
+		return nil, "", line.refError(err)
+	}
+	var coItem model.ConcreteSymbol
+	var content *model.ItemContent
+	switch resolved := sym.(type) {
+	case *model.Item:
+		content = resolved.Content
+		coItem, err = p.Symbols.FindConcreteSymbol(qualifier.ImplicitCoItem(resolved))
+		if err != nil {
+			return nil, "", err
+		}
+	case *model.CoItem:
+		content = resolved.Content
+		coItem = resolved
+	default:
+		return nil, "", cerror.ErrorWithRange("process input must be an item or coitem", line.Range)
+	}
+	err = checkUnit(line, content, sym.GetQualifier())
+	if err != nil {
+		return nil, "", err
+	}
+	return &model.BOMLine{
+		Name:   line.Name,
+		Item:   coItem.GetDigest(),
+		Qty:    line.Qty,
+		Unit:   line.Unit,
+		Refdes: line.Refdes,
+	}, baseUnit(content), nil
+}
+
+// parseProcessContent builds the content of a process block. Without an
+// explicit type, inputs with placement make a drawing as in `from`.
+func (p *Parser) parseProcessContent(ctx *ParserContext, name string, attrs hcl.Attributes) (process.ProcessContent, error) {
+	inputs, err := parseBOMLinesAttr(ctx, attrs["input"])
+	if err != nil {
+		return nil, err
+	}
+	contentType := process.ABSTRACT
+	for _, line := range inputs {
+		if line.HasPlacement {
+			contentType = process.DRAWING
+		}
+	}
+	if typeAttr, ok := attrs["type"]; ok {
+		contentType, err = evalString(ctx, typeAttr.Expr)
+		if err != nil {
+			return nil, cerror.ErrorWithRange("type: "+err.Error(), typeAttr.Expr.Range())
+		}
+	}
+
+	authored := &process.Authored{
+		Name:       name,
+		Input:      make([]*model.BOMLine, 0, len(inputs)),
+		Placements: make([]*model.Placement, 0, len(inputs)),
+	}
+	unplaced := make([]hcl.Range, 0)
+	for _, line := range inputs {
+		resolved, base, err := p.resolveProcessInput(ctx, line)
+		if err != nil {
+			return nil, err
+		}
+		if contentType != process.DRAWING {
+			authored.Input = append(authored.Input, resolved)
+			authored.Placements = append(authored.Placements, nil)
+			continue
+		}
+		if !line.HasPlacement {
+			unplaced = append(unplaced, line.Range)
+		}
+		pieces, err := drawingPieces(line, base)
+		if err != nil {
+			return nil, err
+		}
+		for _, piece := range pieces {
+			authored.Input = append(authored.Input, &model.BOMLine{
+				Name:   piece.Name,
+				Item:   resolved.Item,
+				Qty:    piece.Qty,
+				Unit:   piece.Unit,
+				Refdes: piece.Refdes,
+			})
+			authored.Placements = append(authored.Placements, &piece.Placement)
+		}
+	}
+
+	outputAttr, ok := attrs["output"]
+	if !ok {
+		return nil, errors.New("process requires an output")
+	}
+	authored.Output, err = p.resolveBOMLinesAttr(ctx, outputAttr)
+	if err != nil {
+		return nil, err
+	}
+	if len(authored.Output) == 0 {
+		return nil, cerror.ErrorWithRange("process requires an output", outputAttr.Range)
+	}
+
+	authored.Details, err = evalAttributes(ctx, attrs, PROCESS_RESERVED)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := process.NewContent(contentType)
+	if err != nil {
+		return nil, err
+	}
+	authorable, ok := content.(process.Authorable)
+	if !ok {
+		return nil, fmt.Errorf("content type %q cannot be authored", contentType)
+	}
+	err = authorable.Author(authored)
+	if err != nil {
+		return nil, err
+	}
+	if contentType == process.DRAWING {
+		p.unplaced = append(p.unplaced, unplaced...)
+	}
+	return content, nil
+}
+
 func (p *Parser) parseProcessBlock(ctx *ParserContext, block *hclsyntax.Block) (ret *process.Process, err error) {
 	name := block.Labels[0]
+	attrs, err := extractAttributes(block.Body)
+	if err != nil {
+		return nil, err
+	}
 	ret = &process.Process{}
 	ret.Type = "process"
 	ret.Qualifier = ctx.NameToQualifier(name)
-	_, diags := block.Body.JustAttributes()
-	if diags.HasErrors() {
-		return nil, diags
+	ret.Content, err = p.parseProcessContent(ctx, name, attrs)
+	if err != nil {
+		return nil, err
 	}
 	ret.Digest, err = digest.SHA256FromSymbol(ret)
 	return
 }
 
+var COPROCESS_RESERVED = map[string]struct{}{
+	"from": {},
+	"to":   {},
+}
+
+// resolveCoItem resolves the target of a coprocess. An item stands for its
+// companion coitem.
+func (p *Parser) resolveCoItem(ctx *ParserContext, attr *hcl.Attribute) (model.ConcreteSymbol, error) {
+	ref, err := exprToRef(ctx, attr.Expr)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.resolveConcreteRef(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	switch resolved := sym.(type) {
+	case *model.Item:
+		return p.Symbols.FindConcreteSymbol(qualifier.ImplicitCoItem(resolved))
+	case *model.CoItem:
+		return resolved, nil
+	default:
+		return nil, cerror.ErrorWithRange("coprocess must lead to a coitem", attr.Expr.Range())
+	}
+}
+
 func (p *Parser) parseCoProcessBlock(ctx *ParserContext, block *hclsyntax.Block) (ret *process.CoProcess, err error) {
 	name := block.Labels[0]
+	attrs, err := extractAttributes(block.Body)
+	if err != nil {
+		return nil, err
+	}
+	fromAttr, ok := attrs["from"]
+	if !ok {
+		return nil, errors.New("coprocess requires from")
+	}
+	toAttr, ok := attrs["to"]
+	if !ok {
+		return nil, errors.New("coprocess requires to")
+	}
+
+	ref, err := exprToRef(ctx, fromAttr.Expr)
+	if err != nil {
+		return nil, err
+	}
+	item, err := p.resolveBOMLineRef(ctx, ref)
+	if err != nil {
+		return nil, cerror.ErrorWithRange("from: "+err.Error(), fromAttr.Expr.Range())
+	}
+	coItem, err := p.resolveCoItem(ctx, toAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := evalAttributes(ctx, attrs, COPROCESS_RESERVED)
+	if err != nil {
+		return nil, err
+	}
+	if priority, ok := details["priority"]; ok {
+		if _, ok := priority.(float64); !ok {
+			return nil, cerror.ErrorWithRange("priority must be a number", attrs["priority"].Expr.Range())
+		}
+	}
+
 	ret = &process.CoProcess{}
 	ret.Type = "coprocess"
 	ret.Qualifier = ctx.NameToQualifier(name)
-	_, diags := block.Body.JustAttributes()
-	if diags.HasErrors() {
-		return nil, diags
+	ret.Content = &process.Abstract{
+		Name: name,
+		Input: []*model.BOMLine{
+			{
+				Item: item.Digest,
+				Qty:  1,
+			},
+		},
+		Output: []*model.BOMLine{
+			{
+				Item: coItem.GetDigest(),
+				Qty:  1,
+			},
+		},
+		Details: details,
 	}
 	ret.Digest, err = digest.SHA256FromSymbol(ret)
 	return
@@ -242,11 +501,9 @@ func (p *Parser) parseContractBlock(ctx *ParserContext, block *hclsyntax.Block)
 	if diags.HasErrors() {
 		return nil, diags
 	}
-	params := make(map[string]any)
-	for attr := range attrs {
-		// TODO: support more types?
-		param, _ := getString(attrs, attr)
-		params[attr] = param
+	params, err := evalAttributes(ctx, attrs, nil)
+	if err != nil {
+		return nil, err
 	}
 	contract := &model.Contract{
 		Type:      "contract",
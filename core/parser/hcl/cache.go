@@ -0,0 +1,110 @@
+package hcl
+
+import (
+	"bytes"
+	"os"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/tychonis/cyanotype/internal/digest"
+)
+
+// BuildCache keeps what successive builds of a workspace can share: git
+// repositories, the sources of git imports, which are pinned to a commit,
+// the syntax trees of source files whose content has not changed, and the
+// digests of artifact files whose size and modification time have not
+// changed. Symbols are evaluated again from the syntax trees on every build,
+// since a change in one file can affect symbols of any other. A cache is not
+// safe for concurrent builds.
+type BuildCache struct {
+	repos   map[string]*git.Repository
+	sources map[string][]*sourceFile
+	digests map[string]*fileDigest
+	files   map[string]*parsedFile
+}
+
+type parsedFile struct {
+	digest string
+	file   *hcl.File
+}
+
+type fileDigest struct {
+	size    int64
+	modTime time.Time
+	digest  string
+}
+
+func NewBuildCache() *BuildCache {
+	return &BuildCache{
+		repos:   make(map[string]*git.Repository),
+		sources: make(map[string][]*sourceFile),
+		digests: make(map[string]*fileDigest),
+		files:   make(map[string]*parsedFile),
+	}
+}
+
+// fileDigest returns the digest of a local file, read again only when it
+// was modified since the last build.
+func (p *Parser) fileDigest(path string) (string, error) {
+	cache := p.Options.Cache
+	if cache == nil {
+		return digest.SHA256FromFile(path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if cached, ok := cache.digests[path]; ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.digest, nil
+	}
+	sum, err := digest.SHA256FromFile(path)
+	if err != nil {
+		return "", err
+	}
+	cache.digests[path] = &fileDigest{size: info.Size(), modTime: info.ModTime(), digest: sum}
+	return sum, nil
+}
+
+// cachedGitSources reads the sources of src at a commit once per cache.
+func (p *Parser) cachedGitSources(src *GitSource, commit string) ([]*sourceFile, error) {
+	cache := p.Options.Cache
+	if cache == nil {
+		return p.readGitSources(src, commit)
+	}
+	key := src.Identifier() + "@" + commit
+	if files, ok := cache.sources[key]; ok {
+		return files, nil
+	}
+	files, err := p.readGitSources(src, commit)
+	if err != nil {
+		return nil, err
+	}
+	cache.sources[key] = files
+	return files, nil
+}
+
+// cachedParse parses a source file unless the cache holds the syntax tree
+// of the same content. Files with errors are not cached.
+func (p *Parser) cachedParse(frontend Frontend, src []byte, filename string) (*hcl.File, hcl.Diagnostics) {
+	cache := p.Options.Cache
+	if cache == nil {
+		return frontend.Parse(p.files, src, filename)
+	}
+	sum, err := digest.SHA256FromReader(bytes.NewReader(src))
+	if err != nil {
+		return frontend.Parse(p.files, src, filename)
+	}
+	if cached, ok := cache.files[filename]; ok && cached.digest == sum {
+		p.files.AddFile(filename, cached.file)
+		return cached.file, nil
+	}
+	file, diags := frontend.Parse(p.files, src, filename)
+	if diags.HasErrors() {
+		delete(cache.files, filename)
+		return file, diags
+	}
+	cache.files[filename] = &parsedFile{digest: sum, file: file}
+	return file, diags
+}
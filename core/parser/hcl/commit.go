@@ -3,6 +3,8 @@ package hcl
 import (
 	"errors"
 	"log/slog"
+	"maps"
+	"slices"
 
 	"github.com/tychonis/cyanotype/core/catalog"
 	"github.com/tychonis/cyanotype/model"
@@ -26,10 +28,37 @@ func (p *Parser) isSymbolChanged(old model.ConcreteSymbol, new model.ConcreteSym
 	return old.GetDigest() != new.GetDigest()
 }
 
+// isSourceChanged reports whether git imports moved to other commits since
+// the latest revision, so that a bump is recorded even if no symbol changed.
+func (p *Parser) isSourceChanged(cat *catalog.Catalog, revision *model.Revision) bool {
+	if len(revision.Parents) == 0 {
+		return len(revision.Sources) > 0
+	}
+	parent, err := cat.GetRevision(revision.Parents[0])
+	if err != nil {
+		return len(revision.Sources) > 0
+	}
+	return !maps.Equal(parent.Sources, revision.Sources)
+}
+
 func (p *Parser) commit(cat *catalog.Catalog, dryrun bool) error {
+	err := p.CheckContracts()
+	if err != nil {
+		return err
+	}
 	revision := cat.NewRevision()
+	revision.Sources = p.Sources()
 	change := 0
-	for qualifier, symDigest := range p.Symbols.QualifierIndex {
+	if p.isSourceChanged(cat, revision) {
+		if dryrun {
+			slog.Info("New sources", "sources", revision.Sources)
+		}
+		change++
+	}
+	// Symbols are added in qualifier order so that the catalog indexes, and
+	// with them the candidates offered to rankers, are deterministic.
+	for _, qualifier := range slices.Sorted(maps.Keys(p.Symbols.QualifierIndex)) {
+		symDigest := p.Symbols.QualifierIndex[qualifier]
 		oldSym, err := cat.FindCurrent(qualifier)
 		if err != nil && err != catalog.ErrNotFound {
 			return err
@@ -38,6 +67,12 @@ func (p *Parser) commit(cat *catalog.Catalog, dryrun bool) error {
 		if !ok {
 			return errors.New("symbol not found in symbol table")
 		}
+		if !dryrun {
+			err = p.storeArtifacts(cat, newSym)
+			if err != nil {
+				return err
+			}
+		}
 		if !p.isSymbolChanged(oldSym, newSym) {
 			continue
 		}
@@ -48,6 +83,9 @@ func (p *Parser) commit(cat *catalog.Catalog, dryrun bool) error {
 			}
 		} else {
 			slog.Info("New symbol", "qualifier", qualifier, "digest", symDigest)
+			if oldSym != nil {
+				reportArtifactChanges(oldSym, newSym)
+			}
 		}
 		change++
 	}
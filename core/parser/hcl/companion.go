@@ -15,6 +15,13 @@ func (p *Parser) buildCompanionCoItem(item *model.Item) (*model.CoItem, error) {
 	co := &model.CoItem{}
 	co.Type = "coitem"
 	co.Qualifier = qualifier.ImplicitCoItem(item)
+	// The companion is named after the item qualifier, so every item gets
+	// its own coitem while successive versions of an item share it. Without
+	// a name all companions hash alike and BOM lines cannot tell which item
+	// they reference.
+	co.Content = &model.ItemContent{
+		Name: item.Qualifier,
+	}
 	co.Digest, err = digest.SHA256FromSymbol(co)
 	if err != nil {
 		return co, err
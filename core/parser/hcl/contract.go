@@ -0,0 +1,80 @@
+package hcl
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/tychonis/cyanotype/core/process"
+	"github.com/tychonis/cyanotype/internal/cerror"
+	"github.com/tychonis/cyanotype/model"
+)
+
+func (p *Parser) lookupContract(id model.ContractID) (*model.Contract, error) {
+	sym, ok := p.Symbols.ConcreteSymbols[id]
+	if !ok {
+		return nil, fmt.Errorf("contract %s not found", id)
+	}
+	contract, ok := sym.(*model.Contract)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a contract", sym.GetQualifier())
+	}
+	return contract, nil
+}
+
+func contractNames(contracts []*model.Contract) string {
+	names := make([]string, 0, len(contracts))
+	for _, c := range contracts {
+		names = append(names, c.Qualifier)
+	}
+	return strings.Join(names, ", ")
+}
+
+// checkCoProcess verifies that the item entering a coprocess implements
+// every contract its target coitem requires.
+func (p *Parser) checkCoProcess(cp *process.CoProcess) error {
+	input, output := cp.Input(), cp.Output()
+	if len(input) != 1 || len(output) != 1 {
+		return nil
+	}
+	item, ok := p.Symbols.ConcreteSymbols[input[0].Item].(*model.Item)
+	if !ok {
+		return nil
+	}
+	coItem, ok := p.Symbols.ConcreteSymbols[output[0].Item].(*model.CoItem)
+	if !ok {
+		return nil
+	}
+	missing, err := model.Unfulfilled(item.Implement, coItem.Require, p.lookupContract)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("%s does not fulfil %s required by %s", item.Qualifier, contractNames(missing), coItem.Qualifier)
+	r, ok := p.ranges[cp.Digest]
+	if !ok {
+		return errors.New(msg)
+	}
+	return cerror.ErrorWithRange(msg, r)
+}
+
+// CheckContracts reports every coprocess leading an item to a coitem whose
+// requirements the item does not fulfil.
+func (p *Parser) CheckContracts() error {
+	errs := make([]error, 0)
+	for _, q := range slices.Sorted(maps.Keys(p.Symbols.QualifierIndex)) {
+		cp, ok := p.Symbols.ConcreteSymbols[p.Symbols.QualifierIndex[q]].(*process.CoProcess)
+		if !ok {
+			continue
+		}
+		err := p.checkCoProcess(cp)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
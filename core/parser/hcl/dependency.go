@@ -0,0 +1,144 @@
+package hcl
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+
+	"github.com/tychonis/cyanotype/internal/cerror"
+	"github.com/tychonis/cyanotype/internal/symbols"
+)
+
+// unprocessedSymbols lists the symbols of every module, members of families
+// included, in a deterministic order.
+func (p *Parser) unprocessedSymbols() []*UnprocessedSymbol {
+	ret := make([]*UnprocessedSymbol, 0)
+	var collect func(m *symbols.ModuleScope)
+	collect = func(m *symbols.ModuleScope) {
+		for _, name := range slices.Sorted(maps.Keys(m.Symbols)) {
+			switch s := m.Symbols[name].(type) {
+			case *symbols.ModuleScope:
+				collect(s)
+			case *UnprocessedSymbol:
+				ret = append(ret, s)
+			case *UnprocessedFamily:
+				for _, key := range s.SortedKeys() {
+					ret = append(ret, s.Members[key])
+				}
+			}
+		}
+	}
+	for _, module := range slices.Sorted(maps.Keys(p.Symbols.Modules)) {
+		collect(p.Symbols.Modules[module])
+	}
+	return ret
+}
+
+// references lists the symbols s references, in BOM lines, contracts or
+// attribute references. References that do not resolve are left for
+// parsing to report.
+func (p *Parser) references(s *UnprocessedSymbol) []*UnprocessedSymbol {
+	ret := make([]*UnprocessedSymbol, 0)
+	hclsyntax.VisitAll(s.Block.Body, func(node hclsyntax.Node) hcl.Diagnostics {
+		var expr hcl.Expression
+		switch e := node.(type) {
+		case *hclsyntax.ScopeTraversalExpr:
+			if isEvalVariable(s.Context.Eval, e.Traversal.RootName()) {
+				return nil
+			}
+			expr = e
+		case *hclsyntax.IndexExpr:
+			// Members referenced with a computed key, e.g. bolt[each.key].
+			expr = e
+		default:
+			return nil
+		}
+		ref, err := exprToRef(s.Context, expr)
+		if err != nil {
+			return nil
+		}
+		resolved, err := p.Resolve(s.Context, ref)
+		if err != nil {
+			return nil
+		}
+		switch r := resolved.(type) {
+		case *UnprocessedSymbol:
+			ret = append(ret, r)
+		case *AttributeRef:
+			ret = append(ret, r.Symbol)
+		}
+		return nil
+	})
+	return ret
+}
+
+func (s *UnprocessedSymbol) displayQualifier() string {
+	return s.Context.NameToQualifier(s.Context.BlockName(s.Block))
+}
+
+// sortSymbols orders symbols so that each is parsed after the symbols it
+// references. Strongly connected symbols are found with Tarjan's algorithm,
+// which also yields them in that order, and every cycle is reported on each
+// of its symbols.
+func (p *Parser) sortSymbols() {
+	p.order = make([]*UnprocessedSymbol, 0)
+	p.cyclic = make(map[*UnprocessedSymbol]bool)
+	index := make(map[*UnprocessedSymbol]int)
+	low := make(map[*UnprocessedSymbol]int)
+	onStack := make(map[*UnprocessedSymbol]bool)
+	stack := make([]*UnprocessedSymbol, 0)
+
+	var visit func(s *UnprocessedSymbol)
+	visit = func(s *UnprocessedSymbol) {
+		index[s] = len(index)
+		low[s] = index[s]
+		stack = append(stack, s)
+		onStack[s] = true
+		self := false
+		for _, dep := range p.references(s) {
+			if _, ok := index[dep]; !ok {
+				visit(dep)
+				low[s] = min(low[s], low[dep])
+			} else if onStack[dep] {
+				low[s] = min(low[s], index[dep])
+			}
+			self = self || dep == s
+		}
+		if low[s] != index[s] {
+			return
+		}
+		i := slices.Index(stack, s)
+		component := slices.Clone(stack[i:])
+		stack = stack[:i]
+		for _, member := range component {
+			onStack[member] = false
+		}
+		if len(component) == 1 && !self {
+			p.order = append(p.order, s)
+			return
+		}
+		p.reportCycle(component)
+	}
+
+	for _, s := range p.unprocessedSymbols() {
+		if _, ok := index[s]; !ok {
+			visit(s)
+		}
+	}
+}
+
+func (p *Parser) reportCycle(component []*UnprocessedSymbol) {
+	qualifiers := make([]string, 0, len(component))
+	for _, s := range component {
+		qualifiers = append(qualifiers, s.displayQualifier())
+	}
+	slices.Sort(qualifiers)
+	msg := "cyclic reference between " + strings.Join(qualifiers, ", ")
+	for _, s := range component {
+		p.cyclic[s] = true
+		p.report(cerror.ErrorWithRange(msg, s.Block.DefRange()))
+	}
+}
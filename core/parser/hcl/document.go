@@ -0,0 +1,485 @@
+package hcl
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/big"
+	"slices"
+	"strconv"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+
+	"github.com/tychonis/cyanotype/internal/stable"
+)
+
+// Document is the JSON or YAML form of a source file, for tools generating
+// sources. Symbols are keyed by name, references are written as strings
+// such as "std.bolt", and values are taken literally.
+type Document struct {
+	Imports     []*DocumentImport             `json:"imports,omitempty" yaml:"imports,omitempty"`
+	Items       map[string]*DocumentItem      `json:"items,omitempty" yaml:"items,omitempty"`
+	CoItems     map[string]*DocumentCoItem    `json:"coitems,omitempty" yaml:"coitems,omitempty"`
+	Processes   map[string]*DocumentProcess   `json:"processes,omitempty" yaml:"processes,omitempty"`
+	CoProcesses map[string]*DocumentCoProcess `json:"coprocesses,omitempty" yaml:"coprocesses,omitempty"`
+	Contracts   map[string]map[string]any     `json:"contracts,omitempty" yaml:"contracts,omitempty"`
+}
+
+type DocumentImport struct {
+	Source string `json:"source" yaml:"source"`
+	As     string `json:"as,omitempty" yaml:"as,omitempty"`
+}
+
+type DocumentArtifact struct {
+	Filename string `json:"filename" yaml:"filename"`
+	Tag      string `json:"tag" yaml:"tag"`
+	Source   string `json:"source" yaml:"source"`
+}
+
+// DocumentLine is a BOM line. Ref is a reference, the other keys are
+// name, qty, unit, refdes and placement.
+type DocumentLine map[string]any
+
+type DocumentItem struct {
+	PartNumber string                       `json:"part_number,omitempty" yaml:"part_number,omitempty"`
+	Source     string                       `json:"source,omitempty" yaml:"source,omitempty"`
+	Unit       string                       `json:"unit,omitempty" yaml:"unit,omitempty"`
+	From       []DocumentLine               `json:"from,omitempty" yaml:"from,omitempty"`
+	Impl       []string                     `json:"impl,omitempty" yaml:"impl,omitempty"`
+	Details    map[string]any               `json:"details,omitempty" yaml:"details,omitempty"`
+	Artifacts  map[string]*DocumentArtifact `json:"artifacts,omitempty" yaml:"artifacts,omitempty"`
+}
+
+type DocumentCoItem struct {
+	PartNumber string                       `json:"part_number,omitempty" yaml:"part_number,omitempty"`
+	Source     string                       `json:"source,omitempty" yaml:"source,omitempty"`
+	Unit       string                       `json:"unit,omitempty" yaml:"unit,omitempty"`
+	Req        []string                     `json:"req,omitempty" yaml:"req,omitempty"`
+	Details    map[string]any               `json:"details,omitempty" yaml:"details,omitempty"`
+	Artifacts  map[string]*DocumentArtifact `json:"artifacts,omitempty" yaml:"artifacts,omitempty"`
+}
+
+type DocumentProcess struct {
+	Type    string         `json:"type,omitempty" yaml:"type,omitempty"`
+	Input   []DocumentLine `json:"input,omitempty" yaml:"input,omitempty"`
+	Output  []DocumentLine `json:"output" yaml:"output"`
+	Details map[string]any `json:"details,omitempty" yaml:"details,omitempty"`
+}
+
+type DocumentCoProcess struct {
+	From     string         `json:"from" yaml:"from"`
+	To       string         `json:"to" yaml:"to"`
+	Priority *float64       `json:"priority,omitempty" yaml:"priority,omitempty"`
+	Details  map[string]any `json:"details,omitempty" yaml:"details,omitempty"`
+}
+
+// DOCUMENT_LINE_KEYS are the keys a BOM line of a document may have.
+var DOCUMENT_LINE_KEYS = []string{"name", "ref", "qty", "unit", "refdes", "placement"}
+
+func decodeJSON(src []byte) (*Document, error) {
+	decoder := json.NewDecoder(bytes.NewReader(src))
+	decoder.DisallowUnknownFields()
+	decoder.UseNumber()
+	doc := &Document{}
+	err := decoder.Decode(doc)
+	return doc, err
+}
+
+func decodeYAML(src []byte) (*Document, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(src))
+	decoder.KnownFields(true)
+	doc := &Document{}
+	err := decoder.Decode(doc)
+	if errors.Is(err, io.EOF) {
+		return doc, nil
+	}
+	return doc, err
+}
+
+// DocumentFrontend reads .bpo.json and .bpo.yaml files. A document is
+// translated into the blocks it stands for, which are parsed like any
+// other source. The ranges of the translation are then pointed back at the
+// document, so diagnostics show what the user wrote.
+type DocumentFrontend struct {
+	Decode func(src []byte) (*Document, error)
+	Locate func(src []byte, filename string) (*positions, error)
+}
+
+func (f DocumentFrontend) Parse(files *hclparse.Parser, src []byte, filename string) (*hcl.File, hcl.Diagnostics) {
+	if file, ok := files.Files()[filename]; ok {
+		return file, nil
+	}
+	positions, err := f.Locate(src, filename)
+	if err != nil {
+		positions = newPositions(src, filename)
+	}
+	doc, err := f.Decode(src)
+	if err != nil {
+		return nil, positions.documentError(err)
+	}
+	translated, err := doc.Translate()
+	if err != nil {
+		return nil, positions.documentError(err)
+	}
+	file, diags := hclsyntax.ParseConfig(translated, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	positions.remap(file)
+	file.Bytes = src
+	files.AddFile(filename, file)
+	return file, nil
+}
+
+// documentPathError is an error about the value of a document at a path.
+type documentPathError struct {
+	Path string
+	Err  error
+}
+
+func (e *documentPathError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *documentPathError) Unwrap() error {
+	return e.Err
+}
+
+// documentError reports err at the value it is about when known, at the
+// start of the document otherwise.
+func (m *positions) documentError(err error) hcl.Diagnostics {
+	subject := m.span(0, 0)
+	var pathErr *documentPathError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &pathErr):
+		subject = m.nameRange(pathErr.Path, subject)
+	case errors.As(err, &syntaxErr):
+		subject = m.span(int(syntaxErr.Offset), int(syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		subject = m.span(int(typeErr.Offset), int(typeErr.Offset))
+	}
+	return hcl.Diagnostics{{
+		Severity: hcl.DiagError,
+		Summary:  "Invalid document",
+		Detail:   err.Error(),
+		Subject:  &subject,
+	}}
+}
+
+// Translate writes the blocks a document stands for, in a stable order.
+func (doc *Document) Translate() ([]byte, error) {
+	f := hclwrite.NewEmptyFile()
+	body := f.Body()
+	for i, imp := range doc.Imports {
+		if imp.Source == "" {
+			return nil, &documentPathError{Path: joinPath("imports", strconv.Itoa(i)), Err: errors.New("import requires a source")}
+		}
+		block := body.AppendNewBlock("import", []string{imp.Source})
+		if imp.As != "" {
+			block.Body().SetAttributeValue("as", cty.StringVal(imp.As))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(doc.Items)) {
+		err := cmp.Or(doc.Items[name], &DocumentItem{}).write(body, name)
+		if err != nil {
+			return nil, &documentPathError{Path: joinPath("items", name), Err: fmt.Errorf("item %s: %w", name, err)}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(doc.CoItems)) {
+		err := cmp.Or(doc.CoItems[name], &DocumentCoItem{}).write(body, name)
+		if err != nil {
+			return nil, &documentPathError{Path: joinPath("coitems", name), Err: fmt.Errorf("coitem %s: %w", name, err)}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(doc.Processes)) {
+		err := cmp.Or(doc.Processes[name], &DocumentProcess{}).write(body, name)
+		if err != nil {
+			return nil, &documentPathError{Path: joinPath("processes", name), Err: fmt.Errorf("process %s: %w", name, err)}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(doc.CoProcesses)) {
+		err := cmp.Or(doc.CoProcesses[name], &DocumentCoProcess{}).write(body, name)
+		if err != nil {
+			return nil, &documentPathError{Path: joinPath("coprocesses", name), Err: fmt.Errorf("coprocess %s: %w", name, err)}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(doc.Contracts)) {
+		path := joinPath("contracts", name)
+		block, err := newBlock(body, "contract", name)
+		if err != nil {
+			return nil, &documentPathError{Path: path, Err: err}
+		}
+		err = setDetails(block.Body(), doc.Contracts[name], nil)
+		if err != nil {
+			return nil, &documentPathError{Path: path, Err: fmt.Errorf("contract %s: %w", name, err)}
+		}
+	}
+	return f.Bytes(), nil
+}
+
+func newBlock(body *hclwrite.Body, typ string, name string) (*hclwrite.Block, error) {
+	if !hclsyntax.ValidIdentifier(name) {
+		return nil, fmt.Errorf("%s name %q is not a valid identifier", typ, name)
+	}
+	return body.AppendNewBlock(typ, []string{name}), nil
+}
+
+func setString(body *hclwrite.Body, name string, value string) {
+	if value != "" {
+		body.SetAttributeValue(name, cty.StringVal(value))
+	}
+}
+
+func (item *DocumentItem) write(parent *hclwrite.Body, name string) error {
+	block, err := newBlock(parent, "item", name)
+	if err != nil {
+		return err
+	}
+	body := block.Body()
+	setString(body, "part_number", item.PartNumber)
+	setString(body, "source", item.Source)
+	setString(body, "unit", item.Unit)
+	if item.From != nil {
+		err = setLines(body, "from", item.From)
+		if err != nil {
+			return err
+		}
+	}
+	if item.Impl != nil {
+		err = setRefs(body, "impl", item.Impl)
+		if err != nil {
+			return err
+		}
+	}
+	err = setDetails(body, item.Details, RESERVED)
+	if err != nil {
+		return err
+	}
+	return writeArtifacts(body, item.Artifacts)
+}
+
+func (coItem *DocumentCoItem) write(parent *hclwrite.Body, name string) error {
+	block, err := newBlock(parent, "coitem", name)
+	if err != nil {
+		return err
+	}
+	body := block.Body()
+	setString(body, "part_number", coItem.PartNumber)
+	setString(body, "source", coItem.Source)
+	setString(body, "unit", coItem.Unit)
+	if coItem.Req != nil {
+		err = setRefs(body, "req", coItem.Req)
+		if err != nil {
+			return err
+		}
+	}
+	err = setDetails(body, coItem.Details, RESERVED)
+	if err != nil {
+		return err
+	}
+	return writeArtifacts(body, coItem.Artifacts)
+}
+
+func (proc *DocumentProcess) write(parent *hclwrite.Body, name string) error {
+	block, err := newBlock(parent, "process", name)
+	if err != nil {
+		return err
+	}
+	body := block.Body()
+	setString(body, "type", proc.Type)
+	if proc.Input != nil {
+		err = setLines(body, "input", proc.Input)
+		if err != nil {
+			return err
+		}
+	}
+	err = setLines(body, "output", proc.Output)
+	if err != nil {
+		return err
+	}
+	return setDetails(body, proc.Details, PROCESS_RESERVED)
+}
+
+func (cp *DocumentCoProcess) write(parent *hclwrite.Body, name string) error {
+	block, err := newBlock(parent, "coprocess", name)
+	if err != nil {
+		return err
+	}
+	body := block.Body()
+	from, err := refTokens(cp.From)
+	if err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+	body.SetAttributeRaw("from", from)
+	to, err := refTokens(cp.To)
+	if err != nil {
+		return fmt.Errorf("to: %w", err)
+	}
+	body.SetAttributeRaw("to", to)
+	if cp.Priority != nil {
+		body.SetAttributeValue("priority", cty.NumberFloatVal(*cp.Priority))
+	}
+	reserved := maps.Clone(COPROCESS_RESERVED)
+	reserved["priority"] = struct{}{}
+	return setDetails(body, cp.Details, reserved)
+}
+
+func writeArtifacts(body *hclwrite.Body, artifacts map[string]*DocumentArtifact) error {
+	for _, name := range slices.Sorted(maps.Keys(artifacts)) {
+		a := artifacts[name]
+		if a.Source == "" {
+			return fmt.Errorf("artifact %s requires a source", name)
+		}
+		block := body.AppendNewBlock("artifact", []string{name})
+		block.Body().SetAttributeValue("filename", cty.StringVal(a.Filename))
+		block.Body().SetAttributeValue("tag", cty.StringVal(a.Tag))
+		block.Body().SetAttributeValue("source", cty.StringVal(a.Source))
+	}
+	return nil
+}
+
+// refTokens parses a reference such as "std.bolt" or `bolt["m3"]`.
+func refTokens(ref string) (hclwrite.Tokens, error) {
+	traversal, diags := hclsyntax.ParseTraversalAbs([]byte(ref), "", hcl.InitialPos)
+	if diags.HasErrors() || ref == "" {
+		return nil, fmt.Errorf("invalid reference %q", ref)
+	}
+	return hclwrite.TokensForTraversal(traversal), nil
+}
+
+func setRefs(body *hclwrite.Body, name string, refs []string) error {
+	elems := make([]hclwrite.Tokens, 0, len(refs))
+	for _, ref := range refs {
+		tokens, err := refTokens(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		elems = append(elems, tokens)
+	}
+	body.SetAttributeRaw(name, hclwrite.TokensForTuple(elems))
+	return nil
+}
+
+func setLines(body *hclwrite.Body, name string, lines []DocumentLine) error {
+	elems := make([]hclwrite.Tokens, 0, len(lines))
+	for i, line := range lines {
+		attrs := make([]hclwrite.ObjectAttrTokens, 0, len(line))
+		for _, key := range canonicalKeyOrder(slices.Collect(maps.Keys(line)), DOCUMENT_LINE_KEYS) {
+			var tokens hclwrite.Tokens
+			var err error
+			switch key {
+			case "ref":
+				ref, ok := line[key].(string)
+				if !ok {
+					return fmt.Errorf("%s[%d]: ref must be a string", name, i)
+				}
+				tokens, err = refTokens(ref)
+			default:
+				if !slices.Contains(DOCUMENT_LINE_KEYS, key) {
+					return fmt.Errorf("%s[%d]: unknown key %q", name, i, key)
+				}
+				tokens, err = valueTokens(line[key])
+			}
+			if err != nil {
+				return fmt.Errorf("%s[%d]: %s: %w", name, i, key, err)
+			}
+			attrs = append(attrs, hclwrite.ObjectAttrTokens{
+				Name:  hclwrite.TokensForIdentifier(key),
+				Value: tokens,
+			})
+		}
+		elems = append(elems, hclwrite.TokensForObject(attrs))
+	}
+	body.SetAttributeRaw(name, hclwrite.TokensForTuple(elems))
+	return nil
+}
+
+// setDetails writes details as attributes, in sorted order.
+func setDetails(body *hclwrite.Body, details map[string]any, reserved map[string]struct{}) error {
+	for _, key := range slices.Sorted(maps.Keys(details)) {
+		if _, ok := reserved[key]; ok {
+			return fmt.Errorf("detail %s is a reserved key", key)
+		}
+		if !hclsyntax.ValidIdentifier(key) {
+			return fmt.Errorf("detail %q is not a valid identifier", key)
+		}
+		tokens, err := valueTokens(details[key])
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		body.SetAttributeRaw(key, tokens)
+	}
+	return nil
+}
+
+func valueTokens(v any) (hclwrite.Tokens, error) {
+	val, err := documentValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return hclwrite.TokensForValue(val), nil
+}
+
+// documentValue converts a decoded JSON or YAML value. Strings are kept as
+// they are, without template interpolation.
+func documentValue(v any) (cty.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return cty.NullVal(cty.DynamicPseudoType), nil
+	case string:
+		return cty.StringVal(v), nil
+	case bool:
+		return cty.BoolVal(v), nil
+	case json.Number:
+		return cty.ParseNumberVal(v.String())
+	case int:
+		return cty.NumberIntVal(int64(v)), nil
+	case int64:
+		return cty.NumberIntVal(v), nil
+	case uint64:
+		return cty.NumberVal(new(big.Float).SetUint64(v)), nil
+	case float64:
+		return cty.NumberFloatVal(v), nil
+	case []any:
+		if len(v) == 0 {
+			return cty.EmptyTupleVal, nil
+		}
+		elems := make([]cty.Value, 0, len(v))
+		for _, elem := range v {
+			converted, err := documentValue(elem)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elems = append(elems, converted)
+		}
+		return cty.TupleVal(elems), nil
+	case stable.Map:
+		return documentValue(map[string]any(v))
+	case map[string]any:
+		if len(v) == 0 {
+			return cty.EmptyObjectVal, nil
+		}
+		attrs := make(map[string]cty.Value, len(v))
+		for key, elem := range v {
+			converted, err := documentValue(elem)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			attrs[key] = converted
+		}
+		return cty.ObjectVal(attrs), nil
+	default:
+		return cty.NilVal, fmt.Errorf("unsupported value %v", v)
+	}
+}
@@ -0,0 +1,344 @@
+package hcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+// documentSections maps block types to the document section holding them.
+var documentSections = map[string]string{
+	"item":      "items",
+	"coitem":    "coitems",
+	"process":   "processes",
+	"coprocess": "coprocesses",
+	"contract":  "contracts",
+}
+
+// positions holds where the values of a document are written, by path such
+// as items.cart.from.0.ref, and where the keys naming them are.
+type positions struct {
+	filename string
+	src      []byte
+	lines    []int
+	values   map[string]hcl.Range
+	keys     map[string]hcl.Range
+}
+
+func newPositions(src []byte, filename string) *positions {
+	lines := []int{0}
+	for i, b := range src {
+		if b == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &positions{
+		filename: filename,
+		src:      src,
+		lines:    lines,
+		values:   make(map[string]hcl.Range),
+		keys:     make(map[string]hcl.Range),
+	}
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// pos converts a byte offset of the document.
+func (m *positions) pos(offset int) hcl.Pos {
+	offset = min(max(offset, 0), len(m.src))
+	line := sort.Search(len(m.lines), func(i int) bool { return m.lines[i] > offset }) - 1
+	return hcl.Pos{
+		Line:   line + 1,
+		Column: utf8.RuneCount(m.src[m.lines[line]:offset]) + 1,
+		Byte:   offset,
+	}
+}
+
+// offset converts a 1-based line and column of the document.
+func (m *positions) offset(line int, column int) int {
+	if line < 1 || line > len(m.lines) {
+		return len(m.src)
+	}
+	offset := m.lines[line-1]
+	for range column - 1 {
+		if offset >= len(m.src) || m.src[offset] == '\n' {
+			break
+		}
+		_, size := utf8.DecodeRune(m.src[offset:])
+		offset += size
+	}
+	return offset
+}
+
+func (m *positions) span(start int, end int) hcl.Range {
+	return hcl.Range{Filename: m.filename, Start: m.pos(start), End: m.pos(end)}
+}
+
+// skip moves past the separators before the next JSON token.
+func (m *positions) skip(offset int) int {
+	for offset < len(m.src) {
+		switch m.src[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+func locateJSON(src []byte, filename string) (*positions, error) {
+	m := newPositions(src, filename)
+	decoder := json.NewDecoder(bytes.NewReader(src))
+	decoder.UseNumber()
+	return m, m.jsonValue(decoder, "")
+}
+
+func (m *positions) jsonValue(decoder *json.Decoder, path string) error {
+	start := m.skip(int(decoder.InputOffset()))
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	switch token {
+	case json.Delim('{'):
+		for decoder.More() {
+			keyStart := m.skip(int(decoder.InputOffset()))
+			key, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			name := joinPath(path, fmt.Sprint(key))
+			m.keys[name] = m.span(keyStart, int(decoder.InputOffset()))
+			err = m.jsonValue(decoder, name)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+	case json.Delim('['):
+		for i := 0; decoder.More(); i++ {
+			err = m.jsonValue(decoder, joinPath(path, strconv.Itoa(i)))
+			if err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+	}
+	m.values[path] = m.span(start, int(decoder.InputOffset()))
+	return err
+}
+
+func locateYAML(src []byte, filename string) (*positions, error) {
+	m := newPositions(src, filename)
+	root := &yaml.Node{}
+	err := yaml.Unmarshal(src, root)
+	if err != nil {
+		return nil, err
+	}
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		m.yamlValue(root.Content[0], "")
+	}
+	return m, nil
+}
+
+// yamlValue records the range of a node and returns the offset it ends at.
+// YAML nodes only know where they start, so scalars end after their value
+// and collections after their last element.
+func (m *positions) yamlValue(node *yaml.Node, path string) int {
+	start := m.offset(node.Line, node.Column)
+	end := start
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			name := joinPath(path, key.Value)
+			keyStart := m.offset(key.Line, key.Column)
+			m.keys[name] = m.span(keyStart, m.scalarEnd(key, keyStart))
+			end = max(end, m.yamlValue(value, name))
+		}
+	case yaml.SequenceNode:
+		for i, elem := range node.Content {
+			end = max(end, m.yamlValue(elem, joinPath(path, strconv.Itoa(i))))
+		}
+	default:
+		end = m.scalarEnd(node, start)
+	}
+	m.values[path] = m.span(start, end)
+	return end
+}
+
+func (m *positions) scalarEnd(node *yaml.Node, start int) int {
+	length := len(node.Value)
+	if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		length += 2
+	}
+	end := start
+	for end < len(m.src) && end-start < length && m.src[end] != '\n' {
+		end++
+	}
+	return end
+}
+
+// remap points the ranges of the file translated from a document back into
+// the document. Blocks take the range of their name and attributes the
+// range of their value, down to the elements and keys of BOM lines.
+func (m *positions) remap(file *hcl.File) {
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return
+	}
+	whole := m.span(0, len(m.src))
+	setRanges(body, whole)
+	imports := 0
+	for _, block := range body.Blocks {
+		var path string
+		if block.Type == "import" {
+			path = joinPath("imports", strconv.Itoa(imports))
+			imports++
+		} else if len(block.Labels) > 0 {
+			path = joinPath(documentSections[block.Type], block.Labels[0])
+		}
+		m.remapBlock(block, path, whole)
+	}
+}
+
+func (m *positions) nameRange(path string, fallback hcl.Range) hcl.Range {
+	if r, ok := m.keys[path]; ok {
+		return r
+	}
+	if r, ok := m.values[path]; ok {
+		return r
+	}
+	return fallback
+}
+
+func (m *positions) remapBlock(block *hclsyntax.Block, path string, fallback hcl.Range) {
+	r := m.nameRange(path, fallback)
+	setRanges(block, r)
+	for name, attr := range block.Body.Attributes {
+		attrPath := joinPath(path, name)
+		if _, ok := m.values[attrPath]; !ok {
+			attrPath = joinPath(joinPath(path, "details"), name)
+		}
+		value, ok := m.values[attrPath]
+		if !ok {
+			value = r
+		}
+		setRanges(attr, value)
+		attr.NameRange = m.nameRange(attrPath, value)
+		m.remapExpr(attr.Expr, attrPath)
+	}
+	for _, nested := range block.Body.Blocks {
+		if len(nested.Labels) > 0 {
+			m.remapBlock(nested, joinPath(joinPath(path, "artifacts"), nested.Labels[0]), r)
+		}
+	}
+}
+
+func (m *positions) remapExpr(expr hclsyntax.Expression, path string) {
+	switch e := expr.(type) {
+	case *hclsyntax.TupleConsExpr:
+		for i, elem := range e.Exprs {
+			elemPath := joinPath(path, strconv.Itoa(i))
+			if r, ok := m.values[elemPath]; ok {
+				setRanges(elem, r)
+				m.remapExpr(elem, elemPath)
+			}
+		}
+	case *hclsyntax.ObjectConsExpr:
+		for _, item := range e.Items {
+			key := objectKey(item.KeyExpr)
+			if key == "" {
+				continue
+			}
+			itemPath := joinPath(path, key)
+			if r, ok := m.values[itemPath]; ok {
+				setRanges(item.ValueExpr, r)
+				m.remapExpr(item.ValueExpr, itemPath)
+			}
+			if r, ok := m.keys[itemPath]; ok {
+				setRanges(item.KeyExpr, r)
+			}
+		}
+	}
+}
+
+func objectKey(expr hclsyntax.Expression) string {
+	if key := hcl.ExprAsKeyword(expr); key != "" {
+		return key
+	}
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() || !val.IsKnown() || val.IsNull() || !val.Type().Equals(cty.String) {
+		return ""
+	}
+	return val.AsString()
+}
+
+var rangeType = reflect.TypeFor[hcl.Range]()
+
+// setRanges points every range held by a syntax node, its children
+// included, to r.
+func setRanges(node any, r hcl.Range) {
+	setValueRanges(reflect.ValueOf(node), r, make(map[uintptr]bool))
+}
+
+func setValueRanges(v reflect.Value, r hcl.Range, seen map[uintptr]bool) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() || seen[v.Pointer()] {
+			return
+		}
+		seen[v.Pointer()] = true
+		setValueRanges(v.Elem(), r, seen)
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		elem := v.Elem()
+		if elem.Kind() == reflect.Pointer || !v.CanSet() {
+			setValueRanges(elem, r, seen)
+			return
+		}
+		// Values held by interfaces, such as traversal steps, are copied
+		// to be modified.
+		copied := reflect.New(elem.Type()).Elem()
+		copied.Set(elem)
+		setValueRanges(copied, r, seen)
+		v.Set(copied)
+	case reflect.Struct:
+		if v.Type() == rangeType {
+			if v.CanSet() {
+				v.Set(reflect.ValueOf(r))
+			}
+			return
+		}
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				setValueRanges(v.Field(i), r, seen)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			setValueRanges(v.Index(i), r, seen)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			setValueRanges(v.MapIndex(key), r, seen)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package hcl_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+)
+
+const documentHCL = `
+contract "drive" {
+  voltage = 24
+}
+
+item "motor" {
+  part_number = "MT-1"
+  impl        = [drive]
+  note        = "rated $${voltage}"
+  ratio       = 1.1
+  tags        = ["a", "b"]
+
+  artifact "datasheet" {
+    filename = "motor.pdf"
+    tag      = "spec"
+    source   = "digest:abc"
+  }
+}
+
+coitem "any_motor" {
+  req = [drive]
+}
+
+item "cart" {
+  from = [
+    { name = "left", ref = motor, qty = 2, refdes = "M1..M2" },
+  ]
+}
+
+item "frame" {}
+
+process "frame_cnc" {
+  input  = [{ name = "motor", ref = any_motor, qty = "1 pcs" }]
+  output = [{ name = "frame", ref = frame }]
+  operation = "machining"
+}
+
+coprocess "motor_for_any" {
+  from     = motor
+  to       = any_motor
+  priority = 5
+}
+`
+
+const documentJSON = `{
+  "contracts": {"drive": {"voltage": 24}},
+  "items": {
+    "motor": {
+      "part_number": "MT-1",
+      "impl": ["drive"],
+      "details": {"note": "rated ${voltage}", "ratio": 1.1, "tags": ["a", "b"]},
+      "artifacts": {"datasheet": {"filename": "motor.pdf", "tag": "spec", "source": "digest:abc"}}
+    },
+    "cart": {
+      "from": [{"name": "left", "ref": "motor", "qty": 2, "refdes": "M1..M2"}]
+    },
+    "frame": {}
+  },
+  "coitems": {"any_motor": {"req": ["drive"]}},
+  "processes": {
+    "frame_cnc": {
+      "input": [{"name": "motor", "ref": "any_motor", "qty": "1 pcs"}],
+      "output": [{"name": "frame", "ref": "frame"}],
+      "details": {"operation": "machining"}
+    }
+  },
+  "coprocesses": {
+    "motor_for_any": {"from": "motor", "to": "any_motor", "priority": 5}
+  }
+}`
+
+const documentYAML = `
+contracts:
+  drive:
+    voltage: 24
+items:
+  motor:
+    part_number: MT-1
+    impl: [drive]
+    details:
+      note: rated ${voltage}
+      ratio: 1.1
+      tags: [a, b]
+    artifacts:
+      datasheet:
+        filename: motor.pdf
+        tag: spec
+        source: digest:abc
+  cart:
+    from:
+      - {name: left, ref: motor, qty: 2, refdes: M1..M2}
+  frame:
+coitems:
+  any_motor:
+    req: [drive]
+processes:
+  frame_cnc:
+    input:
+      - {name: motor, ref: any_motor, qty: 1 pcs}
+    output:
+      - {name: frame, ref: frame}
+    details:
+      operation: machining
+coprocesses:
+  motor_for_any:
+    from: motor
+    to: any_motor
+    priority: 5
+`
+
+func TestBuildDocumentDigests(t *testing.T) {
+	qualifiers := []string{".drive", ".motor", ".any_motor", ".cart", ".cart.__process__", ".frame_cnc", ".motor_for_any"}
+	digests := func(files map[string]string) []string {
+		p := hcl.NewParser()
+		err := p.Build(writeFiles(t, files))
+		if err != nil {
+			t.Fatalf("Build error: %v", err)
+		}
+		ret := make([]string, 0, len(qualifiers))
+		for _, q := range qualifiers {
+			sym, err := p.Symbols.FindConcreteSymbol(q)
+			if err != nil {
+				t.Fatalf("%s not found: %v", q, err)
+			}
+			ret = append(ret, sym.GetDigest())
+		}
+		return ret
+	}
+	want := digests(map[string]string{"main.bpo": documentHCL})
+	for name, src := range map[string]string{"main.bpo.json": documentJSON, "main.bpo.yaml": documentYAML} {
+		got := digests(map[string]string{name: src})
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: digest of %s differs from the bpo source", name, qualifiers[i])
+			}
+		}
+	}
+}
+
+func TestBuildMixedSources(t *testing.T) {
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{
+		"parts.bpo.yaml": "items:\n  wheel:\n    part_number: WH-1\n",
+		"cart.bpo":       `item "cart" { from = [{ name = "wheel", ref = wheel, qty = 4 }] }`,
+	}))
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	findItem(t, p, ".cart")
+
+	p = hcl.NewParser()
+	err = p.Build(writeFiles(t, map[string]string{
+		"parts.bpo.json": `{"items": {"wheel": {"partnumber": "WH-1"}}}`,
+	}))
+	diags := p.Diagnostics()
+	if err == nil || len(diags) != 1 || diags[0].Subject == nil {
+		t.Fatalf("expected a diagnostic for the unknown field, got %v", diags)
+	}
+}
+
+func TestBuildDocumentRanges(t *testing.T) {
+	sources := map[string]string{
+		"main.bpo.json": `{
+  "items": {
+    "cart": {
+      "from": [
+        {"name": "left", "ref": "wheel"},
+        {"name": "right", "ref": "missing"}
+      ]
+    },
+    "wheel": {}
+  }
+}`,
+		"main.bpo.yaml": `items:
+  cart:
+    from:
+      - {name: left, ref: wheel}
+      - {name: right, ref: missing}
+  wheel: {}
+`,
+	}
+	lines := map[string]int{"main.bpo.json": 6, "main.bpo.yaml": 5}
+	for name, src := range sources {
+		dir := writeFiles(t, map[string]string{name: src})
+		p := hcl.NewParser()
+		err := p.Build(dir)
+		diags := p.Diagnostics()
+		if err == nil || len(diags) != 1 || diags[0].Subject == nil {
+			t.Fatalf("%s: expected a diagnostic for the missing ref, got %v", name, diags)
+		}
+		if line := diags[0].Subject.Start.Line; line != lines[name] {
+			t.Errorf("%s: diagnostic on line %d, want %d", name, line, lines[name])
+		}
+		file := p.Files()[filepath.Join(dir, name)]
+		if file == nil || string(file.Bytes) != src {
+			t.Errorf("%s: snippets should be taken from the document", name)
+		}
+	}
+
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{
+		"main.bpo.yaml": "items:\n  wheel: {}\n  \"1bad\": {}\n",
+	}))
+	diags := p.Diagnostics()
+	if err == nil || len(diags) != 1 || diags[0].Subject == nil || diags[0].Subject.Start.Line != 3 {
+		t.Errorf("expected an invalid name on line 3, got %v", diags)
+	}
+}
@@ -0,0 +1,217 @@
+package hcl
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"strconv"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/tychonis/cyanotype/internal/cerror"
+	"github.com/tychonis/cyanotype/model"
+)
+
+// UnprocessedFamily is a block with a `for_each` or `count` meta-argument.
+// It expands into one UnprocessedSymbol per instance once variables and
+// locals are evaluated.
+type UnprocessedFamily struct {
+	Context *ParserContext
+	Block   *hclsyntax.Block
+
+	Members map[string]*UnprocessedSymbol
+}
+
+func (f *UnprocessedFamily) Resolve(path []string) (model.Symbol, error) {
+	if len(path) == 0 {
+		return f, nil
+	}
+	if f.Members == nil {
+		return nil, errors.New("family not expanded yet")
+	}
+	member, ok := f.Members[path[0]]
+	if !ok {
+		return nil, fmt.Errorf("instance %s not found in %s", path[0], f.Block.Labels[0])
+	}
+	return member.Resolve(path[1:])
+}
+
+// SortedKeys returns the instance keys in a deterministic order.
+func (f *UnprocessedFamily) SortedKeys() []string {
+	return slices.Sorted(maps.Keys(f.Members))
+}
+
+func isFamilyBlock(block *hclsyntax.Block) bool {
+	_, forEach := block.Body.Attributes["for_each"]
+	_, count := block.Body.Attributes["count"]
+	return forEach || count
+}
+
+// InstanceKey renders an instance key the way it is written in a traversal,
+// e.g. `["M3x10"]` or `[0]`.
+func InstanceKey(key cty.Value) (string, error) {
+	if key.IsNull() || !key.IsKnown() {
+		return "", errors.New("instance key must be known")
+	}
+	switch key.Type() {
+	case cty.String:
+		return "[" + strconv.Quote(key.AsString()) + "]", nil
+	case cty.Number:
+		bf := key.AsBigFloat()
+		if !bf.IsInt() {
+			return "", errors.New("instance index must be an integer")
+		}
+		i, _ := bf.Int(new(big.Int))
+		return "[" + i.String() + "]", nil
+	default:
+		return "", fmt.Errorf("instance key must be a string or number, got %s", key.Type().FriendlyName())
+	}
+}
+
+// Instance returns a context for one member of a family. The member shares
+// the module scope, with `each` or `count` added to its evaluation context.
+func (ctx *ParserContext) Instance(key string, vars map[string]cty.Value) *ParserContext {
+	eval := ctx.Eval.NewChild()
+	eval.Variables = vars
+	return &ParserContext{
+		ImportStack: ctx.ImportStack,
+		Variables:   ctx.Variables,
+		Locals:      ctx.Locals,
+		Eval:        eval,
+		InstanceKey: key,
+	}
+}
+
+// BlockName returns the name of the symbol defined by block, including the
+// instance key for members of a family.
+func (ctx *ParserContext) BlockName(block *hclsyntax.Block) string {
+	return block.Labels[0] + ctx.InstanceKey
+}
+
+func (f *UnprocessedFamily) addMember(key cty.Value, vars map[string]cty.Value, r hcl.Range) error {
+	name, err := InstanceKey(key)
+	if err != nil {
+		return cerror.ErrorWithRange(err.Error(), r)
+	}
+	if _, ok := f.Members[name]; ok {
+		return cerror.ErrorWithRange("duplicate instance key "+name, r)
+	}
+	f.Members[name] = &UnprocessedSymbol{
+		Context: f.Context.Instance(name, vars),
+		Block:   f.Block,
+	}
+	return nil
+}
+
+func (f *UnprocessedFamily) expandForEach(attr *hclsyntax.Attribute) error {
+	val, diags := attr.Expr.Value(f.Context.Eval)
+	if diags.HasErrors() {
+		return diags
+	}
+	if val.IsNull() || !val.IsKnown() {
+		return cerror.ErrorWithRange("for_each must be known", attr.SrcRange)
+	}
+	ty := val.Type()
+	switch {
+	case ty.IsMapType() || ty.IsObjectType():
+		for it := val.ElementIterator(); it.Next(); {
+			key, value := it.Element()
+			vars := map[string]cty.Value{
+				"each": cty.ObjectVal(map[string]cty.Value{"key": key, "value": value}),
+			}
+			err := f.addMember(key, vars, attr.SrcRange)
+			if err != nil {
+				return err
+			}
+		}
+	case ty.IsSetType() || ty.IsListType() || ty.IsTupleType():
+		for it := val.ElementIterator(); it.Next(); {
+			_, value := it.Element()
+			if value.Type() != cty.String {
+				return cerror.ErrorWithRange("for_each over a collection requires strings", attr.SrcRange)
+			}
+			vars := map[string]cty.Value{
+				"each": cty.ObjectVal(map[string]cty.Value{"key": value, "value": value}),
+			}
+			err := f.addMember(value, vars, attr.SrcRange)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return cerror.ErrorWithRange("for_each must be a map, object or set of strings", attr.SrcRange)
+	}
+	return nil
+}
+
+func (f *UnprocessedFamily) expandCount(attr *hclsyntax.Attribute) error {
+	val, diags := attr.Expr.Value(f.Context.Eval)
+	if diags.HasErrors() {
+		return diags
+	}
+	if val.Type() != cty.Number || val.IsNull() || !val.IsKnown() {
+		return cerror.ErrorWithRange("count must be a number", attr.SrcRange)
+	}
+	bf := val.AsBigFloat()
+	count, accuracy := bf.Int64()
+	if accuracy != big.Exact || count < 0 {
+		return cerror.ErrorWithRange("count must be a non-negative integer", attr.SrcRange)
+	}
+	for i := range count {
+		index := cty.NumberIntVal(i)
+		vars := map[string]cty.Value{
+			"count": cty.ObjectVal(map[string]cty.Value{"index": index}),
+		}
+		err := f.addMember(index, vars, attr.SrcRange)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *UnprocessedFamily) Expand() error {
+	f.Members = make(map[string]*UnprocessedSymbol)
+	forEach, hasForEach := f.Block.Body.Attributes["for_each"]
+	count, hasCount := f.Block.Body.Attributes["count"]
+	switch {
+	case hasForEach && hasCount:
+		return cerror.ErrorWithRange("for_each and count cannot be used together", f.Block.DefRange())
+	case hasForEach:
+		return f.expandForEach(forEach)
+	default:
+		return f.expandCount(count)
+	}
+}
+
+func (p *Parser) registerFamilyBlock(ctx *ParserContext, block *hclsyntax.Block) error {
+	if block.Type != "item" && block.Type != "coitem" {
+		return cerror.ErrorWithRange("for_each and count are only supported on item and coitem", block.DefRange())
+	}
+	family := &UnprocessedFamily{
+		Context: ctx,
+		Block:   block,
+	}
+	return p.Symbols.AddSymbol(ctx.CurrentModule(), block.Labels[0], family)
+}
+
+func (p *Parser) expandFamilies() error {
+	for _, module := range slices.Sorted(maps.Keys(p.Symbols.Modules)) {
+		scope := p.Symbols.Modules[module].Symbols
+		for _, name := range slices.Sorted(maps.Keys(scope)) {
+			family, ok := scope[name].(*UnprocessedFamily)
+			if !ok {
+				continue
+			}
+			err := family.Expand()
+			if err != nil {
+				p.report(err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package hcl
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// RESERVED_ORDER is the canonical order of reserved keys in a formatted block.
+var RESERVED_ORDER = []string{"for_each", "count", "type", "part_number", "source", "unit", "from", "to", "input", "output", "impl", "req"}
+
+// BOM_LINE_ATTRIBUTES are the attributes holding a list of BOM lines.
+var BOM_LINE_ATTRIBUTES = []string{"from", "input", "output"}
+
+// BOM_LINE_ORDER is the canonical order of keys in a formatted BOM line.
+var BOM_LINE_ORDER = []string{"name", "ref", "qty", "unit", "refdes"}
+
+// Format returns the canonical formatting of a bpo source file.
+func Format(src []byte, filename string) ([]byte, error) {
+	wf, diags := hclwrite.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	sf, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	body, ok := sf.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse content")
+	}
+	for i, block := range wf.Body().Blocks() {
+		if i >= len(body.Blocks) {
+			break
+		}
+		formatBody(src, block.Body(), body.Blocks[i].Body)
+	}
+	return hclwrite.Format(wf.Bytes()), nil
+}
+
+func formatBody(src []byte, wb *hclwrite.Body, sb *hclsyntax.Body) {
+	for i, block := range wb.Blocks() {
+		if i >= len(sb.Blocks) {
+			break
+		}
+		formatBody(src, block.Body(), sb.Blocks[i].Body)
+	}
+
+	for _, name := range BOM_LINE_ATTRIBUTES {
+		attr, ok := sb.Attributes[name]
+		if !ok {
+			continue
+		}
+		text, ok := formatBOMLines(src, attr.Expr)
+		if ok {
+			wb.SetAttributeRaw(name, rawTokens(text))
+		}
+	}
+
+	reorderAttributes(wb, sb)
+}
+
+func rawTokens(text string) hclwrite.Tokens {
+	return hclwrite.Tokens{{Type: hclsyntax.TokenIdent, Bytes: []byte(text)}}
+}
+
+func sourceOf(src []byte, r hcl.Range) string {
+	return string(src[r.Start.Byte:r.End.Byte])
+}
+
+func hasComment(src []byte, r hcl.Range) bool {
+	tokens, _ := hclsyntax.LexExpression(src[r.Start.Byte:r.End.Byte], r.Filename, r.Start)
+	for _, token := range tokens {
+		if token.Type == hclsyntax.TokenComment {
+			return true
+		}
+	}
+	return false
+}
+
+func canonicalKeyOrder(keys []string, order []string) []string {
+	ret := make([]string, 0, len(keys))
+	for _, key := range order {
+		if slices.Contains(keys, key) {
+			ret = append(ret, key)
+		}
+	}
+	for _, key := range keys {
+		if !slices.Contains(order, key) {
+			ret = append(ret, key)
+		}
+	}
+	return ret
+}
+
+// formatBOMLines rewrites a `from` tuple so every BOM line is a multi-line
+// object with keys in canonical order. Expressions containing comments are
+// left untouched so no comment is ever dropped.
+func formatBOMLines(src []byte, expr hclsyntax.Expression) (string, bool) {
+	tuple, ok := expr.(*hclsyntax.TupleConsExpr)
+	if !ok || hasComment(src, tuple.SrcRange) {
+		return "", false
+	}
+	buf := &strings.Builder{}
+	buf.WriteString("[\n")
+	for _, elem := range tuple.Exprs {
+		obj, ok := elem.(*hclsyntax.ObjectConsExpr)
+		if !ok {
+			return "", false
+		}
+		values := make(map[string]hclsyntax.Expression, len(obj.Items))
+		keys := make([]string, 0, len(obj.Items))
+		for _, item := range obj.Items {
+			key := getObjectKey(item.KeyExpr)
+			if key == "" {
+				return "", false
+			}
+			keys = append(keys, key)
+			values[key] = item.ValueExpr
+		}
+		buf.WriteString("{\n")
+		for _, key := range canonicalKeyOrder(keys, BOM_LINE_ORDER) {
+			fmt.Fprintf(buf, "%s = %s\n", key, formatBOMLineValue(src, key, values[key]))
+		}
+		buf.WriteString("},\n")
+	}
+	buf.WriteString("]")
+	return buf.String(), true
+}
+
+func formatBOMLineValue(src []byte, key string, expr hclsyntax.Expression) string {
+	if key != "placement" {
+		return sourceOf(src, expr.Range())
+	}
+	tuple, ok := expr.(*hclsyntax.TupleConsExpr)
+	if !ok {
+		return sourceOf(src, expr.Range())
+	}
+	elems := make([]string, 0, len(tuple.Exprs))
+	for _, elem := range tuple.Exprs {
+		elems = append(elems, sourceOf(src, elem.Range()))
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+func countComments(tokens hclwrite.Tokens) int {
+	count := 0
+	for _, token := range tokens {
+		if token.Type == hclsyntax.TokenComment {
+			count++
+		}
+	}
+	return count
+}
+
+// reorderAttributes moves reserved keys to the top of a body. The body is
+// only rebuilt when the order changes and every comment belongs to an
+// attribute or a nested block, otherwise free standing comments would be lost.
+func reorderAttributes(wb *hclwrite.Body, sb *hclsyntax.Body) {
+	current := make([]string, 0, len(sb.Attributes))
+	for name := range sb.Attributes {
+		current = append(current, name)
+	}
+	sort.Slice(current, func(i, j int) bool {
+		return sb.Attributes[current[i]].SrcRange.Start.Byte < sb.Attributes[current[j]].SrcRange.Start.Byte
+	})
+	canonical := canonicalKeyOrder(current, RESERVED_ORDER)
+	if slices.Equal(current, canonical) {
+		return
+	}
+
+	attrs := wb.Attributes()
+	blocks := wb.Blocks()
+	owned := 0
+	for _, attr := range attrs {
+		owned += countComments(attr.BuildTokens(nil))
+	}
+	for _, block := range blocks {
+		owned += countComments(block.BuildTokens(nil))
+	}
+	if owned != countComments(wb.BuildTokens(nil)) {
+		return
+	}
+
+	tokens := make([]hclwrite.Tokens, 0, len(canonical)+len(blocks))
+	for _, name := range canonical {
+		tokens = append(tokens, attrs[name].BuildTokens(nil))
+	}
+	for _, block := range blocks {
+		tokens = append(tokens, block.BuildTokens(nil))
+	}
+
+	// The newline following the opening brace belongs to the body.
+	wb.Clear()
+	wb.AppendNewline()
+	for i, ts := range tokens {
+		if i == len(canonical) && i > 0 {
+			wb.AppendNewline()
+		}
+		wb.AppendUnstructuredTokens(ts)
+		if !bytes.HasSuffix(ts.Bytes(), []byte("\n")) {
+			wb.AppendNewline()
+		}
+	}
+}
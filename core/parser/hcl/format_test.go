@@ -0,0 +1,80 @@
+package hcl_test
+
+import (
+	"testing"
+
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+)
+
+func TestFormatCanonical(t *testing.T) {
+	src := `item "assembly" {
+  description = "x"
+  from = [
+    { qty = 1, name = "deck", ref = deck },
+    {
+      ref = wheel
+      name = "wheel"
+      placement = [0,0,0,1,   1,2,3]
+    },
+  ]
+    part_number="A-1"
+}
+`
+	want := `item "assembly" {
+  part_number = "A-1"
+  from = [
+    {
+      name = "deck"
+      ref  = deck
+      qty  = 1
+    },
+    {
+      name      = "wheel"
+      ref       = wheel
+      placement = [0, 0, 0, 1, 1, 2, 3]
+    },
+  ]
+  description = "x"
+}
+`
+	got, err := hcl.Format([]byte(src), "test.bpo")
+	if err != nil {
+		t.Fatalf("Format error: %v", err)
+	}
+	if string(got) != want {
+		t.Fatalf("want:\n%s\ngot:\n%s", want, string(got))
+	}
+
+	again, err := hcl.Format(got, "test.bpo")
+	if err != nil {
+		t.Fatalf("Format error: %v", err)
+	}
+	if string(again) != string(got) {
+		t.Fatalf("format is not idempotent:\n%s", string(again))
+	}
+}
+
+func TestFormatKeepsFreeStandingComments(t *testing.T) {
+	src := `item "deck" {
+  material = "maple"
+
+  # free standing comment
+
+  part_number = "D-1001"
+}
+`
+	got, err := hcl.Format([]byte(src), "test.bpo")
+	if err != nil {
+		t.Fatalf("Format error: %v", err)
+	}
+	if string(got) != src {
+		t.Fatalf("want:\n%s\ngot:\n%s", src, string(got))
+	}
+}
+
+func TestFormatRejectsInvalidSyntax(t *testing.T) {
+	_, err := hcl.Format([]byte(`item "deck" {`), "test.bpo")
+	if err == nil {
+		t.Fatal("expected error for invalid syntax")
+	}
+}
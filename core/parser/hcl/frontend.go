@@ -0,0 +1,54 @@
+package hcl
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// Frontend reads a source file into the HCL syntax tree symbols are built
+// from. Every frontend goes through the same parser, so equivalent sources
+// produce the same symbols and digests whatever their format.
+type Frontend interface {
+	Parse(files *hclparse.Parser, src []byte, filename string) (*hcl.File, hcl.Diagnostics)
+}
+
+// NativeFrontend reads .bpo files.
+type NativeFrontend struct{}
+
+func (NativeFrontend) Parse(files *hclparse.Parser, src []byte, filename string) (*hcl.File, hcl.Diagnostics) {
+	return files.ParseHCL(src, filename)
+}
+
+// FRONTENDS maps source extensions to the frontend reading them.
+var FRONTENDS = map[string]Frontend{
+	EXTENSION:           NativeFrontend{},
+	EXTENSION + ".json": DocumentFrontend{Decode: decodeJSON, Locate: locateJSON},
+	EXTENSION + ".yaml": DocumentFrontend{Decode: decodeYAML, Locate: locateYAML},
+	EXTENSION + ".yml":  DocumentFrontend{Decode: decodeYAML, Locate: locateYAML},
+}
+
+// FrontendFor selects the frontend of a file by its extension.
+func FrontendFor(filename string) (Frontend, bool) {
+	for ext, frontend := range FRONTENDS {
+		if strings.HasSuffix(filename, ext) {
+			return frontend, true
+		}
+	}
+	return nil, false
+}
+
+// IsSource reports whether a file is read by one of the frontends.
+func IsSource(filename string) bool {
+	_, ok := FrontendFor(filename)
+	return ok
+}
+
+func (p *Parser) parseSource(src []byte, filename string) (*hcl.File, hcl.Diagnostics) {
+	frontend, ok := FrontendFor(filename)
+	if !ok {
+		frontend = NativeFrontend{}
+	}
+	return p.cachedParse(frontend, src, filename)
+}
@@ -0,0 +1,176 @@
+package hcl
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+const GIT_PREFIX string = "git::"
+
+// GitSource is an import of the form git::<url>//<dir>?ref=<commit>.
+type GitSource struct {
+	URL string
+	Dir string
+	Ref string
+}
+
+func isGitSource(label string) bool {
+	return strings.HasPrefix(label, GIT_PREFIX)
+}
+
+func parseGitSource(label string) (*GitSource, error) {
+	rest := strings.TrimPrefix(label, GIT_PREFIX)
+	src := &GitSource{}
+	if i := strings.LastIndex(rest, "?"); i >= 0 {
+		query, err := url.ParseQuery(rest[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid git source query: %w", err)
+		}
+		src.Ref = query.Get("ref")
+		rest = rest[:i]
+	}
+	// Skip the "//" of the scheme before looking for the subdirectory.
+	start := 0
+	if i := strings.Index(rest, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	if i := strings.Index(rest[start:], "//"); i >= 0 {
+		src.Dir = strings.Trim(rest[start+i+2:], "/")
+		rest = rest[:start+i]
+	}
+	src.URL = rest
+	if src.URL == "" {
+		return nil, errors.New("git source has no repository url")
+	}
+	return src, nil
+}
+
+// parsePinnedGitSource parses a git source written directly in an import
+// block, which must name the commit it reads.
+func parsePinnedGitSource(label string) (*GitSource, error) {
+	src, err := parseGitSource(label)
+	if err != nil {
+		return nil, err
+	}
+	if !plumbing.IsHash(src.Ref) {
+		return nil, fmt.Errorf("git source %s must pin ref to a full commit hash", src.URL)
+	}
+	return src, nil
+}
+
+// Identifier names the module without its ref, so that qualifiers stay the
+// same when a library is bumped to a new commit.
+func (s *GitSource) Identifier() string {
+	if s.Dir == "" {
+		return GIT_PREFIX + s.URL
+	}
+	return GIT_PREFIX + s.URL + "//" + s.Dir
+}
+
+func (s *GitSource) ModuleName() string {
+	if s.Dir != "" {
+		return path.Base(s.Dir)
+	}
+	return strings.TrimSuffix(path.Base(s.URL), ".git")
+}
+
+// openRepository opens local repositories in place and clones remote ones
+// into memory. Neither checks out a worktree.
+func (p *Parser) openRepository(repoURL string) (*git.Repository, error) {
+	repo, ok := p.repos[repoURL]
+	if ok {
+		return repo, nil
+	}
+	var err error
+	if local, ok := strings.CutPrefix(repoURL, "file://"); ok {
+		repo, err = git.PlainOpen(filepath.FromSlash(local))
+	} else {
+		repo, err = git.Clone(memory.NewStorage(), nil, &git.CloneOptions{URL: repoURL})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open repository %s: %w", repoURL, err)
+	}
+	p.repos[repoURL] = repo
+	return repo, nil
+}
+
+// resolveGitRef turns a branch, tag or commit into a commit hash. Branches
+// of cloned repositories are only known as remote branches.
+func (p *Parser) resolveGitRef(repoURL string, ref string) (string, error) {
+	if plumbing.IsHash(ref) {
+		return ref, nil
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	repo, err := p.openRepository(repoURL)
+	if err != nil {
+		return "", err
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		hash, err = repo.ResolveRevision(plumbing.Revision("origin/" + ref))
+	}
+	if err != nil {
+		return "", fmt.Errorf("resolve %s of %s: %w", ref, repoURL, err)
+	}
+	return hash.String(), nil
+}
+
+// readGitSources reads the source files of src at a commit, straight from
+// the commit object.
+func (p *Parser) readGitSources(src *GitSource, commitHash string) ([]*sourceFile, error) {
+	repo, err := p.openRepository(src.URL)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(plumbing.NewHash(commitHash))
+	if err != nil {
+		return nil, fmt.Errorf("commit %s of %s: %w", commitHash, src.URL, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	if src.Dir != "" {
+		tree, err = tree.Tree(src.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("%s of commit %s: %w", src.Dir, commitHash, err)
+		}
+	}
+	ret := make([]*sourceFile, 0)
+	for _, entry := range tree.Entries {
+		if !entry.Mode.IsFile() || !IsSource(entry.Name) {
+			continue
+		}
+		f, err := tree.TreeEntryFile(&entry)
+		if err != nil {
+			return nil, err
+		}
+		content, err := f.Contents()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &sourceFile{
+			Name:     entry.Name,
+			Filename: src.Identifier() + "/" + entry.Name,
+			Content:  []byte(content),
+		})
+	}
+	return ret, nil
+}
+
+// Sources returns the commit each git import was read from, keyed by the
+// imported module.
+func (p *Parser) Sources() map[string]string {
+	return maps.Clone(p.sources)
+}
@@ -0,0 +1,94 @@
+package hcl_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+)
+
+func commitLibrary(t *testing.T, repo *git.Repository, dir string, partNumber string) string {
+	t.Helper()
+	err := os.WriteFile(filepath.Join(dir, "parts", "bolt.bpo"),
+		[]byte(`item "bolt" { part_number = "`+partNumber+`" }`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.Add("parts/bolt.bpo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := wt.Commit(partNumber, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash.String()
+}
+
+func TestBuildGitImport(t *testing.T) {
+	lib := t.TempDir()
+	err := os.MkdirAll(filepath.Join(lib, "parts"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := git.PlainInit(lib, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitLibrary(t, repo, lib, "B-1")
+	commitLibrary(t, repo, lib, "B-2")
+
+	source := "git::file://" + filepath.ToSlash(lib) + "//parts"
+	p := hcl.NewParser()
+	err = p.Build(writeFiles(t, map[string]string{"main.bpo": `
+import "` + source + `?ref=` + first + `" {}
+
+item "frame" {
+  from = [{ ref = parts.bolt, qty = 2 }]
+}
+`}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bolt := findItem(t, p, source+".bolt")
+	if bolt.Content.PartNumber != "B-1" {
+		t.Errorf("expected bolt from the pinned commit, got %s", bolt.Content.PartNumber)
+	}
+	if p.Sources()[source] != first {
+		t.Errorf("expected %s to be recorded at %s, got %v", source, first, p.Sources())
+	}
+
+	// Pinned sources are read once per cache, even if the repository goes.
+	cache := hcl.NewBuildCache()
+	main := writeFiles(t, map[string]string{"main.bpo": `
+import "` + source + `?ref=` + first + `" {}
+`})
+	for i := range 2 {
+		p = hcl.NewParser()
+		p.Options.Cache = cache
+		err = p.Build(main)
+		if err != nil {
+			t.Fatalf("build %d: %v", i, err)
+		}
+		os.RemoveAll(filepath.Join(lib, ".git"))
+	}
+
+	p = hcl.NewParser()
+	err = p.Build(writeFiles(t, map[string]string{"main.bpo": `
+import "` + source + `?ref=master" {}
+`}))
+	if err == nil {
+		t.Error("expected an import without a commit hash to fail")
+	}
+}
@@ -0,0 +1,197 @@
+package hcl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/tychonis/cyanotype/internal/cerror"
+	"github.com/tychonis/cyanotype/internal/symbols"
+)
+
+// sourceFile is a bpo file of an imported module.
+type sourceFile struct {
+	Name     string
+	Filename string
+	Content  []byte
+}
+
+// moduleSource describes where an import block reads its module from.
+type moduleSource struct {
+	// Identifier names the module in qualifiers.
+	Identifier string
+	// Name is the symbol the module is imported as, unless aliased.
+	Name string
+	// Commit is set for modules read from git.
+	Commit string
+	Read   func() ([]*sourceFile, error)
+}
+
+func readFolderSources(dir string, overlay map[string][]byte) ([]*sourceFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*sourceFile, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !IsSource(entry.Name()) {
+			continue
+		}
+		filename := filepath.Join(dir, entry.Name())
+		content, ok := overlay[filename]
+		if !ok {
+			content, err = os.ReadFile(filename)
+			if err != nil {
+				return nil, err
+			}
+		}
+		ret = append(ret, &sourceFile{Name: entry.Name(), Filename: filename, Content: content})
+	}
+	return ret, nil
+}
+
+func (p *Parser) parseSources(ctx *ParserContext, files []*sourceFile) {
+	for _, f := range files {
+		file, diags := p.parseSource(f.Content, f.Filename)
+		if diags.HasErrors() {
+			p.report(diags)
+			continue
+		}
+		err := p.parseBody(ctx, file)
+		if err != nil {
+			p.report(err)
+		}
+	}
+}
+
+// importAlias reads the optional `as` attribute of an import block.
+func importAlias(block *hclsyntax.Block) (string, error) {
+	attr, ok := block.Body.Attributes["as"]
+	if !ok {
+		return "", nil
+	}
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() {
+		return "", diags
+	}
+	if val.Type() != cty.String || val.IsNull() || !hclsyntax.ValidIdentifier(val.AsString()) {
+		return "", cerror.ErrorWithRange("import alias must be a valid identifier", attr.Expr.Range())
+	}
+	return val.AsString(), nil
+}
+
+func (p *Parser) dependencySource(dep *Dependency) (*moduleSource, error) {
+	ret := &moduleSource{Identifier: dep.Name, Name: dep.Name}
+	var read func() ([]*sourceFile, error)
+	if isGitSource(dep.Source) {
+		src, err := parseGitSource(dep.Source)
+		if err != nil {
+			return nil, err
+		}
+		ref := dep.Ref
+		if ref == "" {
+			ref = src.Ref
+		}
+		commit := p.lockedRef(dep)
+		if commit == "" {
+			commit, err = p.resolveGitRef(src.URL, ref)
+			if err != nil {
+				return nil, err
+			}
+		}
+		ret.Commit = commit
+		read = func() ([]*sourceFile, error) { return p.cachedGitSources(src, commit) }
+	} else {
+		dir := dep.Source
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(p.root, dir)
+		}
+		read = func() ([]*sourceFile, error) { return readFolderSources(dir, p.Options.Overlay) }
+	}
+	ret.Read = func() ([]*sourceFile, error) {
+		files, err := read()
+		if err != nil {
+			return nil, err
+		}
+		sum, err := sourceDigest(files)
+		if err != nil {
+			return nil, err
+		}
+		if dep.Digest != "" && dep.Digest != sum {
+			return nil, cerror.ErrorWithRange(
+				fmt.Sprintf("digest of dependency %s is %s, manifest expects %s", dep.Name, sum, dep.Digest), dep.Range)
+		}
+		p.locked[dep.Name] = &LockEntry{Source: dep.Source, Ref: dep.Ref, Commit: ret.Commit, Digest: sum}
+		return files, nil
+	}
+	return ret, nil
+}
+
+// moduleSourceOf finds the module an import label refers to: a dependency
+// of the manifest, a pinned git source or a folder.
+func (p *Parser) moduleSourceOf(label string) (*moduleSource, error) {
+	if dep, ok := p.dependencies[label]; ok {
+		return p.dependencySource(dep)
+	}
+	if isGitSource(label) {
+		src, err := parsePinnedGitSource(label)
+		if err != nil {
+			return nil, err
+		}
+		identifier := src.Identifier()
+		if pinned, ok := p.sources[identifier]; ok && pinned != src.Ref {
+			return nil, fmt.Errorf("%s is imported at both %s and %s", identifier, pinned, src.Ref)
+		}
+		return &moduleSource{
+			Identifier: identifier,
+			Name:       src.ModuleName(),
+			Commit:     src.Ref,
+			Read:       func() ([]*sourceFile, error) { return p.cachedGitSources(src, src.Ref) },
+		}, nil
+	}
+	return &moduleSource{
+		Identifier: label,
+		Name:       pathToModuleName(label),
+		Read:       func() ([]*sourceFile, error) { return readFolderSources(label, p.Options.Overlay) },
+	}, nil
+}
+
+func (p *Parser) parseImportBlock(ctx *ParserContext, block *hclsyntax.Block) error {
+	src, err := p.moduleSourceOf(block.Labels[0])
+	if err != nil {
+		return err
+	}
+	name, err := importAlias(block)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = src.Name
+	}
+	err = p.Symbols.AddSymbol(ctx.CurrentModule(), name,
+		&symbols.Import{Symbols: p.Symbols, Identifier: src.Identifier})
+	if err != nil {
+		return err
+	}
+	newCtx, err := ctx.Import(src.Identifier)
+	if err != nil {
+		return err
+	}
+	if p.imported[src.Identifier] {
+		// Already read through another import.
+		return nil
+	}
+	p.imported[src.Identifier] = true
+	files, err := src.Read()
+	if err != nil {
+		return err
+	}
+	if src.Commit != "" {
+		p.sources[src.Identifier] = src.Commit
+	}
+	p.parseSources(newCtx, files)
+	return nil
+}
@@ -0,0 +1,68 @@
+package hcl_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+)
+
+func TestBuildImportAliasAndManifest(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"libs/a/fasteners/bolt.bpo": `item "bolt" { part_number = "A" }`,
+		"libs/b/fasteners/bolt.bpo": `item "bolt" { part_number = "B" }`,
+		"app/cyanotype.hcl": `
+dependency "std" {
+  source = "../libs/a/fasteners"
+}
+`,
+		"app/main.bpo": `
+import "std" {}
+import "` + "LIBS" + `/b/fasteners" { as = "m3lib" }
+
+item "frame" {
+  from = [
+    { ref = std.bolt, qty = 1 },
+    { ref = m3lib.bolt, qty = 2 },
+  ]
+}
+`,
+	})
+	app := filepath.Join(dir, "app")
+	main := filepath.Join(app, "main.bpo")
+	src, err := os.ReadFile(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	libs := filepath.ToSlash(filepath.Join(dir, "libs"))
+	err = os.WriteFile(main, []byte(strings.ReplaceAll(string(src), "LIBS", libs)), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := hcl.NewParser()
+	err = p.Build(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findItem(t, p, "std.bolt").Content.PartNumber != "A" {
+		t.Error("std should be read from the manifest source")
+	}
+	if findItem(t, p, libs+"/b/fasteners.bolt").Content.PartNumber != "B" {
+		t.Error("m3lib should be read from its path")
+	}
+	lock, err := os.ReadFile(filepath.Join(app, hcl.LOCKFILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(lock), `dependency "std"`) || !strings.Contains(string(lock), "digest") {
+		t.Errorf("lock file should record std with its digest, got:\n%s", lock)
+	}
+	want := []string{app, filepath.Join(dir, "libs", "a", "fasteners"), filepath.Join(dir, "libs", "b", "fasteners")}
+	if !slices.Equal(p.Folders(), want) {
+		t.Errorf("unexpected folders %v, want %v", p.Folders(), want)
+	}
+}
@@ -0,0 +1,244 @@
+package hcl
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+
+	"github.com/tychonis/cyanotype/internal/symbols"
+	"github.com/tychonis/cyanotype/model"
+)
+
+var ErrNoReference = errors.New("no reference at position")
+
+// BLOCK_TYPES are the blocks allowed at the top level of a bpo file.
+var BLOCK_TYPES = []string{"item", "coitem", "process", "coprocess", "contract", "import", "variable", "locals"}
+
+// ReservedKeys returns the keys with a meaning of their own in a block of
+// the given type, sorted.
+func ReservedKeys(blockType string) []string {
+	switch blockType {
+	case "item", "coitem":
+		return slices.Sorted(maps.Keys(RESERVED))
+	case "process":
+		return slices.Sorted(maps.Keys(PROCESS_RESERVED))
+	case "coprocess":
+		return slices.Sorted(maps.Keys(COPROCESS_RESERVED))
+	case "import":
+		return []string{"as"}
+	case "variable":
+		return []string{"default", "type"}
+	default:
+		return nil
+	}
+}
+
+// Reference is a symbol referred to from source.
+type Reference struct {
+	Symbol model.Symbol
+	// Range is where the reference is written.
+	Range hcl.Range
+}
+
+// ModuleOf returns the module a file read by the last Build belongs to.
+func (p *Parser) ModuleOf(filename string) (string, bool) {
+	module, ok := p.fileModules[filename]
+	return module, ok
+}
+
+// Context returns the context symbols of a module are evaluated in.
+func (p *Parser) Context(module string) (*ParserContext, bool) {
+	ctx, ok := p.contexts[module]
+	return ctx, ok
+}
+
+// ModuleFiles returns the files of a module, sorted.
+func (p *Parser) ModuleFiles(module string) []string {
+	ret := make([]string, 0)
+	for filename, m := range p.fileModules {
+		if m == module {
+			ret = append(ret, filename)
+		}
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// Folders returns the local folders the last Build read from: the folders
+// of its files, the folders on the import stack of every module and the
+// workspace root. Git imports have none.
+func (p *Parser) Folders() []string {
+	candidates := make([]string, 0)
+	for filename := range p.fileModules {
+		candidates = append(candidates, filepath.Dir(filename))
+	}
+	for _, ctx := range p.contexts {
+		// The root module is named "." whatever folder it is read from.
+		for _, module := range ctx.ImportStack {
+			if module != "." {
+				candidates = append(candidates, module)
+			}
+		}
+	}
+	if p.root != "" {
+		candidates = append(candidates, p.root)
+	}
+	ret := make([]string, 0)
+	for _, dir := range candidates {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		dir, err := filepath.Abs(dir)
+		if err == nil && !slices.Contains(ret, dir) {
+			ret = append(ret, dir)
+		}
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+func (p *Parser) fileBody(filename string) (*hclsyntax.Body, bool) {
+	file, ok := p.Files()[filename]
+	if !ok {
+		return nil, false
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	return body, ok
+}
+
+// BlockAt returns the top level block enclosing a byte offset of a file.
+func (p *Parser) BlockAt(filename string, offset int) (*hclsyntax.Block, bool) {
+	body, ok := p.fileBody(filename)
+	if !ok {
+		return nil, false
+	}
+	for _, block := range body.Blocks {
+		if block.Range().ContainsOffset(offset) {
+			return block, true
+		}
+	}
+	return nil, false
+}
+
+// expressionAt finds the innermost reference expression around offset. A
+// family member written as bolt[key] is kept whole.
+func expressionAt(body *hclsyntax.Body, offset int) hclsyntax.Expression {
+	var found hclsyntax.Expression
+	hclsyntax.VisitAll(body, func(n hclsyntax.Node) hcl.Diagnostics {
+		switch e := n.(type) {
+		case *hclsyntax.IndexExpr:
+			if _, ok := e.Collection.(*hclsyntax.ScopeTraversalExpr); ok && e.SrcRange.ContainsOffset(offset) {
+				found = e
+			}
+		case *hclsyntax.ScopeTraversalExpr:
+			if !e.SrcRange.ContainsOffset(offset) {
+				return nil
+			}
+			if index, ok := found.(*hclsyntax.IndexExpr); ok && index.Collection == e {
+				return nil
+			}
+			found = e
+		}
+		return nil
+	})
+	return found
+}
+
+// ReferenceAt returns the symbol referenced at a byte offset of a file read
+// by the last Build: a traversal such as `ref = std.bolt`, the label of an
+// import block, or the label of a block, which refers to the block itself.
+func (p *Parser) ReferenceAt(filename string, offset int) (*Reference, error) {
+	module, ok := p.ModuleOf(filename)
+	if !ok {
+		return nil, ErrNoReference
+	}
+	ctx, ok := p.Context(module)
+	if !ok {
+		return nil, ErrNoReference
+	}
+	body, ok := p.fileBody(filename)
+	if !ok {
+		return nil, ErrNoReference
+	}
+	block, ok := p.BlockAt(filename, offset)
+	if ok && len(block.Labels) == 1 && block.LabelRanges[0].ContainsOffset(offset) {
+		name := block.Labels[0]
+		if block.Type == "import" {
+			if src, err := p.moduleSourceOf(name); err == nil {
+				name = src.Name
+			}
+			if alias, err := importAlias(block); err == nil && alias != "" {
+				name = alias
+			}
+		}
+		scope, ok := p.Symbols.Modules[module]
+		if !ok {
+			return nil, ErrNoReference
+		}
+		sym, err := scope.Resolve([]string{name})
+		if err != nil {
+			return nil, err
+		}
+		return &Reference{Symbol: sym, Range: block.LabelRanges[0]}, nil
+	}
+	expr := expressionAt(body, offset)
+	if expr == nil {
+		return nil, ErrNoReference
+	}
+	ref, err := exprToRef(ctx, expr)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return &Reference{Symbol: sym, Range: expr.Range()}, nil
+}
+
+// Definition returns where a symbol is defined. Imports are defined by the
+// first file of the imported module.
+func (p *Parser) Definition(sym model.Symbol) (hcl.Range, bool) {
+	switch s := sym.(type) {
+	case *UnprocessedSymbol:
+		return s.Block.DefRange(), true
+	case *AttributeRef:
+		return s.Symbol.Block.DefRange(), true
+	case *UnprocessedFamily:
+		return s.Block.DefRange(), true
+	case *symbols.Import:
+		files := p.ModuleFiles(s.Identifier)
+		if len(files) == 0 {
+			return hcl.Range{}, false
+		}
+		return hcl.Range{Filename: files[0], Start: hcl.InitialPos, End: hcl.InitialPos}, true
+	case model.ConcreteSymbol:
+		r, ok := p.ranges[s.GetDigest()]
+		return r, ok
+	default:
+		return hcl.Range{}, false
+	}
+}
+
+// Concrete returns the symbol built from sym by the last Build, if any.
+func (p *Parser) Concrete(sym model.Symbol) (model.ConcreteSymbol, bool) {
+	switch s := sym.(type) {
+	case *UnprocessedSymbol:
+		if s.qualifier == "" {
+			return nil, false
+		}
+		concrete, err := p.Symbols.FindConcreteSymbol(s.qualifier)
+		return concrete, err == nil
+	case *AttributeRef:
+		return p.Concrete(s.Symbol)
+	case model.ConcreteSymbol:
+		return s, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,230 @@
+package hcl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/tychonis/cyanotype/internal/cerror"
+	"github.com/tychonis/cyanotype/internal/digest"
+)
+
+const MANIFEST string = "cyanotype.hcl"
+const LOCKFILE string = "cyanotype.lock"
+
+// Dependency is a library declared in the workspace manifest:
+//
+//	dependency "m3lib" {
+//	  source = "git::https://example.com/parts.git//fasteners"
+//	  ref    = "v1.2"
+//	  digest = "9f2c..."
+//	}
+//
+// Sources are folders relative to the manifest, or git sources. Ref is a
+// branch, tag or commit of a git source, and Digest, when set, must match
+// the content read.
+type Dependency struct {
+	Name   string
+	Source string
+	Ref    string
+	Digest string
+	Range  hcl.Range
+}
+
+// LockEntry records what a dependency resolved to in the last build.
+type LockEntry struct {
+	Source string
+	Ref    string
+	Commit string
+	Digest string
+}
+
+type Lock = map[string]*LockEntry
+
+func readDependency(block *hclsyntax.Block) (*Dependency, error) {
+	if len(block.Labels) != 1 {
+		return nil, cerror.ErrorWithRange("dependency block must have exactly one label", block.DefRange())
+	}
+	dep := &Dependency{Name: block.Labels[0], Range: block.DefRange()}
+	if !hclsyntax.ValidIdentifier(dep.Name) {
+		return nil, cerror.ErrorWithRange(fmt.Sprintf("dependency name %q is not a valid identifier", dep.Name), block.LabelRanges[0])
+	}
+	attrs, diags := block.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	fields := map[string]*string{"source": &dep.Source, "ref": &dep.Ref, "digest": &dep.Digest}
+	for name, attr := range attrs {
+		field, ok := fields[name]
+		if !ok {
+			return nil, cerror.ErrorWithRange(fmt.Sprintf("unknown dependency attribute %s", name), attr.NameRange)
+		}
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		if val.Type() != cty.String || val.IsNull() {
+			return nil, cerror.ErrorWithRange(fmt.Sprintf("%s of dependency %s must be a string", name, dep.Name), attr.Range)
+		}
+		*field = val.AsString()
+	}
+	if dep.Source == "" {
+		return nil, cerror.ErrorWithRange(fmt.Sprintf("dependency %s has no source", dep.Name), dep.Range)
+	}
+	return dep, nil
+}
+
+// loadManifest reads the manifest of the workspace rooted at root, if any.
+func (p *Parser) loadManifest(root string) error {
+	path := filepath.Join(root, MANIFEST)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	file, diags := p.files.ParseHCLFile(path)
+	if diags.HasErrors() {
+		return diags
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return errors.New("failed to parse manifest")
+	}
+	p.root = root
+	p.dependencies = make(map[string]*Dependency)
+	for _, block := range body.Blocks {
+		if block.Type != "dependency" {
+			return cerror.ErrorWithRange(fmt.Sprintf("unknown manifest block %s", block.Type), block.DefRange())
+		}
+		dep, err := readDependency(block)
+		if err != nil {
+			return err
+		}
+		if _, ok := p.dependencies[dep.Name]; ok {
+			return cerror.ErrorWithRange(fmt.Sprintf("dependency %s already declared", dep.Name), dep.Range)
+		}
+		p.dependencies[dep.Name] = dep
+	}
+	lock, err := readLock(filepath.Join(root, LOCKFILE))
+	if err != nil {
+		return err
+	}
+	p.lock = lock
+	return nil
+}
+
+func readLock(path string) (Lock, error) {
+	ret := make(Lock)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return ret, nil
+	}
+	file, diags := hclparse.NewParser().ParseHCLFile(path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, errors.New("failed to parse lock file")
+	}
+	for _, block := range body.Blocks {
+		if block.Type != "dependency" || len(block.Labels) != 1 {
+			continue
+		}
+		attrs, diags := block.Body.JustAttributes()
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		entry := &LockEntry{}
+		fields := map[string]*string{"source": &entry.Source, "ref": &entry.Ref, "commit": &entry.Commit, "digest": &entry.Digest}
+		for name, attr := range attrs {
+			field, ok := fields[name]
+			if !ok {
+				continue
+			}
+			val, diags := attr.Expr.Value(nil)
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			if val.Type() == cty.String && !val.IsNull() {
+				*field = val.AsString()
+			}
+		}
+		ret[block.Labels[0]] = entry
+	}
+	return ret, nil
+}
+
+func encodeLock(lock Lock) []byte {
+	f := hclwrite.NewEmptyFile()
+	body := f.Body()
+	for i, name := range slices.Sorted(maps.Keys(lock)) {
+		if i > 0 {
+			body.AppendNewline()
+		}
+		entry := lock[name]
+		block := body.AppendNewBlock("dependency", []string{name}).Body()
+		block.SetAttributeValue("source", cty.StringVal(entry.Source))
+		if entry.Ref != "" {
+			block.SetAttributeValue("ref", cty.StringVal(entry.Ref))
+		}
+		if entry.Commit != "" {
+			block.SetAttributeValue("commit", cty.StringVal(entry.Commit))
+		}
+		block.SetAttributeValue("digest", cty.StringVal(entry.Digest))
+	}
+	return f.Bytes()
+}
+
+// writeLock records the dependencies used by this build next to the
+// manifest, leaving the file untouched when nothing changed.
+func (p *Parser) writeLock() error {
+	if p.dependencies == nil || p.Options.NoLock {
+		return nil
+	}
+	// Keep entries of dependencies no source imported this time.
+	lock := maps.Clone(p.lock)
+	maps.Copy(lock, p.locked)
+	for name := range lock {
+		if _, ok := p.dependencies[name]; !ok {
+			delete(lock, name)
+		}
+	}
+	data := encodeLock(lock)
+	path := filepath.Join(p.root, LOCKFILE)
+	old, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// lockedRef returns the commit a git dependency was pinned to by the lock
+// file, as long as the manifest still asks for the same source and ref.
+func (p *Parser) lockedRef(dep *Dependency) string {
+	entry, ok := p.lock[dep.Name]
+	if !ok || entry.Source != dep.Source || entry.Ref != dep.Ref {
+		return ""
+	}
+	return entry.Commit
+}
+
+// sourceDigest summarizes the files of a module, independent of where they
+// were read from.
+func sourceDigest(files []*sourceFile) (string, error) {
+	var buf bytes.Buffer
+	for _, f := range files {
+		buf.WriteString(f.Name)
+		buf.WriteByte(0)
+		buf.Write(f.Content)
+		buf.WriteByte(0)
+	}
+	return digest.SHA256FromReader(&buf)
+}
@@ -0,0 +1,688 @@
+package hcl
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/tychonis/cyanotype/core/catalog"
+	"github.com/tychonis/cyanotype/core/process"
+	"github.com/tychonis/cyanotype/core/qualifier"
+	"github.com/tychonis/cyanotype/internal/refdes"
+	"github.com/tychonis/cyanotype/model"
+)
+
+// REF_ATTRIBUTES hold references, which cannot vary between the members of
+// a family.
+var REF_ATTRIBUTES = []string{"from", "impl", "req"}
+
+// Materialize writes the root module as of a revision back to source.
+// Companions are left out since building the source generates them again;
+// the process of an item comes back as its `from` lines. Symbols of other
+// modules are referenced through import blocks.
+func Materialize(cat *catalog.Catalog, rev *model.Revision) ([]byte, error) {
+	symbols, err := cat.GetSymbolsAt(rev.Digest)
+	if err != nil {
+		return nil, err
+	}
+	m := &materializer{
+		symbols: symbols,
+		digests: make(map[model.Digest]model.Qualifier),
+		sources: rev.Sources,
+		imports: make(map[string]string),
+		names:   make(map[string]bool),
+	}
+	return m.write()
+}
+
+type materializer struct {
+	symbols map[model.Qualifier]model.ConcreteSymbol
+	// digests maps digests back to qualifiers, preferring the root module
+	// when modules share a symbol.
+	digests map[model.Digest]model.Qualifier
+	sources map[string]string
+	// imports maps module identifiers to the name they are imported as.
+	imports map[string]string
+	names   map[string]bool
+}
+
+// familyMember is an item or coitem written by a for_each or count block.
+type familyMember struct {
+	Key       string
+	Index     int
+	Qualifier model.Qualifier
+	Symbol    model.ConcreteSymbol
+}
+
+type family struct {
+	Label   string
+	Type    string
+	Count   bool
+	Members []*familyMember
+}
+
+func symbolName(sym model.ConcreteSymbol) string {
+	switch s := sym.(type) {
+	case *model.Item:
+		return s.Content.Name
+	case *model.CoItem:
+		return s.Content.Name
+	case *model.Contract:
+		return s.Name
+	case *process.Process:
+		return s.Content.GetName()
+	case *process.CoProcess:
+		return s.Content.GetName()
+	default:
+		return ""
+	}
+}
+
+// split cuts a qualifier into its module and the name of the symbol. The
+// module of the root is empty.
+func (m *materializer) split(q model.Qualifier) (string, string, error) {
+	sym, ok := m.symbols[q]
+	if !ok {
+		return "", "", fmt.Errorf("%s is not in the catalog at this revision", q)
+	}
+	name := symbolName(sym)
+	module, ok := strings.CutSuffix(q, "."+name)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("cannot tell the module of %s", q)
+	}
+	return module, name, nil
+}
+
+func (m *materializer) isRoot(q model.Qualifier) bool {
+	module, _, err := m.split(q)
+	return err == nil && module == ""
+}
+
+// familyLabel splits the name of a family member into the block label and
+// the instance key, e.g. `bolt["M3"]`.
+func familyLabel(name string) (string, string, bool) {
+	i := strings.Index(name, "[")
+	if i <= 0 || !strings.HasSuffix(name, "]") {
+		return name, "", false
+	}
+	return name[:i], name[i:], true
+}
+
+// moduleAlias derives an identifier to import a module as.
+func moduleAlias(module string) string {
+	base := pathToModuleName(module)
+	if isGitSource(module) {
+		if src, err := parseGitSource(module); err == nil {
+			base = src.ModuleName()
+		}
+	}
+	base = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, base)
+	if !hclsyntax.ValidIdentifier(base) {
+		base = "module_" + base
+	}
+	return base
+}
+
+func (m *materializer) importAs(module string) string {
+	if name, ok := m.imports[module]; ok {
+		return name
+	}
+	base := moduleAlias(module)
+	name := base
+	for i := 2; m.names[name]; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	m.names[name] = true
+	m.imports[module] = name
+	return name
+}
+
+// ref writes a reference to a symbol as seen from the root module.
+func (m *materializer) ref(q model.Qualifier) (string, error) {
+	module, name, err := m.split(q)
+	if err != nil {
+		return "", err
+	}
+	if module == "" {
+		return name, nil
+	}
+	return m.importAs(module) + "." + name, nil
+}
+
+func (m *materializer) digestRef(d model.Digest) (string, error) {
+	q, ok := m.digests[d]
+	if !ok {
+		return "", fmt.Errorf("symbol %s is not in the catalog at this revision", d)
+	}
+	return m.ref(q)
+}
+
+// coItemRef references a coitem. Companion coitems are written as the item
+// they belong to.
+func (m *materializer) coItemRef(d model.Digest) (string, error) {
+	q, ok := m.digests[d]
+	if !ok {
+		return "", fmt.Errorf("symbol %s is not in the catalog at this revision", d)
+	}
+	if item, ok := strings.CutSuffix(q, qualifier.COITEM); ok {
+		return m.ref(item)
+	}
+	return m.ref(q)
+}
+
+func (m *materializer) contractRefs(ids []model.ContractID) ([]string, error) {
+	ret := make([]string, 0, len(ids))
+	for _, id := range ids {
+		ref, err := m.digestRef(id)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, ref)
+	}
+	return ret, nil
+}
+
+// refdesValue writes designators as a spec such as "R1..R4" when it
+// expands back to the same list, in the same order.
+func refdesValue(list []string) any {
+	spec := refdes.Format(list)
+	expanded, err := refdes.Expand(spec)
+	if err == nil && slices.Equal(expanded, list) {
+		return spec
+	}
+	ret := make([]any, 0, len(list))
+	for _, d := range list {
+		ret = append(ret, d)
+	}
+	return ret
+}
+
+func numbers(values []float64) []any {
+	ret := make([]any, 0, len(values))
+	for _, v := range values {
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+func placementValue(component *process.Component) map[string]any {
+	placement := model.IdentityPlacement
+	if component.Rotation != nil {
+		placement.Rotation = *component.Rotation
+	}
+	if component.Translation != nil {
+		placement.Position = *component.Translation
+	}
+	ret := map[string]any{
+		"position": numbers(placement.Position[:]),
+	}
+	if placement.Rotation != model.IdentityQuaternion {
+		ret["rotation"] = numbers(placement.Rotation[:])
+	}
+	return ret
+}
+
+// bomLine writes a line, leaving out the qty it would default to.
+func bomLine(line *model.BOMLine, ref string) DocumentLine {
+	ret := DocumentLine{"ref": ref}
+	if line.Name != "" {
+		ret["name"] = line.Name
+	}
+	qty := 1.0
+	if len(line.Refdes) > 0 {
+		ret["refdes"] = refdesValue(line.Refdes)
+		qty = float64(len(line.Refdes))
+	}
+	if line.Qty != qty {
+		ret["qty"] = line.Qty
+	}
+	if line.Unit != "" {
+		ret["unit"] = line.Unit
+	}
+	return ret
+}
+
+// inputLines writes the input of a process. Components of a drawing carry
+// their placement.
+func (m *materializer) inputLines(content process.ProcessContent) ([]DocumentLine, error) {
+	drawing, ok := content.(*process.Drawing)
+	if !ok {
+		ret := make([]DocumentLine, 0)
+		for _, line := range content.GetInput() {
+			ref, err := m.coItemRef(line.Item)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, bomLine(line, ref))
+		}
+		return ret, nil
+	}
+	ret := make([]DocumentLine, 0, len(drawing.Components))
+	for _, component := range drawing.Components {
+		ref, err := m.coItemRef(component.CoItem)
+		if err != nil {
+			return nil, err
+		}
+		line := DocumentLine{
+			"ref":       ref,
+			"placement": placementValue(component),
+		}
+		if component.Name != "" {
+			line["name"] = component.Name
+		}
+		if component.Refdes != "" {
+			line["refdes"] = component.Refdes
+		}
+		ret = append(ret, line)
+	}
+	return ret, nil
+}
+
+func writeContent(body *hclwrite.Body, content *model.ItemContent) {
+	setString(body, "part_number", content.PartNumber)
+	setString(body, "source", content.Source)
+	setString(body, "unit", content.Unit)
+}
+
+// writeArtifactList keeps artifacts in order, as their order is part of the
+// digest of the item.
+func writeArtifactList(body *hclwrite.Body, artifacts []*model.Artifact) {
+	for _, a := range artifacts {
+		block := body.AppendNewBlock("artifact", []string{a.Name})
+		block.Body().SetAttributeValue("filename", cty.StringVal(a.Filename))
+		block.Body().SetAttributeValue("tag", cty.StringVal(a.Tag))
+		block.Body().SetAttributeValue("source", cty.StringVal(a.Source))
+	}
+}
+
+func (m *materializer) writeItem(parent *hclwrite.Body, name string, q model.Qualifier, item *model.Item) error {
+	body := parent.AppendNewBlock("item", []string{name}).Body()
+	writeContent(body, item.Content)
+	if sym, ok := m.symbols[q+qualifier.PROCESS]; ok {
+		proc, ok := sym.(*process.Process)
+		if !ok {
+			return fmt.Errorf("%s is not a process", q+qualifier.PROCESS)
+		}
+		from, err := m.inputLines(proc.Content)
+		if err != nil {
+			return err
+		}
+		if len(from) > 0 {
+			err = setLines(body, "from", from)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// An empty impl list and no impl at all make different digests.
+	if item.Implement != nil {
+		refs, err := m.contractRefs(item.Implement)
+		if err != nil {
+			return err
+		}
+		err = setRefs(body, "impl", refs)
+		if err != nil {
+			return err
+		}
+	}
+	err := setDetails(body, item.Content.Details, RESERVED)
+	if err != nil {
+		return err
+	}
+	writeArtifactList(body, item.Content.Artifacts)
+	return nil
+}
+
+func (m *materializer) writeCoItem(parent *hclwrite.Body, name string, coItem *model.CoItem) error {
+	body := parent.AppendNewBlock("coitem", []string{name}).Body()
+	writeContent(body, coItem.Content)
+	if coItem.Require != nil {
+		refs, err := m.contractRefs(coItem.Require)
+		if err != nil {
+			return err
+		}
+		err = setRefs(body, "req", refs)
+		if err != nil {
+			return err
+		}
+	}
+	err := setDetails(body, coItem.Content.Details, RESERVED)
+	if err != nil {
+		return err
+	}
+	writeArtifactList(body, coItem.Content.Artifacts)
+	return nil
+}
+
+func (m *materializer) writeProcess(parent *hclwrite.Body, name string, proc *process.Process) error {
+	body := parent.AppendNewBlock("process", []string{name}).Body()
+	input, err := m.inputLines(proc.Content)
+	if err != nil {
+		return err
+	}
+	// The type is only written when placements would not tell it.
+	inferred := process.ABSTRACT
+	if drawing, ok := proc.Content.(*process.Drawing); ok && len(drawing.Components) > 0 {
+		inferred = process.DRAWING
+	}
+	if proc.Content.GetType() != inferred {
+		setString(body, "type", proc.Content.GetType())
+	}
+	if len(input) > 0 {
+		err = setLines(body, "input", input)
+		if err != nil {
+			return err
+		}
+	}
+	output := make([]DocumentLine, 0)
+	for _, line := range proc.Output() {
+		ref, err := m.digestRef(line.Item)
+		if err != nil {
+			return err
+		}
+		output = append(output, bomLine(line, ref))
+	}
+	err = setLines(body, "output", output)
+	if err != nil {
+		return err
+	}
+	return setDetails(body, proc.Content.GetDetails(), PROCESS_RESERVED)
+}
+
+func (m *materializer) writeCoProcess(parent *hclwrite.Body, name string, cp *process.CoProcess) error {
+	input, output := cp.Input(), cp.Output()
+	if len(input) != 1 || len(output) != 1 {
+		return fmt.Errorf("coprocess %s must lead from one item to one coitem", name)
+	}
+	from, err := m.digestRef(input[0].Item)
+	if err != nil {
+		return err
+	}
+	to, err := m.coItemRef(output[0].Item)
+	if err != nil {
+		return err
+	}
+	body := parent.AppendNewBlock("coprocess", []string{name}).Body()
+	tokens, err := refTokens(from)
+	if err != nil {
+		return err
+	}
+	body.SetAttributeRaw("from", tokens)
+	tokens, err = refTokens(to)
+	if err != nil {
+		return err
+	}
+	body.SetAttributeRaw("to", tokens)
+	return setDetails(body, cp.Content.GetDetails(), COPROCESS_RESERVED)
+}
+
+func (m *materializer) writeContract(parent *hclwrite.Body, contract *model.Contract) error {
+	body := parent.AppendNewBlock("contract", []string{contract.Name}).Body()
+	return setDetails(body, contract.Params, nil)
+}
+
+func (m *materializer) writeMember(parent *hclwrite.Body, label string, member *familyMember) error {
+	switch sym := member.Symbol.(type) {
+	case *model.Item:
+		return m.writeItem(parent, label, member.Qualifier, sym)
+	case *model.CoItem:
+		return m.writeCoItem(parent, label, sym)
+	default:
+		return errors.New("only items and coitems form families")
+	}
+}
+
+// writeFamily writes the members of a family as one block. Attributes that
+// differ between members are read from each.value, or indexed by
+// count.index.
+func (m *materializer) writeFamily(parent *hclwrite.Body, f *family) error {
+	scratch := hclwrite.NewEmptyFile().Body()
+	bodies := make([]*hclwrite.Body, 0, len(f.Members))
+	for _, member := range f.Members {
+		err := m.writeMember(scratch, f.Label, member)
+		if err != nil {
+			return err
+		}
+		blocks := scratch.Blocks()
+		bodies = append(bodies, blocks[len(blocks)-1].Body())
+	}
+
+	names := slices.Sorted(maps.Keys(bodies[0].Attributes()))
+	nested := blockTokens(bodies[0])
+	for _, body := range bodies[1:] {
+		if !slices.Equal(slices.Sorted(maps.Keys(body.Attributes())), names) {
+			return fmt.Errorf("members of %s set different attributes", f.Label)
+		}
+		if !bytes.Equal(blockTokens(body), nested) {
+			return fmt.Errorf("members of %s have different artifacts", f.Label)
+		}
+	}
+
+	body := parent.AppendNewBlock(f.Type, []string{f.Label}).Body()
+	values := make([][]hclwrite.ObjectAttrTokens, len(f.Members))
+	for _, name := range canonicalKeyOrder(names, RESERVED_ORDER) {
+		tokens := make([]hclwrite.Tokens, 0, len(bodies))
+		for _, b := range bodies {
+			tokens = append(tokens, b.GetAttribute(name).Expr().BuildTokens(nil))
+		}
+		if !slices.ContainsFunc(tokens, func(t hclwrite.Tokens) bool { return !bytes.Equal(t.Bytes(), tokens[0].Bytes()) }) {
+			body.SetAttributeRaw(name, tokens[0])
+			continue
+		}
+		if slices.Contains(REF_ATTRIBUTES, name) {
+			return fmt.Errorf("members of %s differ in %s, which holds references", f.Label, name)
+		}
+		if f.Count {
+			index := hclwrite.Tokens{{Type: hclsyntax.TokenOBrack, Bytes: []byte("[")}}
+			index = append(index, hclwrite.TokensForTraversal(hcl.Traversal{
+				hcl.TraverseRoot{Name: "count"},
+				hcl.TraverseAttr{Name: "index"},
+			})...)
+			index = append(index, &hclwrite.Token{Type: hclsyntax.TokenCBrack, Bytes: []byte("]")})
+			body.SetAttributeRaw(name, append(hclwrite.TokensForTuple(tokens), index...))
+			continue
+		}
+		for i, expr := range tokens {
+			values[i] = append(values[i], hclwrite.ObjectAttrTokens{
+				Name:  hclwrite.TokensForIdentifier(name),
+				Value: expr,
+			})
+		}
+		body.SetAttributeRaw(name, hclwrite.TokensForTraversal(hcl.Traversal{
+			hcl.TraverseRoot{Name: "each"},
+			hcl.TraverseAttr{Name: "value"},
+			hcl.TraverseAttr{Name: name},
+		}))
+	}
+
+	if f.Count {
+		body.SetAttributeValue("count", cty.NumberIntVal(int64(len(f.Members))))
+	} else if len(values[0]) == 0 {
+		keys := make([]cty.Value, 0, len(f.Members))
+		for _, member := range f.Members {
+			keys = append(keys, cty.StringVal(member.Key))
+		}
+		body.SetAttributeValue("for_each", cty.TupleVal(keys))
+	} else {
+		attrs := make([]hclwrite.ObjectAttrTokens, 0, len(f.Members))
+		for i, member := range f.Members {
+			key := hclwrite.TokensForValue(cty.StringVal(member.Key))
+			if hclsyntax.ValidIdentifier(member.Key) {
+				key = hclwrite.TokensForIdentifier(member.Key)
+			}
+			attrs = append(attrs, hclwrite.ObjectAttrTokens{
+				Name:  key,
+				Value: hclwrite.TokensForObject(values[i]),
+			})
+		}
+		body.SetAttributeRaw("for_each", hclwrite.TokensForObject(attrs))
+	}
+	for _, block := range bodies[0].Blocks() {
+		body.AppendBlock(block)
+	}
+	return nil
+}
+
+func blockTokens(body *hclwrite.Body) []byte {
+	buf := &bytes.Buffer{}
+	for _, block := range body.Blocks() {
+		block.BuildTokens(nil).WriteTo(buf)
+	}
+	return buf.Bytes()
+}
+
+// addMember files a family member under its label, checking that keys are
+// either all strings or all indexes.
+func addMember(families map[string]*family, typ string, q model.Qualifier, sym model.ConcreteSymbol, label string, key string) error {
+	f, ok := families[label]
+	if !ok {
+		f = &family{Label: label, Type: typ, Count: !strings.HasPrefix(key, `["`)}
+		families[label] = f
+	}
+	if f.Type != typ {
+		return fmt.Errorf("%s is both an item and a coitem family", label)
+	}
+	member := &familyMember{Qualifier: q, Symbol: sym}
+	inner := key[1 : len(key)-1]
+	var err error
+	if f.Count {
+		member.Index, err = strconv.Atoi(inner)
+	} else {
+		member.Key, err = strconv.Unquote(inner)
+	}
+	if err != nil || f.Count == strings.HasPrefix(key, `["`) {
+		return fmt.Errorf("invalid instance key %s of %s", key, label)
+	}
+	f.Members = append(f.Members, member)
+	return nil
+}
+
+func sortFamily(f *family) error {
+	slices.SortFunc(f.Members, func(a, b *familyMember) int {
+		return cmp.Or(cmp.Compare(a.Index, b.Index), cmp.Compare(a.Key, b.Key))
+	})
+	if !f.Count {
+		return nil
+	}
+	for i, member := range f.Members {
+		if member.Index != i {
+			return fmt.Errorf("instances of %s are not numbered from 0 without gaps", f.Label)
+		}
+	}
+	return nil
+}
+
+func (m *materializer) write() ([]byte, error) {
+	qualifiers := slices.Sorted(maps.Keys(m.symbols))
+	for _, q := range qualifiers {
+		d := m.symbols[q].GetDigest()
+		if existing, ok := m.digests[d]; ok && (m.isRoot(existing) || !m.isRoot(q)) {
+			continue
+		}
+		m.digests[d] = q
+	}
+
+	families := make(map[string]*family)
+	for _, q := range qualifiers {
+		if qualifier.IsImplicit(q) || !m.isRoot(q) {
+			continue
+		}
+		name := symbolName(m.symbols[q])
+		label, key, isMember := familyLabel(name)
+		m.names[label] = true
+		if !isMember {
+			continue
+		}
+		var err error
+		switch sym := m.symbols[q].(type) {
+		case *model.Item:
+			err = addMember(families, "item", q, sym, label, key)
+		case *model.CoItem:
+			err = addMember(families, "coitem", q, sym, label, key)
+		default:
+			err = fmt.Errorf("%s cannot be written in source", q)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range families {
+		err := sortFamily(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f := hclwrite.NewEmptyFile()
+	body := f.Body()
+	for _, typ := range []string{"item", "coitem", "process", "coprocess", "contract"} {
+		written := make(map[string]bool)
+		for _, q := range qualifiers {
+			if qualifier.IsImplicit(q) || !m.isRoot(q) || m.symbols[q].GetType() != typ {
+				continue
+			}
+			name := symbolName(m.symbols[q])
+			label, _, isMember := familyLabel(name)
+			if written[label] {
+				continue
+			}
+			if len(body.Blocks()) > 0 {
+				body.AppendNewline()
+			}
+			if isMember {
+				written[label] = true
+				err := m.writeFamily(body, families[label])
+				if err != nil {
+					return nil, fmt.Errorf("%s %s: %w", typ, label, err)
+				}
+				continue
+			}
+			var err error
+			switch sym := m.symbols[q].(type) {
+			case *model.Item:
+				err = m.writeItem(body, name, q, sym)
+			case *model.CoItem:
+				err = m.writeCoItem(body, name, sym)
+			case *process.Process:
+				err = m.writeProcess(body, name, sym)
+			case *process.CoProcess:
+				err = m.writeCoProcess(body, name, sym)
+			case *model.Contract:
+				err = m.writeContract(body, sym)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", typ, name, err)
+			}
+		}
+	}
+
+	header := hclwrite.NewEmptyFile()
+	for _, module := range slices.Sorted(maps.Keys(m.imports)) {
+		label := module
+		if commit, ok := m.sources[module]; ok && isGitSource(module) {
+			label += "?ref=" + commit
+		}
+		block := header.Body().AppendNewBlock("import", []string{label})
+		block.Body().SetAttributeValue("as", cty.StringVal(m.imports[module]))
+	}
+	if len(m.imports) > 0 {
+		header.Body().AppendNewline()
+	}
+	return Format(append(header.Bytes(), f.Bytes()...), "materialized"+EXTENSION)
+}
@@ -0,0 +1,187 @@
+package hcl_test
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tychonis/cyanotype/core/catalog"
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+	"github.com/tychonis/cyanotype/model"
+)
+
+const materializeLib = `
+item "bolt" {
+  part_number = "B-1"
+}
+`
+
+const materializeMain = `
+import "LIB" {
+  as = "std"
+}
+
+contract "drive" {
+  voltage = 24
+}
+
+item "motor" {
+  part_number = "MT-1"
+  impl        = [drive]
+  rating      = { torque = 1.5, curve = [1, 2.25] }
+  note        = "rated $${voltage}"
+
+  artifact "spec" {
+    filename = "spec.pdf"
+    tag      = "spec"
+    source   = "digest:b"
+  }
+  artifact "datasheet" {
+    filename = "motor.pdf"
+    tag      = "spec"
+    source   = "digest:a"
+  }
+}
+
+coitem "any_motor" {
+  req = [drive]
+}
+
+coprocess "motor_for_any" {
+  from     = motor
+  to       = any_motor
+  priority = 5
+}
+
+item "screw" {
+  for_each    = { M3 = "S-3", M4 = "S-4" }
+  part_number = each.value
+  unit        = "pcs"
+}
+
+item "spacer" {
+  count  = 2
+  height = count.index * 5
+}
+
+item "wire" {
+  unit = "m"
+}
+
+item "board" {
+  from = [
+    { name = "resistors", ref = std.bolt, refdes = "R1..R4" },
+    { name = "caps", ref = std.bolt, refdes = ["C2", "C1"] },
+    { ref = wire, qty = "250 mm" },
+    { ref = screw["M3"], qty = 4 },
+  ]
+}
+
+item "cart" {
+  from = [
+    { name = "left", ref = motor, placement = { position = [0, 1.5, 0] }, refdes = "M1" },
+    { name = "right", ref = motor, placement = { rotation = { euler_deg = [0, 0, 90] } } },
+    { ref = board, placement = [0, 0, 0, 1, 10, 0, 0] },
+    { ref = spacer[1], placement = [0, 0, 0, 1, 0, 0, 0] },
+  ]
+}
+
+item "frame" {}
+
+process "frame_cnc" {
+  input     = [{ name = "motor", ref = any_motor, qty = "1 pcs" }]
+  output    = [{ name = "frame", ref = frame }]
+  operation = "machining"
+}
+`
+
+func buildDir(t *testing.T, dir string) *hcl.Parser {
+	t.Helper()
+	p := hcl.NewParser()
+	p.Options.NoLock = true
+	err := p.Build(dir)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	return p
+}
+
+func materialize(t *testing.T, cat *catalog.Catalog, rev *model.Revision) *hcl.Parser {
+	t.Helper()
+	src, err := hcl.Materialize(cat, rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := hcl.Format(src, "main.bpo")
+	if err != nil || string(formatted) != string(src) {
+		t.Errorf("output is not formatted: %v\n%s", err, src)
+	}
+	if strings.Contains(string(src), "__") {
+		t.Errorf("output contains companions:\n%s", src)
+	}
+	dir := writeFiles(t, map[string]string{"main.bpo": string(src)})
+	p := hcl.NewParser()
+	p.Options.NoLock = true
+	err = p.Build(dir)
+	if err != nil {
+		t.Fatalf("Build error: %v\n%s", err, src)
+	}
+	return p
+}
+
+func TestMaterializeRoundTrip(t *testing.T) {
+	lib := writeFiles(t, map[string]string{"main.bpo": materializeLib})
+	main := strings.ReplaceAll(materializeMain, "LIB", filepath.ToSlash(lib))
+	dir := writeFiles(t, map[string]string{"main.bpo": main})
+	cat := catalog.New("memory")
+
+	first := buildDir(t, dir)
+	err := first.Commit(cat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rev, err := cat.GetLatestRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := strings.ReplaceAll(main, `"MT-1"`, `"MT-2"`)
+	err = os.WriteFile(filepath.Join(dir, "main.bpo"), []byte(changed), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := buildDir(t, dir)
+	err = second.Commit(cat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := cat.GetLatestRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Digest == rev.Digest {
+		t.Fatal("expected a new revision")
+	}
+
+	for _, tc := range []struct {
+		rev  *model.Revision
+		want *hcl.Parser
+	}{{rev, first}, {latest, second}} {
+		got := materialize(t, cat, tc.rev).Symbols.QualifierIndex
+		want := tc.want.Symbols.QualifierIndex
+		if !maps.Equal(got, want) {
+			for q, d := range want {
+				if got[q] != d {
+					t.Errorf("%s: got %s, want %s", q, got[q], d)
+				}
+			}
+			for q := range got {
+				if _, ok := want[q]; !ok {
+					t.Errorf("unexpected symbol %s", q)
+				}
+			}
+		}
+	}
+}
@@ -1,14 +1,21 @@
 package hcl
 
 import (
+	"cmp"
 	"errors"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	git "github.com/go-git/go-git/v5"
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 
+	"github.com/tychonis/cyanotype/core/artifact"
+	"github.com/tychonis/cyanotype/internal/cerror"
 	"github.com/tychonis/cyanotype/internal/symbols"
 	"github.com/tychonis/cyanotype/model"
 )
@@ -18,34 +25,95 @@ const IMPLICIT string = "implicit."
 const DEFAULT string = "default"
 
 type ParserOptions struct {
+	// IgnoreArtifacts skips artifact blocks, so nothing is read or fetched.
 	IgnoreArtifacts bool
+	// ArtifactCache is where remote artifacts are kept. It defaults to
+	// .bpc/artifacts when the working directory holds a catalog.
+	ArtifactCache string
+
+	// Vars holds raw values for root module variables, keyed by name.
+	Vars map[string]string
+	// VarFiles are variable definition files, applied before Vars.
+	VarFiles []string
+
+	// Overlay replaces the content of files on disk, keyed by filename, for
+	// editors with unsaved changes.
+	Overlay map[string][]byte
+	// NoLock keeps the lock file untouched, for tools building on every edit.
+	NoLock bool
+	// Cache is shared by builds of the same workspace, when set.
+	Cache *BuildCache
 }
 
 type Parser struct {
 	Symbols *symbols.SymbolTable
 
 	Options *ParserOptions
+
+	contexts map[string]*ParserContext
+	// ranges records where each authored symbol is defined.
+	ranges map[model.Digest]hcl.Range
+	// parsing holds the symbols being parsed, to detect cyclic references.
+	parsing map[*UnprocessedSymbol]bool
+	// order lists symbols after the symbols they reference, cyclic holds
+	// the symbols left out of it.
+	order  []*UnprocessedSymbol
+	cyclic map[*UnprocessedSymbol]bool
+	// unplaced holds the components of drawings written without placement.
+	unplaced []hcl.Range
+	// artifacts is created on the first remote artifact.
+	artifacts *artifact.Cache
+
+	files *hclparse.Parser
+	diags hcl.Diagnostics
+
+	// repos caches git repositories by URL, sources records the commit
+	// each git import was read from.
+	repos    map[string]*git.Repository
+	sources  map[string]string
+	imported map[string]bool
+	// fileModules maps every file read to the module it belongs to.
+	fileModules map[string]string
+	parsed      bool
+
+	// root is the folder of the workspace manifest, if there is one.
+	root         string
+	dependencies map[string]*Dependency
+	lock         Lock
+	locked       Lock
 }
 
 type ParserContext struct {
 	ImportStack []string
+
+	Variables map[string]*Variable
+	Locals    map[string]*hcl.Attribute
+	Eval      *hcl.EvalContext
+
+	// InstanceKey is set for members of a for_each or count family.
+	InstanceKey string
 }
 
-func NewParserContext() *ParserContext {
+func newParserContext(importStack []string) *ParserContext {
 	return &ParserContext{
-		ImportStack: []string{"."},
+		ImportStack: importStack,
+		Variables:   make(map[string]*Variable),
+		Locals:      make(map[string]*hcl.Attribute),
+		Eval:        newEvalContext(),
 	}
 }
 
+func NewParserContext() *ParserContext {
+	return newParserContext([]string{"."})
+}
+
 func (ctx *ParserContext) Import(path string) (*ParserContext, error) {
 	for _, existed := range ctx.ImportStack {
 		if existed == path {
 			return nil, errors.New("cyclic import detected")
 		}
 	}
-	return &ParserContext{
-		ImportStack: append([]string{path}, ctx.ImportStack...),
-	}, nil
+	return newParserContext(append([]string{path}, ctx.ImportStack...)), nil
 }
 
 func (ctx *ParserContext) CurrentModule() string {
@@ -63,8 +131,77 @@ func (ctx *ParserContext) NameToQualifier(name string) string {
 func NewParser() *Parser {
 	return &Parser{
 		Symbols: symbols.NewSymbolTable(),
-		Options: &ParserOptions{},
+		Options: &ParserOptions{
+			Vars: make(map[string]string),
+		},
+
+		contexts:    make(map[string]*ParserContext),
+		ranges:      make(map[model.Digest]hcl.Range),
+		parsing:     make(map[*UnprocessedSymbol]bool),
+		files:       hclparse.NewParser(),
+		repos:       make(map[string]*git.Repository),
+		sources:     make(map[string]string),
+		imported:    make(map[string]bool),
+		fileModules: make(map[string]string),
+		locked:      make(Lock),
+	}
+}
+
+// Files returns the sources read so far, keyed by filename, for printing
+// diagnostics with snippets.
+func (p *Parser) Files() map[string]*hcl.File {
+	return p.files.Files()
+}
+
+// Unplaced returns the components of drawings built by the last Build that
+// have no placement. They are placed at the origin.
+func (p *Parser) Unplaced() []hcl.Range {
+	return p.unplaced
+}
+
+// Diagnostics returns every problem found by the last Build.
+func (p *Parser) Diagnostics() hcl.Diagnostics {
+	return p.diags
+}
+
+// report records err as diagnostics, skipping ones already reported. The
+// same failure is often reached again through references to the symbol.
+func (p *Parser) report(err error) {
+	for _, diag := range cerror.Diagnostics(err) {
+		duplicate := slices.ContainsFunc(p.diags, func(d *hcl.Diagnostic) bool {
+			return d.Summary == diag.Summary && d.Detail == diag.Detail && sameRange(d.Subject, diag.Subject)
+		})
+		if !duplicate {
+			p.diags = append(p.diags, diag)
+		}
+	}
+}
+
+// compareDiagnostics orders diagnostics by file and position, since symbols
+// are processed in map order.
+func compareDiagnostics(a, b *hcl.Diagnostic) int {
+	switch {
+	case a.Subject == nil || b.Subject == nil:
+		return cmp.Compare(btoi(a.Subject != nil), btoi(b.Subject != nil))
+	case a.Subject.Filename != b.Subject.Filename:
+		return strings.Compare(a.Subject.Filename, b.Subject.Filename)
+	default:
+		return cmp.Compare(a.Subject.Start.Byte, b.Subject.Start.Byte)
+	}
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
 	}
+	return 0
+}
+
+func sameRange(a, b *hcl.Range) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
 
 func (p *Parser) Resolve(ctx *ParserContext, ref []string) (model.Symbol, error) {
@@ -86,10 +223,12 @@ func (p *Parser) parseFolder(ctx *ParserContext, dir string) error {
 		return err
 	}
 	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == EXTENSION {
+		if !entry.IsDir() && IsSource(entry.Name()) {
+			// Keep reading the other files, so one run reports every
+			// syntax error.
 			err = p.parseFile(ctx, filepath.Join(dir, entry.Name()))
 			if err != nil {
-				return err
+				p.report(err)
 			}
 		}
 	}
@@ -102,23 +241,40 @@ func (p *Parser) ParseFolder(dir string) error {
 }
 
 func (p *Parser) parseFile(ctx *ParserContext, filename string) error {
-	parser := hclparse.NewParser()
-	file, diags := parser.ParseHCLFile(filename)
+	src, ok := p.Options.Overlay[filename]
+	if !ok {
+		var err error
+		src, err = os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+	}
+	file, diags := p.parseSource(src, filename)
 	if diags.HasErrors() {
-		slog.Error("Failed to parse file.", "error", diags.Error())
 		return diags
 	}
+	return p.parseBody(ctx, file)
+}
+
+func (p *Parser) parseBody(ctx *ParserContext, file *hcl.File) error {
+	if _, ok := p.contexts[ctx.CurrentModule()]; !ok {
+		p.contexts[ctx.CurrentModule()] = ctx
+	}
 
 	content, ok := file.Body.(*hclsyntax.Body)
 	if !ok {
 		slog.Error("Failed to parse content.")
 		return errors.New("failed to parse content")
 	}
+	p.fileModules[content.SrcRange.Filename] = ctx.CurrentModule()
 
 	for _, block := range content.Blocks {
 		err := p.registerBlock(ctx, block)
 		if err != nil {
-			slog.Warn("Error parsing block.", "error", err)
+			if !cerror.HasRange(err) {
+				err = cerror.ErrorWithRange(err.Error(), block.DefRange())
+			}
+			p.report(err)
 		}
 	}
 	return nil
@@ -140,64 +296,91 @@ func (p *Parser) Parse(path string) error {
 	return p.ParseFile(path)
 }
 
+// Build parses and evaluates path. Errors are collected as diagnostics, and
+// Build stops after the first stage that reports any. When the workspace has
+// a manifest, the dependencies used are recorded in its lock file.
 func (p *Parser) Build(path string) error {
-	err := p.Parse(path)
-	if err != nil {
-		return err
+	p.diags = nil
+	p.unplaced = nil
+	p.parsed = false
+	if p.Options.Cache != nil {
+		p.repos = p.Options.Cache.repos
+	}
+	root := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		root = filepath.Dir(path)
+	}
+	stages := []func() error{
+		func() error { return p.loadManifest(root) },
+		func() error {
+			err := p.Parse(path)
+			p.parsed = err == nil && !p.diags.HasErrors()
+			return err
+		},
+		p.evaluateContexts,
+		p.expandFamilies,
+		p.processModules,
+		p.writeLock,
+	}
+	for _, stage := range stages {
+		err := stage()
+		if err != nil {
+			p.report(err)
+		}
+		if p.diags.HasErrors() {
+			slices.SortStableFunc(p.diags, compareDiagnostics)
+			return p.diags
+		}
 	}
-	return p.processModules()
+	return nil
+}
+
+// Parsed reports whether the last Build read every source file, even if a
+// later stage failed.
+func (p *Parser) Parsed() bool {
+	return p.parsed
 }
 
+// processModules parses symbols in dependency order. Symbols in a cycle are
+// reported and left out.
 func (p *Parser) processModules() error {
-	for _, module := range p.Symbols.Modules {
-		err := p.processModuleScope(module)
+	p.sortSymbols()
+	for _, s := range p.order {
+		slog.Debug("Process item", "item", s.displayQualifier())
+		_, err := p.ParseSymbol(s)
 		if err != nil {
-			return err
+			p.report(err)
 		}
 	}
 	return nil
 }
 
-func (p *Parser) processModuleScope(m *symbols.ModuleScope) error {
-	for _, symbol := range m.Symbols {
-		switch s := symbol.(type) {
-		case *symbols.ModuleScope:
-			err := p.processModuleScope(s)
-			if err != nil {
-				return err
-			}
-		case *UnprocessedSymbol:
-			slog.Debug("Process item", "item", s)
-			_, err := p.ParseSymbol(s)
-			if err != nil {
-				slog.Warn("Error adding item.", "error", err, "item", s)
-			}
-		default:
-		}
+// resolveConcreteRef returns the symbol referenced by ref, parsing it first
+// if needed.
+func (p *Parser) resolveConcreteRef(ctx *ParserContext, ref Ref) (model.ConcreteSymbol, error) {
+	qualifier := refToQualifier(ctx, ref)
+	sym, err := p.Symbols.FindConcreteSymbol(qualifier)
+	if err == nil {
+		return sym, nil
 	}
-	return nil
+	if err != symbols.ErrNotFound {
+		return nil, err
+	}
+	resolved, err := p.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	unprocessed, ok := resolved.(*UnprocessedSymbol)
+	if !ok {
+		return nil, errors.New("wrong symbol type")
+	}
+	return p.ParseSymbol(unprocessed)
 }
 
 func (p *Parser) resolveBOMLineRef(ctx *ParserContext, ref Ref) (*model.Item, error) {
-	qualifier := refToQualifier(ctx, ref)
-	itemSym, err := p.Symbols.FindConcreteSymbol(qualifier)
+	itemSym, err := p.resolveConcreteRef(ctx, ref)
 	if err != nil {
-		if err != symbols.ErrNotFound {
-			return nil, err
-		} else {
-			sym, err := p.Resolve(ctx, ref)
-			if err != nil {
-				return nil, err
-			}
-			unprocessed, ok := sym.(*UnprocessedSymbol)
-			if !ok {
-				return nil, errors.New("wrong symbol type")
-			}
-			itemSym, err = p.ParseSymbol(unprocessed)
-			if err != nil {
-				return nil, err
-			}
-		}
+		return nil, err
 	}
 
 	item, ok := itemSym.(*model.Item)
@@ -209,12 +392,18 @@ func (p *Parser) resolveBOMLineRef(ctx *ParserContext, ref Ref) (*model.Item, er
 
 func (p *Parser) ResolveBOMLine(ctx *ParserContext, line *UnresolvedBOMLine) (*model.BOMLine, error) {
 	item, err := p.resolveBOMLineRef(ctx, line.Ref)
+	if err != nil {
+		return nil, line.refError(err)
+	}
+	err = checkUnit(line, item.Content, item.Qualifier)
 	if err != nil {
 		return nil, err
 	}
 	return &model.BOMLine{
-		Name: line.Name,
-		Item: item.Digest,
-		Qty:  line.Qty,
+		Name:   line.Name,
+		Item:   item.Digest,
+		Qty:    line.Qty,
+		Unit:   line.Unit,
+		Refdes: line.Refdes,
 	}, nil
 }
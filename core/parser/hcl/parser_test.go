@@ -0,0 +1,1027 @@
+package hcl_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tychonis/cyanotype/core/catalog"
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+	"github.com/tychonis/cyanotype/core/process"
+	"github.com/tychonis/cyanotype/internal/stable"
+	"github.com/tychonis/cyanotype/model"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func findItem(t *testing.T, p *hcl.Parser, qualifier string) *model.Item {
+	t.Helper()
+	sym, err := p.Symbols.FindConcreteSymbol(qualifier)
+	if err != nil {
+		t.Fatalf("symbol %s not found: %v", qualifier, err)
+	}
+	item, ok := sym.(*model.Item)
+	if !ok {
+		t.Fatalf("symbol %s is not an item", qualifier)
+	}
+	return item
+}
+
+const variablesSource = `
+variable "prefix" {
+  default = "SK"
+}
+
+variable "legs" {
+  type    = number
+  default = 2
+}
+
+locals {
+  wheels = local.legs * 2
+  legs   = var.legs
+}
+
+item "wheel" {
+  part_number = format("%s-%04d", var.prefix, 2001)
+}
+
+item "assembly" {
+  part_number = "${var.prefix}-1001"
+  from = [
+    {
+      name = "wheel"
+      ref  = wheel
+      qty  = local.wheels
+    },
+  ]
+}
+`
+
+func TestBuildEvaluatesVariablesAndLocals(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"main.bpo": variablesSource})
+
+	p := hcl.NewParser()
+	err := p.Build(dir)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	if pn := findItem(t, p, ".assembly").Content.PartNumber; pn != "SK-1001" {
+		t.Errorf("want part number SK-1001, got %s", pn)
+	}
+	if pn := findItem(t, p, ".wheel").Content.PartNumber; pn != "SK-2001" {
+		t.Errorf("want part number SK-2001, got %s", pn)
+	}
+	sym, err := p.Symbols.FindConcreteSymbol(".assembly.__process__")
+	if err != nil {
+		t.Fatalf("companion process not found: %v", err)
+	}
+	input := sym.(*process.Process).Input()
+	if len(input) != 1 || input[0].Qty != 4 {
+		t.Errorf("want a single input with qty 4, got %v", input)
+	}
+}
+
+func TestBuildVariableOverrides(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.bpo":     variablesSource,
+		"prod.bpovars": `prefix = "PR"`,
+	})
+
+	p := hcl.NewParser()
+	p.Options.VarFiles = []string{filepath.Join(dir, "prod.bpovars")}
+	p.Options.Vars["legs"] = "3"
+	err := p.Build(dir)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	if pn := findItem(t, p, ".assembly").Content.PartNumber; pn != "PR-1001" {
+		t.Errorf("want part number PR-1001, got %s", pn)
+	}
+
+	dir = writeFiles(t, map[string]string{
+		"main.bpo":     variablesSource,
+		"prod.bpovars": "prefix = \"PR\"\nprefx  = \"PR\"\n",
+	})
+	p = hcl.NewParser()
+	p.Options.VarFiles = []string{filepath.Join(dir, "prod.bpovars")}
+	err = p.Build(dir)
+	diags := p.Diagnostics()
+	if err == nil || len(diags) != 1 || diags[0].Subject == nil || diags[0].Subject.Start.Line != 2 {
+		t.Errorf("expected the undeclared variable to be reported on line 2, got %v", diags)
+	}
+}
+
+func TestBuildRequiredVariable(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"main.bpo": `variable "prefix" {}`})
+
+	p := hcl.NewParser()
+	err := p.Build(dir)
+	if err == nil {
+		t.Fatal("expected error for missing required variable")
+	}
+}
+
+func TestBuildCyclicLocals(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"main.bpo": `
+locals {
+  a = local.b
+  b = local.a
+}
+`})
+
+	p := hcl.NewParser()
+	err := p.Build(dir)
+	if err == nil {
+		t.Fatal("expected error for cyclic locals")
+	}
+}
+
+func TestBuildExpandsFamilies(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"main.bpo": `
+item "bolt" {
+  for_each    = { "M3x10" = "10", "M4x12" = "12" }
+  part_number = "B-${each.key}"
+  length      = each.value
+}
+
+item "leg" {
+  count       = 2
+  part_number = "L-${count.index}"
+}
+
+item "assembly" {
+  from = [
+    {
+      name = "bolt"
+      ref  = bolt["M3x10"]
+      qty  = 8
+    },
+    {
+      name = "leg"
+      ref  = leg[1]
+    },
+  ]
+}
+`})
+
+	p := hcl.NewParser()
+	err := p.Build(dir)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	m3 := findItem(t, p, `.bolt["M3x10"]`)
+	m4 := findItem(t, p, `.bolt["M4x12"]`)
+	if m3.Content.PartNumber != "B-M3x10" || m3.Content.Details["length"] != "10" {
+		t.Errorf("unexpected content for M3x10: %+v", m3.Content)
+	}
+	if m3.Digest == m4.Digest {
+		t.Error("family members share a digest")
+	}
+	if pn := findItem(t, p, ".leg[1]").Content.PartNumber; pn != "L-1" {
+		t.Errorf("want part number L-1, got %s", pn)
+	}
+
+	coM3, err := p.Symbols.FindConcreteSymbol(`.bolt["M3x10"].__coitem__`)
+	if err != nil {
+		t.Fatalf("companion coitem not found: %v", err)
+	}
+	coM4, err := p.Symbols.FindConcreteSymbol(`.bolt["M4x12"].__coitem__`)
+	if err != nil {
+		t.Fatalf("companion coitem not found: %v", err)
+	}
+	if coM3.GetDigest() == coM4.GetDigest() {
+		t.Error("family members share a companion coitem")
+	}
+
+	sym, err := p.Symbols.FindConcreteSymbol(".assembly.__process__")
+	if err != nil {
+		t.Fatalf("companion process not found: %v", err)
+	}
+	input := sym.(*process.Process).Input()
+	if len(input) != 2 || input[0].Item != coM3.GetDigest() {
+		t.Errorf("assembly does not reference bolt[\"M3x10\"]: %v", input)
+	}
+}
+
+func TestBuildTypedDetails(t *testing.T) {
+	src := `
+contract "bore" {
+  diameter = 8
+  fit      = "H7"
+}
+
+item "bearing" {
+  part_number = "BR-608"
+  mass        = 0.012
+  sealed      = true
+  tolerances  = { bore = "H7", outer = "h6" }
+  sizes       = [8, 22, 7]
+}
+`
+	build := func() *hcl.Parser {
+		p := hcl.NewParser()
+		err := p.Build(writeFiles(t, map[string]string{"main.bpo": src}))
+		if err != nil {
+			t.Fatalf("Build error: %v", err)
+		}
+		return p
+	}
+
+	p := build()
+	item := findItem(t, p, ".bearing")
+	details := item.Content.Details
+	if details["mass"] != 0.012 || details["sealed"] != true {
+		t.Errorf("unexpected scalar details: %v", details)
+	}
+	tolerances, ok := details["tolerances"].(stable.Map)
+	if !ok || tolerances["bore"] != "H7" {
+		t.Errorf("unexpected nested details: %v", details["tolerances"])
+	}
+	sizes, ok := details["sizes"].([]any)
+	if !ok || len(sizes) != 3 || sizes[1] != 22.0 {
+		t.Errorf("unexpected list details: %v", details["sizes"])
+	}
+	if _, ok := details["impl"]; ok {
+		t.Error("reserved key impl leaked into details")
+	}
+
+	sym, err := p.Symbols.FindConcreteSymbol(".bore")
+	if err != nil {
+		t.Fatalf("contract not found: %v", err)
+	}
+	if params := sym.(*model.Contract).Params; params["diameter"] != 8.0 || params["fit"] != "H7" {
+		t.Errorf("unexpected contract params: %v", params)
+	}
+
+	if again := findItem(t, build(), ".bearing"); again.Digest != item.Digest {
+		t.Errorf("digest is not deterministic: %s != %s", again.Digest, item.Digest)
+	}
+}
+
+func TestBuildBOMLineUnits(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"main.bpo": `
+item "cable" {
+  unit = "m"
+}
+
+item "harness" {
+  from = [
+    { name = "a", ref = cable, qty = "250 mm" },
+    { name = "b", ref = cable, qty = 2, unit = "m" },
+    { name = "c", ref = cable, qty = 3 },
+  ]
+}
+`})
+	p := hcl.NewParser()
+	err := p.Build(dir)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	if u := findItem(t, p, ".cable").Content.Unit; u != "m" {
+		t.Errorf("cable unit = %q, want m", u)
+	}
+	sym, err := p.Symbols.FindConcreteSymbol(".harness.__process__")
+	if err != nil {
+		t.Fatalf("process not found: %v", err)
+	}
+	input := sym.(*process.Process).Input()
+	if len(input) != 3 {
+		t.Fatalf("expected 3 inputs, got %d", len(input))
+	}
+	if input[0].Qty != 250 || input[0].Unit != "mm" || input[1].Qty != 2 || input[1].Unit != "m" {
+		t.Errorf("unexpected inputs: %+v %+v", input[0], input[1])
+	}
+	if input[2].Qty != 3 || input[2].Unit != "" {
+		t.Errorf("line without unit should keep the unit of its item: %+v", input[2])
+	}
+}
+
+func TestBuildExplicitProcess(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"main.bpo": `
+item "bar" {
+  unit = "mm"
+}
+
+coitem "fastener" {}
+
+item "bracket" {
+  part_number = "BR-1"
+}
+
+process "bracket_cnc" {
+  input = [
+    { name = "stock", ref = bar, qty = "0.12 m" },
+    { name = "screw", ref = fastener, qty = 2 },
+  ]
+  output = [
+    { name = "bracket", ref = bracket },
+  ]
+  operation = "machining"
+}
+
+process "bracket_layout" {
+  type = "drawing"
+  input = [
+    { name = "screw", ref = fastener },
+  ]
+  output = [
+    { name = "bracket", ref = bracket },
+  ]
+}
+`})
+	p := hcl.NewParser()
+	err := p.Build(dir)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	sym, err := p.Symbols.FindConcreteSymbol(".bracket_cnc")
+	if err != nil {
+		t.Fatalf("process not found: %v", err)
+	}
+	cnc := sym.(*process.Process)
+	content, ok := cnc.Content.(*process.Abstract)
+	if !ok {
+		t.Fatalf("expected abstract content, got %T", cnc.Content)
+	}
+	if content.Details["operation"] != "machining" {
+		t.Errorf("unexpected details: %v", content.Details)
+	}
+	bar, err := p.Symbols.FindConcreteSymbol(".bar.__coitem__")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fastener, err := p.Symbols.FindConcreteSymbol(".fastener")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := cnc.Input()
+	if len(input) != 2 || input[0].Item != bar.GetDigest() || input[0].Unit != "m" || input[1].Item != fastener.GetDigest() {
+		t.Errorf("unexpected inputs: %+v", input)
+	}
+	bracket := findItem(t, p, ".bracket")
+	if output := cnc.Output(); len(output) != 1 || output[0].Item != bracket.Digest {
+		t.Errorf("unexpected outputs: %+v", output)
+	}
+
+	sym, err = p.Symbols.FindConcreteSymbol(".bracket_layout")
+	if err != nil {
+		t.Fatalf("process not found: %v", err)
+	}
+	if typ := sym.(*process.Process).Content.GetType(); typ != process.DRAWING {
+		t.Errorf("expected drawing, got %s", typ)
+	}
+}
+
+func TestBuildCoProcess(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"main.bpo": `
+item "bolt_a" {}
+
+item "bolt_b" {}
+
+coprocess "bolt_b_for_a" {
+  from     = bolt_b
+  to       = bolt_a
+  priority = 10
+}
+`})
+	p := hcl.NewParser()
+	err := p.Build(dir)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	sym, err := p.Symbols.FindConcreteSymbol(".bolt_b_for_a")
+	if err != nil {
+		t.Fatalf("coprocess not found: %v", err)
+	}
+	cp := sym.(*process.CoProcess)
+	coItem, err := p.Symbols.FindConcreteSymbol(".bolt_a.__coitem__")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input := cp.Input(); len(input) != 1 || input[0].Item != findItem(t, p, ".bolt_b").Digest {
+		t.Errorf("unexpected input: %+v", input)
+	}
+	if output := cp.Output(); len(output) != 1 || output[0].Item != coItem.GetDigest() {
+		t.Errorf("unexpected output: %+v", output)
+	}
+	if priority := cp.Content.GetDetails()["priority"]; priority != 10.0 {
+		t.Errorf("unexpected priority: %v", priority)
+	}
+}
+
+const contractsSource = `
+contract "m3" {
+  thread = "M3"
+}
+
+coitem "fastener" {
+  req = [m3]
+}
+
+item "screw" {
+  impl = [m3]
+}
+
+item "rivet" {}
+
+coprocess "screw_as_fastener" {
+  from = screw
+  to   = fastener
+}
+`
+
+func TestCheckContracts(t *testing.T) {
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{"main.bpo": contractsSource}))
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	screw := findItem(t, p, ".screw")
+	if len(screw.Implement) != 1 {
+		t.Fatalf("expected screw to implement m3, got %v", screw.Implement)
+	}
+	err = p.CheckContracts()
+	if err != nil {
+		t.Errorf("unexpected violation: %v", err)
+	}
+}
+
+func TestCheckContractsViolation(t *testing.T) {
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{"main.bpo": contractsSource + `
+coprocess "rivet_as_fastener" {
+  from = rivet
+  to   = fastener
+}
+`}))
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	err = p.CheckContracts()
+	if err == nil {
+		t.Fatal("expected a contract violation")
+	}
+	want := "main.bpo:21 .rivet does not fulfil .m3 required by .fastener"
+	if !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("unexpected error:\n got: %s\nwant: %s", err, want)
+	}
+}
+
+func TestBuildCollectsDiagnostics(t *testing.T) {
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{"main.bpo": `
+item "a" {
+  from = [{ ref = missing_a, qty = 1 }]
+}
+
+item "b" {
+  from = [{ ref = missing_b, qty = 1 }]
+}
+`}))
+	if err == nil {
+		t.Fatal("expected build to fail")
+	}
+	diags := p.Diagnostics()
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %d: %v", len(diags), diags)
+	}
+	for i, line := range []int{3, 7} {
+		if diags[i].Subject == nil || diags[i].Subject.Start.Line != line {
+			t.Errorf("diagnostic %d should point at line %d: %v", i, line, diags[i])
+		}
+	}
+	for i, ref := range []string{"missing_a", "missing_b"} {
+		diag := diags[i]
+		if diag.Subject == nil || diag.Subject.Start.Column != 19 || diag.Subject.End.Column != 28 ||
+			!strings.Contains(diag.Summary+diag.Detail, ref) {
+			t.Errorf("diagnostic %d should point at ref %s: %v", i, ref, diag)
+		}
+	}
+}
+
+const attributesSource = `
+item "motor" {
+  part_number = "MT-1"
+  voltage     = 24
+}
+
+item "deck" {
+  part_number = "DK-${motor.details.voltage}"
+  motors      = 2
+}
+
+contract "drive" {
+  voltage = motor.details.voltage
+}
+
+item "frame" {
+  part_number = "FR-1"
+}
+
+process "frame_proc" {
+  input  = [{ name = "deck", ref = deck, qty = deck.details.motors }]
+  output = [{ name = "frame", ref = frame }]
+}
+
+item "cart" {
+  part_number = "${deck.part_number}-C"
+  steps       = length(frame_proc.input)
+  first       = frame_proc.input[0].qty
+}
+`
+
+func TestBuildAttributeTraversal(t *testing.T) {
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{"main.bpo": attributesSource}))
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	cart := findItem(t, p, ".cart")
+	if cart.Content.PartNumber != "DK-24-C" {
+		t.Errorf("unexpected part number %s", cart.Content.PartNumber)
+	}
+	if cart.Content.Details["steps"] != 1.0 || cart.Content.Details["first"] != 2.0 {
+		t.Errorf("unexpected details: %v", cart.Content.Details)
+	}
+	sym, err := p.Symbols.FindConcreteSymbol(".drive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := sym.(*model.Contract).Params["voltage"]; v != 24.0 {
+		t.Errorf("unexpected contract params: %v", sym.(*model.Contract).Params)
+	}
+	val, err := findItem(t, p, ".deck").Resolve([]string{"details", "motors"})
+	if err != nil || val.(*model.Value).Value != 2.0 {
+		t.Errorf("unexpected resolved attribute %v: %v", val, err)
+	}
+}
+
+func TestBuildAttributeErrors(t *testing.T) {
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{"main.bpo": `
+item "motor" {
+  voltage = 24
+}
+
+item "a" {
+  part_number = motor.details
+}
+
+item "b" {
+  watts = motor.details.current * 2
+}
+
+item "c" {
+  part_number = c.part_number
+}
+`}))
+	if err == nil {
+		t.Fatal("expected build to fail")
+	}
+	diags := p.Diagnostics()
+	if len(diags) != 3 {
+		t.Fatalf("expected 3 diagnostics, got %d: %v", len(diags), diags)
+	}
+	for i, line := range []int{7, 11, 14} {
+		if diags[i].Subject == nil || diags[i].Subject.Start.Line != line {
+			t.Errorf("diagnostic %d should point at line %d: %v", i, line, diags[i])
+		}
+	}
+}
+
+func TestBuildReferenceCycle(t *testing.T) {
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{"main.bpo": `
+item "a" {
+  from = [{ ref = b }]
+}
+
+item "b" {
+  from = [{ ref = c }]
+}
+
+item "c" {
+  part_number = a.part_number
+}
+
+item "d" {
+  from = [{ ref = a }]
+}
+
+item "e" {}
+`}))
+	if err == nil {
+		t.Fatal("expected build to fail")
+	}
+	diags := p.Diagnostics()
+	if len(diags) != 4 {
+		t.Fatalf("expected 4 diagnostics, got %d: %v", len(diags), diags)
+	}
+	for i, line := range []int{2, 6, 10} {
+		if diags[i].Summary != "cyclic reference between .a, .b, .c" {
+			t.Errorf("unexpected diagnostic %d: %v", i, diags[i])
+		}
+		if diags[i].Subject == nil || diags[i].Subject.Start.Line != line {
+			t.Errorf("diagnostic %d should point at line %d: %v", i, line, diags[i])
+		}
+	}
+	if diags[3].Subject == nil || diags[3].Subject.Start.Line != 15 {
+		t.Errorf("dependent diagnostic should point at line 15: %v", diags[3])
+	}
+	findItem(t, p, ".e")
+}
+
+func TestBuildPlacement(t *testing.T) {
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{"main.bpo": `
+coitem "wheel" {}
+
+item "cart" {}
+
+process "cart_layout" {
+  type = "drawing"
+  input = [
+    { name = "a", ref = wheel, placement = [0, 0, 0, 1, 1, 2, 3] },
+    { name = "b", ref = wheel, placement = {
+      position = [1, 0, 2]
+      rotation = { euler_deg = [0, 0, 90] }
+      units    = "in"
+    } },
+    { name = "c", ref = wheel, placement = { rotation = [0, 1, 0, 0] } },
+  ]
+  output = [{ name = "cart", ref = cart }]
+}
+`}))
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	sym, err := p.Symbols.FindConcreteSymbol(".cart_layout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drawing, ok := sym.(*process.Process).Content.(*process.Drawing)
+	if !ok || len(drawing.Components) != 3 {
+		t.Fatalf("unexpected content: %+v", sym.(*process.Process).Content)
+	}
+	near := func(a, b []float64) bool {
+		for i := range a {
+			if math.Abs(a[i]-b[i]) > 1e-9 {
+				return false
+			}
+		}
+		return true
+	}
+	want := []struct {
+		rotation model.Quaternion
+		position model.Vec3
+	}{
+		{model.Quaternion{0, 0, 0, 1}, model.Vec3{1, 2, 3}},
+		{model.Quaternion{0, 0, math.Sqrt2 / 2, math.Sqrt2 / 2}, model.Vec3{25.4, 0, 50.8}},
+		{model.Quaternion{0, 1, 0, 0}, model.Vec3{0, 0, 0}},
+	}
+	for i, comp := range drawing.Components {
+		if !near(comp.Rotation[:], want[i].rotation[:]) || !near(comp.Translation[:], want[i].position[:]) {
+			t.Errorf("component %s placed at %v %v, want %v %v",
+				comp.Name, *comp.Rotation, *comp.Translation, want[i].rotation, want[i].position)
+		}
+	}
+}
+
+func TestBuildPlacementErrors(t *testing.T) {
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{"main.bpo": `
+coitem "wheel" {}
+
+process "a" {
+  input = [{ ref = wheel, placement = [0, 0, 0, 2, 1, 2, 3] }]
+}
+
+process "b" {
+  input = [{ ref = wheel, placement = [1, 2, 3] }]
+}
+
+process "c" {
+  input = [{ ref = wheel, placement = { position = [1, 2, 3], units = "kg" } }]
+}
+
+process "d" {
+  input = [{ ref = wheel, placement = { rotation = { euler = [0, 0, 0] } } }]
+}
+`}))
+	if err == nil {
+		t.Fatal("expected build to fail")
+	}
+	diags := p.Diagnostics()
+	if len(diags) != 4 {
+		t.Fatalf("expected 4 diagnostics, got %d: %v", len(diags), diags)
+	}
+	for i, line := range []int{5, 9, 13, 17} {
+		if diags[i].Subject == nil || diags[i].Subject.Start.Line != line {
+			t.Errorf("diagnostic %d should point at line %d: %v", i, line, diags[i])
+		}
+	}
+}
+
+func TestBuildDrawingQty(t *testing.T) {
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{"main.bpo": `
+item "wheel" {}
+
+item "frame" {}
+
+item "cable" {
+  unit = "m"
+}
+
+process "a" {
+  type   = "drawing"
+  input  = [{ ref = wheel, qty = 2, placement = [0, 0, 0, 1, 0, 0, 0] }]
+  output = [{ ref = frame }]
+}
+
+item "cart" {
+  from = [{ ref = wheel, qty = 2, placement = [0, 0, 0, 1, 0, 0, 0] }]
+}
+
+item "trolley" {
+  from = [
+    { ref = wheel, placement = [0, 0, 0, 1, 0, 0, 0] },
+    { ref = cable, qty = "250 mm" },
+  ]
+}
+`}))
+	if err == nil {
+		t.Fatal("expected build to fail")
+	}
+	diags := p.Diagnostics()
+	want := []struct {
+		line int
+		text string
+	}{
+		{12, "must have qty 1, write one line per placement"},
+		{17, "must have qty 1, write one line per placement"},
+		{23, "must be a whole number of pieces, not 250 mm"},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %d: %v", len(want), len(diags), diags)
+	}
+	for i, diag := range diags {
+		if diag.Subject == nil || diag.Subject.Start.Line != want[i].line ||
+			!strings.Contains(diag.Detail+diag.Summary, want[i].text) {
+			t.Errorf("diagnostic %d should say %q at line %d: %v", i, want[i].text, want[i].line, diag)
+		}
+	}
+}
+
+func TestBuildDrawingUnplacedPieces(t *testing.T) {
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{"main.bpo": `
+item "wheel" {}
+
+item "screw" {}
+
+item "cart" {
+  from = [
+    { name = "wheel", ref = wheel, placement = [0, 0, 0, 1, 0, 0, 0] },
+    { name = "screws", ref = screw, qty = 4 },
+  ]
+}
+
+process "cart_layout" {
+  type   = "drawing"
+  input  = [{ name = "screws", ref = screw, qty = 2 }]
+  output = [{ ref = cart }]
+}
+`}))
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	for q, want := range map[string][]string{
+		".cart.__process__": {"wheel", "screws[0]", "screws[1]", "screws[2]", "screws[3]"},
+		".cart_layout":      {"screws[0]", "screws[1]"},
+	} {
+		sym, err := p.Symbols.FindConcreteSymbol(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drawing, ok := sym.(*process.Process).Content.(*process.Drawing)
+		if !ok {
+			t.Fatalf("%s: expected drawing, got %T", q, sym.(*process.Process).Content)
+		}
+		names := make([]string, 0, len(drawing.Components))
+		for _, comp := range drawing.Components {
+			names = append(names, comp.Name)
+		}
+		if !slices.Equal(names, want) {
+			t.Errorf("%s: want components %v, got %v", q, want, names)
+		}
+	}
+	if unplaced := p.Unplaced(); len(unplaced) != 2 {
+		t.Errorf("expected one unplaced line per drawing, got %v", unplaced)
+	}
+}
+
+func TestBuildRefdes(t *testing.T) {
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{"main.bpo": `
+item "resistor" {}
+
+item "capacitor" {}
+
+item "board" {
+  from = [
+    { name = "pullups", ref = resistor, refdes = "R1..R12" },
+    { name = "bulk", ref = capacitor, qty = 2, refdes = ["C3", "C7"] },
+  ]
+}
+`}))
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	sym, err := p.Symbols.FindConcreteSymbol(".board.__process__")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := sym.(*process.Process).Input()
+	if len(input) != 2 || input[0].Qty != 12 || len(input[0].Refdes) != 12 || input[0].Refdes[11] != "R12" {
+		t.Fatalf("unexpected inputs: %+v", input)
+	}
+	if !slices.Equal(input[1].Refdes, []string{"C3", "C7"}) {
+		t.Errorf("unexpected designators %v", input[1].Refdes)
+	}
+}
+
+func TestBuildRefdesErrors(t *testing.T) {
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{"main.bpo": `
+item "resistor" {}
+
+item "a" {
+  from = [{ ref = resistor, qty = 3, refdes = "R1..R2" }]
+}
+
+item "b" {
+  from = [
+    { name = "x", ref = resistor, refdes = "R1..R4" },
+    { name = "y", ref = resistor, refdes = "R4" },
+  ]
+}
+
+item "c" {
+  from = [{ ref = resistor, refdes = "R3..R1" }]
+}
+`}))
+	if err == nil {
+		t.Fatal("expected build to fail")
+	}
+	diags := p.Diagnostics()
+	if len(diags) != 3 {
+		t.Fatalf("expected 3 diagnostics, got %d: %v", len(diags), diags)
+	}
+	for i, line := range []int{5, 11, 16} {
+		if diags[i].Subject == nil || diags[i].Subject.Start.Line != line {
+			t.Errorf("diagnostic %d should point at line %d: %v", i, line, diags[i])
+		}
+	}
+}
+
+func TestBuildCacheReusesUnchangedFiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"parts.bpo": `item "wheel" {
+  part_number = "W-1"
+}
+`,
+		"main.bpo": `item "cart" {
+  from = [{ ref = wheel, qty = 4 }]
+}
+`,
+	})
+	cache := hcl.NewBuildCache()
+	build := func() *hcl.Parser {
+		t.Helper()
+		p := hcl.NewParser()
+		p.Options.NoLock = true
+		p.Options.Cache = cache
+		err := p.Build(dir)
+		if err != nil {
+			t.Fatalf("Build error: %v", err)
+		}
+		if !p.Parsed() {
+			t.Error("expected every source to be parsed")
+		}
+		return p
+	}
+	first := build()
+
+	parts := filepath.Join(dir, "parts.bpo")
+	err := os.WriteFile(parts, []byte(`item "wheel" {
+  part_number = "W-2"
+}
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := build()
+	main := filepath.Join(dir, "main.bpo")
+	if first.Files()[main] != second.Files()[main] {
+		t.Error("unchanged file parsed again")
+	}
+	if first.Files()[parts] == second.Files()[parts] {
+		t.Error("changed file not parsed again")
+	}
+	if pn := findItem(t, second, ".wheel").Content.PartNumber; pn != "W-2" {
+		t.Errorf("want part number W-2, got %s", pn)
+	}
+
+	err = os.WriteFile(parts, []byte(`item "wheel" {`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := hcl.NewParser()
+	p.Options.Cache = cache
+	if p.Build(dir) == nil || p.Parsed() {
+		t.Error("expected a syntax error to stop the build before parsing completes")
+	}
+}
+
+func TestCommitDigestArtifact(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"main.bpo": `item "deck" {
+  part_number = "D-1"
+
+  artifact "cad" {
+    filename = "deck.step"
+    tag      = "cad"
+    source   = "digest:abc123"
+  }
+}
+`})
+	t.Chdir(t.TempDir())
+	p := hcl.NewParser()
+	p.Options.NoLock = true
+	err := p.Build(dir)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	cat := catalog.New("local")
+	err = p.Commit(cat)
+	if err != nil {
+		t.Fatalf("artifacts known by digest only should commit: %v", err)
+	}
+	ok, err := cat.HasArtifact("abc123")
+	if err != nil || ok {
+		t.Errorf("unexpected artifact content: %v %v", ok, err)
+	}
+}
+
+// Companion digests are stored in every catalog, so changing how they are
+// built makes the next commit record new companions for every item. Update
+// these along with the migration note of the README.
+func TestBuildCompanionDigests(t *testing.T) {
+	p := hcl.NewParser()
+	err := p.Build(writeFiles(t, map[string]string{"main.bpo": `
+item "wheel" {
+  part_number = "W-1"
+}
+
+item "cart" {
+  part_number = "C-1"
+  from        = [{ ref = wheel, qty = 4 }]
+}
+`}))
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	for q, want := range map[string]string{
+		".wheel.__coitem__":    "171216000749368ab5a28ae827cdc04898a2e38019e2cc04b30473d9140e6493",
+		".wheel.__coprocess__": "6258e7f353eb3028f67bfb4f5a6c2e2e2d977d1c9d2e1436571b80fc66ffc1d1",
+		".cart.__coitem__":     "8d38fb174db2a26e5ed0f2d191d5a38d517101ed0b5fd7ccc9aeb0c6238b5311",
+		".cart.__process__":    "8ad2a55b4a3337b61bc8c515aacd6b258b4e8a487a244bfd5d22251c105f50a6",
+	} {
+		sym, err := p.Symbols.FindConcreteSymbol(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sym.GetDigest() != want {
+			t.Errorf("%s: want digest %s, got %s", q, want, sym.GetDigest())
+		}
+	}
+}
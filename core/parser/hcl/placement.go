@@ -0,0 +1,174 @@
+package hcl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/tychonis/cyanotype/internal/unit"
+	"github.com/tychonis/cyanotype/model"
+)
+
+// PLACEMENT_UNIT is the length unit placements are normalized into.
+const PLACEMENT_UNIT = "mm"
+
+// QUATERNION_TOLERANCE is how far the norm of a quaternion may be from one.
+const QUATERNION_TOLERANCE = 1e-6
+
+// evalPlacement accepts either the 7 number tuple of a quaternion [x,y,z,w]
+// followed by a position, or an object with named fields:
+//
+//	placement = {
+//	  position = [x, y, z]
+//	  rotation = { euler_deg = [rx, ry, rz] }
+//	  units    = "in"
+//	}
+//
+// rotation is a quaternion tuple, or an object with one of quaternion,
+// euler_deg or euler_rad. Euler angles are extrinsic rotations about X, Y
+// then Z. Positions are converted into PLACEMENT_UNIT.
+func evalPlacement(ctx *ParserContext, expr hcl.Expression) (model.Placement, error) {
+	ret := model.IdentityPlacement
+	val, diags := expr.Value(ctx.Eval)
+	if diags.HasErrors() {
+		return ret, diags
+	}
+	if val.IsNull() || !val.IsWhollyKnown() {
+		return ret, errors.New("incorrect type")
+	}
+	if !val.Type().IsObjectType() && !val.Type().IsMapType() {
+		nums, err := ctyNumbers(val, 7)
+		if err != nil {
+			return ret, err
+		}
+		copy(ret.Rotation[:], nums[:4])
+		copy(ret.Position[:], nums[4:])
+		return ret, checkQuaternion(ret.Rotation)
+	}
+
+	fields, err := ctyFields(val, "position", "rotation", "units")
+	if err != nil {
+		return ret, err
+	}
+	if rotation, ok := fields["rotation"]; ok {
+		ret.Rotation, err = evalRotation(rotation)
+		if err != nil {
+			return ret, fmt.Errorf("rotation: %w", err)
+		}
+	}
+	if position, ok := fields["position"]; ok {
+		nums, err := ctyNumbers(position, 3)
+		if err != nil {
+			return ret, fmt.Errorf("position: %w", err)
+		}
+		copy(ret.Position[:], nums)
+	}
+	if units, ok := fields["units"]; ok {
+		if units.Type() != cty.String {
+			return ret, errors.New("units: incorrect type")
+		}
+		name := units.AsString()
+		u, err := unit.Lookup(name)
+		if err != nil {
+			return ret, fmt.Errorf("units: %w", err)
+		}
+		if u.Dimension != unit.Length {
+			return ret, fmt.Errorf("units: %s is not a length", name)
+		}
+		for i, v := range ret.Position {
+			ret.Position[i], _ = unit.Convert(v, name, PLACEMENT_UNIT)
+		}
+	}
+	return ret, nil
+}
+
+func evalRotation(val cty.Value) (model.Quaternion, error) {
+	var q model.Quaternion
+	if !val.Type().IsObjectType() && !val.Type().IsMapType() {
+		nums, err := ctyNumbers(val, 4)
+		if err != nil {
+			return q, err
+		}
+		copy(q[:], nums)
+		return q, checkQuaternion(q)
+	}
+	fields, err := ctyFields(val, "quaternion", "euler_deg", "euler_rad")
+	if err != nil {
+		return q, err
+	}
+	if len(fields) != 1 {
+		return q, errors.New("expected exactly one of quaternion, euler_deg or euler_rad")
+	}
+	for key, v := range fields {
+		if key == "quaternion" {
+			return evalRotation(v)
+		}
+		nums, err := ctyNumbers(v, 3)
+		if err != nil {
+			return q, fmt.Errorf("%s: %w", key, err)
+		}
+		if key == "euler_deg" {
+			for i := range nums {
+				nums[i] *= math.Pi / 180
+			}
+		}
+		q = eulerToQuaternion(nums[0], nums[1], nums[2])
+	}
+	return q, nil
+}
+
+// eulerToQuaternion composes rotations of rx about X, ry about Y then rz
+// about Z, in radians, into a quaternion [x,y,z,w].
+func eulerToQuaternion(rx, ry, rz float64) model.Quaternion {
+	cr, sr := math.Cos(rx/2), math.Sin(rx/2)
+	cp, sp := math.Cos(ry/2), math.Sin(ry/2)
+	cy, sy := math.Cos(rz/2), math.Sin(rz/2)
+	return model.Quaternion{
+		sr*cp*cy - cr*sp*sy,
+		cr*sp*cy + sr*cp*sy,
+		cr*cp*sy - sr*sp*cy,
+		cr*cp*cy + sr*sp*sy,
+	}
+}
+
+func checkQuaternion(q model.Quaternion) error {
+	norm := math.Sqrt(q[0]*q[0] + q[1]*q[1] + q[2]*q[2] + q[3]*q[3])
+	if math.Abs(norm-1) > QUATERNION_TOLERANCE {
+		return fmt.Errorf("quaternion %v is not a unit quaternion (norm %g)", q, norm)
+	}
+	return nil
+}
+
+// ctyFields returns the attributes of an object, rejecting unknown keys.
+func ctyFields(val cty.Value, allowed ...string) (map[string]cty.Value, error) {
+	ret := val.AsValueMap()
+	for key := range ret {
+		if !slices.Contains(allowed, key) {
+			return nil, fmt.Errorf("unknown field %q", key)
+		}
+	}
+	return ret, nil
+}
+
+// ctyNumbers reads a tuple or list of exactly n numbers.
+func ctyNumbers(val cty.Value, n int) ([]float64, error) {
+	if val.IsNull() || !val.Type().IsTupleType() && !val.Type().IsListType() {
+		return nil, fmt.Errorf("expected %d numbers", n)
+	}
+	if val.LengthInt() != n {
+		return nil, fmt.Errorf("expected %d numbers, got %d", n, val.LengthInt())
+	}
+	ret := make([]float64, 0, n)
+	for _, v := range val.AsValueSlice() {
+		if v.IsNull() || v.Type() != cty.Number {
+			return nil, fmt.Errorf("expected %d numbers", n)
+		}
+		f, _ := v.AsBigFloat().Float64()
+		ret = append(ret, f)
+	}
+	return ret, nil
+}
@@ -6,13 +6,20 @@ import (
 
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 
-	"github.com/tychonis/cyanotype/internal/symbols"
+	"github.com/tychonis/cyanotype/internal/cerror"
 )
 
 func (p *Parser) registerBlock(ctx *ParserContext, block *hclsyntax.Block) error {
+	if block.Type != "locals" && len(block.Labels) != 1 {
+		return cerror.ErrorWithRange(block.Type+" block must have exactly one label", block.DefRange())
+	}
 	switch block.Type {
 	case "import":
 		return p.parseImportBlock(ctx, block)
+	case "variable":
+		return p.parseVariableBlock(ctx, block)
+	case "locals":
+		return p.parseLocalsBlock(ctx, block)
 	default:
 		return p.registerUnprocessedBlock(ctx, block)
 	}
@@ -23,23 +30,10 @@ func pathToModuleName(path string) string {
 	return components[len(components)-1]
 }
 
-func (p *Parser) parseImportBlock(ctx *ParserContext, block *hclsyntax.Block) error {
-	path := block.Labels[0]
-	moduleName := pathToModuleName(path)
-	currentModule := ctx.CurrentModule()
-	err := p.Symbols.AddSymbol(currentModule, moduleName,
-		&symbols.Import{Symbols: p.Symbols, Identifier: path})
-	if err != nil {
-		return err
-	}
-	newCtx, err := ctx.Import(path)
-	if err != nil {
-		return err
-	}
-	return p.parseFolder(newCtx, path)
-}
-
 func (p *Parser) registerUnprocessedBlock(ctx *ParserContext, block *hclsyntax.Block) error {
+	if isFamilyBlock(block) {
+		return p.registerFamilyBlock(ctx, block)
+	}
 	name := block.Labels[0]
 	symbol := &UnprocessedSymbol{
 		Context: ctx,
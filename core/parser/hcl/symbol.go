@@ -14,5 +14,8 @@ type UnprocessedSymbol struct {
 }
 
 func (us *UnprocessedSymbol) Resolve(path []string) (model.Symbol, error) {
+	if len(path) > 0 {
+		return &AttributeRef{Symbol: us, Path: path}, nil
+	}
 	return us, nil
 }
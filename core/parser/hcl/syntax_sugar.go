@@ -2,12 +2,20 @@ package hcl
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
+	"math"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+
 	"github.com/tychonis/cyanotype/core/process"
 	"github.com/tychonis/cyanotype/core/qualifier"
+	"github.com/tychonis/cyanotype/internal/cerror"
+	"github.com/tychonis/cyanotype/internal/refdes"
+	"github.com/tychonis/cyanotype/internal/unit"
 	"github.com/tychonis/cyanotype/model"
 )
 
@@ -17,54 +25,175 @@ type UnresolvedBOMLine struct {
 	Name         string          `json:"name" yaml:"name"`
 	Ref          Ref             `json:"ref" yaml:"ref"`
 	Qty          float64         `json:"qty" yaml:"qty"`
+	Unit         string          `json:"unit,omitempty" yaml:"unit,omitempty"`
+	Range        hcl.Range       `json:"-" yaml:"-"`
+	RefRange     hcl.Range       `json:"-" yaml:"-"`
 	HasPlacement bool            `json:"-" yaml:"-"`
 	Placement    model.Placement `json:"placement,omitempty" yaml:"placement,omitempty"`
+	Refdes       []string        `json:"refdes,omitempty" yaml:"refdes,omitempty"`
+}
+
+// label names the line in messages, by its ref when it has no name.
+func (line *UnresolvedBOMLine) label() string {
+	if line.Name != "" {
+		return line.Name
+	}
+	return strings.Join(line.Ref, ".")
+}
+
+// refError points an error resolving the line's ref at the ref, unless it
+// already has a range of its own.
+func (line *UnresolvedBOMLine) refError(err error) error {
+	if cerror.HasRange(err) {
+		return err
+	}
+	return cerror.ErrorWithRange(err.Error(), line.RefRange)
 }
 
-func readBOMLine(ctx *ParserContext, expr *hclsyntax.ObjectConsExpr) *UnresolvedBOMLine {
+func readBOMLine(ctx *ParserContext, expr *hclsyntax.ObjectConsExpr) (*UnresolvedBOMLine, error) {
 	ret := &UnresolvedBOMLine{
 		Qty:          1,
+		Range:        expr.Range(),
 		HasPlacement: false,
 	}
+	var err error
+	var qtyUnit string
+	hasQty := false
 	for _, item := range expr.Items {
 		key := getObjectKey(item.KeyExpr)
 		switch key {
 		case "name":
-			val, _ := item.ValueExpr.Value(nil)
-			ret.Name = val.AsString()
+			ret.Name, err = evalString(ctx, item.ValueExpr)
 		case "ref":
-			ref, _ := exprToRef(ctx, item.ValueExpr)
-			ret.Ref = ref
+			ret.Ref, err = exprToRef(ctx, item.ValueExpr)
+			ret.RefRange = item.ValueExpr.Range()
 		case "qty":
-			val, _ := item.ValueExpr.Value(nil)
-			ret.Qty, _ = val.AsBigFloat().Float64()
+			hasQty = true
+			ret.Qty, qtyUnit, err = evalQuantity(ctx, item.ValueExpr)
+		case "unit":
+			ret.Unit, err = evalUnit(ctx, item.ValueExpr)
 		case "placement":
 			ret.HasPlacement = true
-			val, _ := item.ValueExpr.Value(nil)
-			slice := val.AsValueSlice()
-			if len(slice) != 7 {
-				slog.Warn("incorrect format for placement")
-				continue
-			}
-			for i, num := range slice[:4] {
-				ret.Placement.Rotation[i], _ = num.AsBigFloat().Float64()
-			}
-			for i, num := range slice[4:7] {
-				ret.Placement.Position[i], _ = num.AsBigFloat().Float64()
-			}
+			ret.Placement, err = evalPlacement(ctx, item.ValueExpr)
+		case "refdes":
+			ret.Refdes, err = evalRefdes(ctx, item.ValueExpr)
+		}
+		if err != nil {
+			return nil, cerror.ErrorWithRange(key+": "+err.Error(), item.ValueExpr.Range())
 		}
 	}
-	return ret
+	if qtyUnit != "" {
+		if ret.Unit != "" && ret.Unit != qtyUnit {
+			return nil, cerror.ErrorWithRange("qty unit "+qtyUnit+" conflicts with unit "+ret.Unit, ret.Range)
+		}
+		ret.Unit = qtyUnit
+	}
+	if ret.Refdes != nil {
+		err = checkRefdes(ret, hasQty)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
 }
 
-func parseBOMLinesAttr(ctx *ParserContext, attr *hcl.Attribute) []*UnresolvedBOMLine {
-	if attr == nil {
+// evalRefdes accepts a designator spec such as "R1..R12, C3", or a list of
+// them.
+func evalRefdes(ctx *ParserContext, expr hcl.Expression) ([]string, error) {
+	val, diags := expr.Value(ctx.Eval)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	if val.IsNull() || !val.IsWhollyKnown() {
+		return nil, errors.New("incorrect type")
+	}
+	specs := []cty.Value{val}
+	if val.Type().IsTupleType() || val.Type().IsListType() {
+		specs = val.AsValueSlice()
+	}
+	ret := make([]string, 0)
+	for _, spec := range specs {
+		if spec.IsNull() || spec.Type() != cty.String {
+			return nil, errors.New("incorrect type")
+		}
+		list, err := refdes.Expand(spec.AsString())
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, list...)
+	}
+	if d, ok := refdes.Duplicate(ret); ok {
+		return nil, fmt.Errorf("duplicate designator %s", d)
+	}
+	return ret, nil
+}
+
+// checkRefdes verifies that a line has one designator per piece. Without an
+// explicit qty, the quantity is the number of designators.
+func checkRefdes(line *UnresolvedBOMLine, hasQty bool) error {
+	if !unit.Compatible(line.Unit, unit.DEFAULT) {
+		return cerror.ErrorWithRange("refdes requires a quantity in pieces, got "+line.Unit, line.Range)
+	}
+	if !hasQty {
+		line.Qty = float64(len(line.Refdes))
 		return nil
 	}
+	if line.Qty != float64(len(line.Refdes)) {
+		return cerror.ErrorWithRange(fmt.Sprintf("qty %g does not match the %d designators of %s",
+			line.Qty, len(line.Refdes), line.Name), line.Range)
+	}
+	return nil
+}
+
+// evalQuantity accepts either a number or a string with a unit, e.g. "2.5 m".
+func evalQuantity(ctx *ParserContext, expr hcl.Expression) (float64, string, error) {
+	val, diags := expr.Value(ctx.Eval)
+	if diags.HasErrors() {
+		return 0, "", diags
+	}
+	if val.IsNull() || !val.IsKnown() {
+		return 0, "", errors.New("incorrect type")
+	}
+	switch val.Type() {
+	case cty.Number:
+		ret, _ := val.AsBigFloat().Float64()
+		return ret, "", nil
+	case cty.String:
+		return unit.ParseQuantity(val.AsString())
+	default:
+		return 0, "", errors.New("incorrect type")
+	}
+}
+
+func evalUnit(ctx *ParserContext, expr hcl.Expression) (string, error) {
+	name, err := evalString(ctx, expr)
+	if err != nil {
+		return "", err
+	}
+	_, err = unit.Lookup(name)
+	return name, err
+}
+
+func getUnit(ctx *ParserContext, attrs hcl.Attributes) (string, error) {
+	attr, ok := attrs["unit"]
+	if !ok {
+		return "", nil
+	}
+	ret, err := evalUnit(ctx, attr.Expr)
+	if err != nil {
+		return "", cerror.ErrorWithRange("unit: "+err.Error(), attr.Expr.Range())
+	}
+	return ret, nil
+}
+
+func parseBOMLinesAttr(ctx *ParserContext, attr *hcl.Attribute) ([]*UnresolvedBOMLine, error) {
+	if attr == nil {
+		return nil, nil
+	}
 
 	expr, ok := attr.Expr.(*hclsyntax.TupleConsExpr)
 	if !ok {
-		return nil
+		return nil, nil
 	}
 
 	comps := make([]*UnresolvedBOMLine, 0)
@@ -73,10 +202,28 @@ func parseBOMLinesAttr(ctx *ParserContext, attr *hcl.Attribute) []*UnresolvedBOM
 		if !ok {
 			continue
 		}
-		comp := readBOMLine(ctx, obj)
+		comp, err := readBOMLine(ctx, obj)
+		if err != nil {
+			return nil, err
+		}
 		comps = append(comps, comp)
 	}
-	return comps
+	return comps, checkUniqueRefdes(comps)
+}
+
+// checkUniqueRefdes rejects designators used by two lines of an assembly.
+func checkUniqueRefdes(lines []*UnresolvedBOMLine) error {
+	owner := make(map[string]*UnresolvedBOMLine)
+	for _, line := range lines {
+		for _, d := range line.Refdes {
+			if other, ok := owner[d]; ok {
+				return cerror.ErrorWithRange(fmt.Sprintf("designator %s of %s is already used by %s",
+					d, line.Name, other.Name), line.Range)
+			}
+			owner[d] = line
+		}
+	}
+	return nil
 }
 
 func (p *Parser) processKeywordFROM(ctx *ParserContext, from []*UnresolvedBOMLine) (process.ProcessContent, error) {
@@ -96,6 +243,10 @@ func (p *Parser) processKeywordFROM(ctx *ParserContext, from []*UnresolvedBOMLin
 
 	for _, comp := range from {
 		item, err := p.resolveBOMLineRef(ctx, comp.Ref)
+		if err != nil {
+			return nil, comp.refError(err)
+		}
+		err = checkUnit(comp, item.Content, item.Qualifier)
 		if err != nil {
 			return nil, err
 		}
@@ -111,19 +262,31 @@ func (p *Parser) processKeywordFROM(ctx *ParserContext, from []*UnresolvedBOMLin
 		if drawing {
 			if !comp.HasPlacement {
 				slog.Warn("component has no placement for drawing", "component", comp.Name, "ref", comp.Ref)
-				comp.Placement = model.IdentityPlacement
+				p.unplaced = append(p.unplaced, comp.Range)
+			}
+			pieces, err := drawingPieces(comp, baseUnit(item.Content))
+			if err != nil {
+				return nil, err
+			}
+			for _, piece := range pieces {
+				component := &process.Component{
+					Name:        piece.Name,
+					CoItem:      coItemSym.GetDigest(),
+					Rotation:    &piece.Placement.Rotation,
+					Translation: &piece.Placement.Position,
+				}
+				if len(piece.Refdes) == 1 {
+					component.Refdes = piece.Refdes[0]
+				}
+				components = append(components, component)
 			}
-			components = append(components, &process.Component{
-				Name:        comp.Name,
-				CoItem:      coItemSym.GetDigest(),
-				Rotation:    &comp.Placement.Rotation,
-				Translation: &comp.Placement.Position,
-			})
 		} else {
 			input = append(input, &model.BOMLine{
-				Name: comp.Name,
-				Item: coItemSym.GetDigest(),
-				Qty:  comp.Qty,
+				Name:   comp.Name,
+				Item:   coItemSym.GetDigest(),
+				Qty:    comp.Qty,
+				Unit:   comp.Unit,
+				Refdes: comp.Refdes,
 			})
 		}
 	}
@@ -140,6 +303,78 @@ func (p *Parser) processKeywordFROM(ctx *ParserContext, from []*UnresolvedBOMLin
 	return ret, nil
 }
 
+// drawingPieces splits a line of a drawing into one line per component. A
+// placed line is a single component. A line without placement stands for
+// as many components at the origin as it has pieces.
+func drawingPieces(line *UnresolvedBOMLine, base string) ([]*UnresolvedBOMLine, error) {
+	if line.HasPlacement {
+		if line.Qty != 1 {
+			return nil, cerror.ErrorWithRange(fmt.Sprintf("component %s of a drawing must have qty 1, "+
+				"write one line per placement", line.label()), line.Range)
+		}
+		if len(line.Refdes) > 1 {
+			return nil, cerror.ErrorWithRange("a placed component takes a single designator", line.Range)
+		}
+		return []*UnresolvedBOMLine{line}, nil
+	}
+	lineUnit := line.Unit
+	if lineUnit == "" {
+		lineUnit = base
+	}
+	if !unit.Compatible(lineUnit, unit.DEFAULT) || line.Qty < 1 || line.Qty != math.Trunc(line.Qty) {
+		return nil, cerror.ErrorWithRange(fmt.Sprintf("component %s of a drawing must be a whole number of pieces, "+
+			"not %g %s; give it a placement or list it in a separate process", line.label(), line.Qty, displayUnit(lineUnit)), line.Range)
+	}
+	count := int(line.Qty)
+	pieces := make([]*UnresolvedBOMLine, 0, count)
+	for i := range count {
+		piece := *line
+		piece.Qty = 1
+		piece.Unit = ""
+		piece.Placement = model.IdentityPlacement
+		if count > 1 && line.Name != "" {
+			piece.Name = fmt.Sprintf("%s[%d]", line.Name, i)
+		}
+		if len(line.Refdes) == count {
+			piece.Refdes = line.Refdes[i : i+1]
+		}
+		pieces = append(pieces, &piece)
+	}
+	return pieces, nil
+}
+
+func baseUnit(content *model.ItemContent) string {
+	if content == nil {
+		return ""
+	}
+	return content.Unit
+}
+
+func displayUnit(name string) string {
+	if name == "" {
+		return unit.DEFAULT
+	}
+	return name
+}
+
+// checkUnit verifies that a BOM line can be counted in the unit of the
+// item or coitem it references. A line without unit is in that unit.
+func checkUnit(line *UnresolvedBOMLine, content *model.ItemContent, qualifier string) error {
+	base := baseUnit(content)
+	if line.Unit == "" {
+		if len(line.Refdes) > 0 && !unit.Compatible(base, unit.DEFAULT) {
+			return cerror.ErrorWithRange(fmt.Sprintf("refdes requires a quantity in pieces, %s is counted in %s",
+				qualifier, displayUnit(base)), line.Range)
+		}
+		return nil
+	}
+	if unit.Compatible(line.Unit, base) {
+		return nil
+	}
+	return cerror.ErrorWithRange(fmt.Sprintf("unit %s of %s is incompatible with unit %s of %s",
+		displayUnit(line.Unit), line.Name, displayUnit(base), qualifier), line.Range)
+}
+
 func (p *Parser) readContractLine(ctx *ParserContext, expr hcl.Expression) ([]Ref, error) {
 	ret := make([]Ref, 0)
 	switch e := expr.(type) {
@@ -164,13 +399,13 @@ func (p *Parser) readContractLine(ctx *ParserContext, expr hcl.Expression) ([]Re
 func (p *Parser) resolveContractsID(ctx *ParserContext, contracts []Ref) ([]model.ContractID, error) {
 	ret := make([]model.ContractID, 0, len(contracts))
 	for _, ref := range contracts {
-		sym, err := p.Resolve(ctx, ref)
+		sym, err := p.resolveConcreteRef(ctx, ref)
 		if err != nil {
 			return nil, err
 		}
 		contract, ok := sym.(*model.Contract)
 		if !ok {
-			return nil, errors.New("implement non contract")
+			return nil, fmt.Errorf("%s is not a contract", joinRef(ref))
 		}
 		ret = append(ret, contract.Digest)
 	}
@@ -6,6 +6,8 @@ import (
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/zclconf/go-cty/cty"
+
+	"github.com/tychonis/cyanotype/internal/cerror"
 )
 
 type ErrorWithRange struct {
@@ -25,52 +27,77 @@ func extractAttributes(body *hclsyntax.Body) (hcl.Attributes, error) {
 	return attrs, nil
 }
 
-func getString(attrs hcl.Attributes, key string) (string, error) {
-	attr, ok := attrs[key]
-	if !ok {
-		return "", errors.New("key not found")
-	}
-	val, diags := attr.Expr.Value(nil)
+func evalString(ctx *ParserContext, expr hcl.Expression) (string, error) {
+	val, diags := expr.Value(ctx.Eval)
 	if diags.HasErrors() {
 		return "", diags
 	}
-	if val.Type() != cty.String {
+	if val.Type() != cty.String || val.IsNull() || !val.IsKnown() {
 		return "", errors.New("incorrect type")
 	}
 	return val.AsString(), nil
 }
 
-func getNumber(attrs hcl.Attributes, key string) (float64, error) {
-	attr, ok := attrs[key]
-	if !ok {
-		return 0, errors.New("key not found")
-	}
-	val, diags := attr.Expr.Value(nil)
+func evalNumber(ctx *ParserContext, expr hcl.Expression) (float64, error) {
+	val, diags := expr.Value(ctx.Eval)
 	if diags.HasErrors() {
 		return 0, diags
 	}
-	if val.Type() != cty.String {
+	if val.Type() != cty.Number || val.IsNull() || !val.IsKnown() {
 		return 0, errors.New("incorrect type")
 	}
 	ret, _ := val.AsBigFloat().Float64()
 	return ret, nil
 }
 
-func getFloat64Array(attrs hcl.Attributes, key string) ([]float64, error) {
+func getString(ctx *ParserContext, attrs hcl.Attributes, key string) (string, error) {
+	attr, ok := attrs[key]
+	if !ok {
+		return "", errors.New("key not found")
+	}
+	return evalString(ctx, attr.Expr)
+}
+
+// getOptionalString evaluates an attribute that may be omitted. Present
+// attributes must evaluate to a string.
+func getOptionalString(ctx *ParserContext, attrs hcl.Attributes, key string) (string, error) {
+	attr, ok := attrs[key]
+	if !ok {
+		return "", nil
+	}
+	ret, err := evalString(ctx, attr.Expr)
+	if err != nil && !cerror.HasRange(err) {
+		return "", cerror.ErrorWithRange(key+": "+err.Error(), attr.Expr.Range())
+	}
+	return ret, err
+}
+
+func getNumber(ctx *ParserContext, attrs hcl.Attributes, key string) (float64, error) {
+	attr, ok := attrs[key]
+	if !ok {
+		return 0, errors.New("key not found")
+	}
+	return evalNumber(ctx, attr.Expr)
+}
+
+func getFloat64Array(ctx *ParserContext, attrs hcl.Attributes, key string) ([]float64, error) {
 	attr, ok := attrs[key]
 	if !ok {
 		return nil, errors.New("key not found")
 	}
-	val, diags := attr.Expr.Value(nil)
+	val, diags := attr.Expr.Value(ctx.Eval)
 	if diags.HasErrors() {
 		return nil, diags
 	}
-	if val.Type() != cty.List(cty.Number) {
+	if !val.CanIterateElements() {
 		return nil, errors.New("incorrect type")
 	}
 	slice := val.AsValueSlice()
 	ret := make([]float64, 0, len(slice))
 	for _, num := range slice {
+		if num.Type() != cty.Number {
+			return nil, errors.New("incorrect type")
+		}
 		f, _ := num.AsBigFloat().Float64()
 		ret = append(ret, f)
 	}
@@ -95,22 +122,41 @@ func getTraverserName(t hcl.Traverser) string {
 		return t.Name
 	case hcl.TraverseAttr:
 		return t.Name
+	case hcl.TraverseIndex:
+		key, _ := InstanceKey(t.Key)
+		return key
 	default:
 		return ""
 	}
 }
 
 func exprToRef(ctx *ParserContext, expr hcl.Expression) (Ref, error) {
-	se, ok := expr.(*hclsyntax.ScopeTraversalExpr)
-	if !ok {
+	switch e := expr.(type) {
+	case *hclsyntax.ScopeTraversalExpr:
+		ref := make(Ref, 0)
+		if ctx.CurrentModule() != "." {
+			ref = append(ref, ctx.CurrentModule())
+		}
+		for _, n := range e.Traversal {
+			ref = append(ref, getTraverserName(n))
+		}
+		return ref, nil
+	case *hclsyntax.IndexExpr:
+		// Reference to a family member with a computed key, e.g. bolt[each.key].
+		ref, err := exprToRef(ctx, e.Collection)
+		if err != nil {
+			return nil, err
+		}
+		val, diags := e.Key.Value(ctx.Eval)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		key, err := InstanceKey(val)
+		if err != nil {
+			return nil, err
+		}
+		return append(ref, key), nil
+	default:
 		return nil, errors.New("incorrect expr type")
 	}
-	ref := make(Ref, 0)
-	if ctx.CurrentModule() != "." {
-		ref = append(ref, ctx.CurrentModule())
-	}
-	for _, n := range se.Traversal {
-		ref = append(ref, getTraverserName(n))
-	}
-	return ref, nil
 }
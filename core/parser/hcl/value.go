@@ -0,0 +1,85 @@
+package hcl
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/tychonis/cyanotype/internal/cerror"
+	"github.com/tychonis/cyanotype/internal/stable"
+)
+
+// ctyToGo converts an evaluated value into plain Go values that serialize
+// deterministically: string, float64, bool, []any, stable.Map or nil.
+func ctyToGo(val cty.Value) (any, error) {
+	if !val.IsWhollyKnown() {
+		return nil, errors.New("value must be known")
+	}
+	if val.IsNull() {
+		return nil, nil
+	}
+	ty := val.Type()
+	switch {
+	case ty == cty.String:
+		return val.AsString(), nil
+	case ty == cty.Number:
+		f, _ := val.AsBigFloat().Float64()
+		return f, nil
+	case ty == cty.Bool:
+		return val.True(), nil
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		ret := make([]any, 0, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			v, err := ctyToGo(elem)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, v)
+		}
+		return ret, nil
+	case ty.IsMapType() || ty.IsObjectType():
+		ret := make(stable.Map, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			key, elem := it.Element()
+			v, err := ctyToGo(elem)
+			if err != nil {
+				return nil, err
+			}
+			ret[key.AsString()] = v
+		}
+		return ret, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", ty.FriendlyName())
+	}
+}
+
+func evalValue(ctx *ParserContext, attr *hcl.Attribute) (any, error) {
+	val, diags := attr.Expr.Value(ctx.Eval)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	ret, err := ctyToGo(val)
+	if err != nil {
+		return nil, cerror.ErrorWithRange(attr.Name+": "+err.Error(), attr.Expr.Range())
+	}
+	return ret, nil
+}
+
+// evalAttributes evaluates every attribute not listed in exclude.
+func evalAttributes(ctx *ParserContext, attrs hcl.Attributes, exclude map[string]struct{}) (stable.Map, error) {
+	ret := make(stable.Map)
+	for key, attr := range attrs {
+		if _, ok := exclude[key]; ok {
+			continue
+		}
+		val, err := evalValue(ctx, attr)
+		if err != nil {
+			return ret, err
+		}
+		ret[key] = val
+	}
+	return ret, nil
+}
@@ -0,0 +1,270 @@
+package hcl
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+
+	"github.com/tychonis/cyanotype/internal/cerror"
+)
+
+// Variable is an input declared with a `variable` block.
+type Variable struct {
+	Name    string
+	Type    cty.Type
+	Default *cty.Value
+	Range   hcl.Range
+}
+
+func newEvalContext() *hcl.EvalContext {
+	return &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var":   cty.EmptyObjectVal,
+			"local": cty.EmptyObjectVal,
+		},
+		Functions: Functions(),
+	}
+}
+
+func (p *Parser) parseVariableBlock(ctx *ParserContext, block *hclsyntax.Block) error {
+	if len(block.Labels) != 1 {
+		return cerror.ErrorWithRange("variable block must have exactly one label", block.DefRange())
+	}
+	name := block.Labels[0]
+	if _, ok := ctx.Variables[name]; ok {
+		return cerror.ErrorWithRange(fmt.Sprintf("variable %s already declared", name), block.DefRange())
+	}
+	v := &Variable{
+		Name:  name,
+		Type:  cty.DynamicPseudoType,
+		Range: block.DefRange(),
+	}
+	attrs, diags := block.Body.JustAttributes()
+	if diags.HasErrors() {
+		return diags
+	}
+	if attr, ok := attrs["type"]; ok {
+		ty, diags := typeexpr.TypeConstraint(attr.Expr)
+		if diags.HasErrors() {
+			return diags
+		}
+		v.Type = ty
+	}
+	if attr, ok := attrs["default"]; ok {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return diags
+		}
+		val, err := convert.Convert(val, v.Type)
+		if err != nil {
+			return cerror.ErrorWithRange(fmt.Sprintf("invalid default for variable %s: %s", name, err), attr.Range)
+		}
+		v.Default = &val
+	}
+	ctx.Variables[name] = v
+	return nil
+}
+
+func (p *Parser) parseLocalsBlock(ctx *ParserContext, block *hclsyntax.Block) error {
+	attrs, diags := block.Body.JustAttributes()
+	if diags.HasErrors() {
+		return diags
+	}
+	for name, attr := range attrs {
+		if _, ok := ctx.Locals[name]; ok {
+			return cerror.ErrorWithRange(fmt.Sprintf("local %s already declared", name), attr.Range)
+		}
+		ctx.Locals[name] = attr
+	}
+	return nil
+}
+
+// readVarFile reads `name = value` assignments from a variable definitions
+// file. Files are read through the parser so that diagnostics show them.
+func (p *Parser) readVarFile(path string) (hcl.Attributes, error) {
+	file, diags := p.files.ParseHCLFile(path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	attrs, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return attrs, nil
+}
+
+// parseRawVariable interprets a value given on the command line. Strings are
+// taken verbatim, anything else is parsed as an HCL expression.
+func parseRawVariable(v *Variable, raw string) (cty.Value, error) {
+	if v.Type == cty.String || v.Type == cty.DynamicPseudoType {
+		return cty.StringVal(raw), nil
+	}
+	expr, diags := hclsyntax.ParseExpression([]byte(raw), "<value for var."+v.Name+">", hcl.InitialPos)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+	return val, nil
+}
+
+func (p *Parser) variableOverrides(ctx *ParserContext) (map[string]cty.Value, error) {
+	ret := make(map[string]cty.Value)
+	if ctx.CurrentModule() != "." {
+		return ret, nil
+	}
+	for _, path := range p.Options.VarFiles {
+		attrs, err := p.readVarFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range slices.Sorted(maps.Keys(attrs)) {
+			attr := attrs[name]
+			if _, ok := ctx.Variables[name]; !ok {
+				return nil, cerror.ErrorWithRange("value given for undeclared variable "+name, attr.NameRange)
+			}
+			val, diags := attr.Expr.Value(nil)
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			ret[name] = val
+		}
+	}
+	for name, raw := range p.Options.Vars {
+		v, ok := ctx.Variables[name]
+		if !ok {
+			return nil, fmt.Errorf("value given for undeclared variable %s", name)
+		}
+		val, err := parseRawVariable(v, raw)
+		if err != nil {
+			return nil, err
+		}
+		ret[name] = val
+	}
+	return ret, nil
+}
+
+func (p *Parser) evaluateVariables(ctx *ParserContext) error {
+	overrides, err := p.variableOverrides(ctx)
+	if err != nil {
+		return err
+	}
+	vars := make(map[string]cty.Value, len(ctx.Variables))
+	for name, v := range ctx.Variables {
+		val, ok := overrides[name]
+		if !ok {
+			if v.Default == nil {
+				return cerror.ErrorWithRange(fmt.Sprintf("no value for required variable %s", name), v.Range)
+			}
+			vars[name] = *v.Default
+			continue
+		}
+		val, err := convert.Convert(val, v.Type)
+		if err != nil {
+			return cerror.ErrorWithRange(fmt.Sprintf("invalid value for variable %s: %s", name, err), v.Range)
+		}
+		vars[name] = val
+	}
+	ctx.Eval.Variables["var"] = cty.ObjectVal(vars)
+	return nil
+}
+
+func localDependencies(expr hcl.Expression) []string {
+	ret := make([]string, 0)
+	for _, traversal := range expr.Variables() {
+		if traversal.RootName() != "local" || len(traversal) < 2 {
+			continue
+		}
+		attr, ok := traversal[1].(hcl.TraverseAttr)
+		if ok {
+			ret = append(ret, attr.Name)
+		}
+	}
+	return ret
+}
+
+// evaluateLocals evaluates locals in dependency order, since a local may
+// reference any other local of the same module.
+func (p *Parser) evaluateLocals(ctx *ParserContext) error {
+	pending := make([]string, 0, len(ctx.Locals))
+	for name := range ctx.Locals {
+		pending = append(pending, name)
+	}
+	sort.Strings(pending)
+
+	locals := make(map[string]cty.Value, len(ctx.Locals))
+	for len(pending) > 0 {
+		next := make([]string, 0, len(pending))
+		for _, name := range pending {
+			attr := ctx.Locals[name]
+			ready := true
+			for _, dep := range localDependencies(attr.Expr) {
+				if dep != name && slices.Contains(pending, dep) {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				next = append(next, name)
+				continue
+			}
+			ctx.Eval.Variables["local"] = cty.ObjectVal(locals)
+			val, diags := attr.Expr.Value(ctx.Eval)
+			if diags.HasErrors() {
+				return diags
+			}
+			locals[name] = val
+		}
+		if len(next) == len(pending) {
+			return cerror.ErrorWithRange(
+				"cyclic reference between locals: "+strings.Join(pending, ", "),
+				ctx.Locals[pending[0]].Range,
+			)
+		}
+		pending = next
+	}
+	ctx.Eval.Variables["local"] = cty.ObjectVal(locals)
+	return nil
+}
+
+func (p *Parser) evaluateContext(ctx *ParserContext) error {
+	err := p.evaluateVariables(ctx)
+	if err != nil {
+		return err
+	}
+	return p.evaluateLocals(ctx)
+}
+
+func (p *Parser) evaluateContexts() error {
+	modules := make([]string, 0, len(p.contexts))
+	for module := range p.contexts {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	for _, module := range modules {
+		err := p.evaluateContext(p.contexts[module])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseVarArg parses a `name=value` command line assignment.
+func ParseVarArg(arg string) (string, string, error) {
+	name, value, ok := strings.Cut(arg, "=")
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("invalid variable assignment %q, expected name=value", arg)
+	}
+	return name, value, nil
+}
@@ -40,3 +40,7 @@ func (a *Abstract) GetInput() []*model.BOMLine {
 func (a *Abstract) GetOutput() []*model.BOMLine {
 	return a.Output
 }
+
+func (a *Abstract) GetDetails() stable.Map {
+	return a.Details
+}
@@ -13,3 +13,7 @@ func TestAbstractImplementProcessContent(t *testing.T) {
 func TestMarshallJSONForAbstract(t *testing.T) {
 	testMarshallJSON(t, &process.Abstract{})
 }
+
+func TestAbstractImplementAuthorable(t *testing.T) {
+	var _ process.Authorable = (*process.Abstract)(nil)
+}
@@ -0,0 +1,69 @@
+package process
+
+import (
+	"fmt"
+
+	"github.com/tychonis/cyanotype/internal/stable"
+	"github.com/tychonis/cyanotype/model"
+)
+
+// Authored is the content of a `process` block as written in source.
+type Authored struct {
+	Name   string
+	Input  []*model.BOMLine
+	Output []*model.BOMLine
+	// Placements is parallel to Input, with nil for lines without placement.
+	Placements []*model.Placement
+	Details    stable.Map
+}
+
+// Authorable is implemented by content types that can be written directly
+// in source.
+type Authorable interface {
+	ProcessContent
+	Author(a *Authored) error
+}
+
+// NewContent returns an empty content of a registered type.
+func NewContent(contentType string) (ProcessContent, error) {
+	ctor, ok := processContentTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("unknown content type %q", contentType)
+	}
+	return ctor(), nil
+}
+
+func (a *Abstract) Author(authored *Authored) error {
+	a.Name = authored.Name
+	a.Input = authored.Input
+	a.Output = authored.Output
+	a.Details = authored.Details
+	return nil
+}
+
+func (d *Drawing) Author(authored *Authored) error {
+	d.Name = authored.Name
+	d.Components = make([]*Component, 0, len(authored.Input))
+	for i, line := range authored.Input {
+		placement := model.IdentityPlacement
+		if i < len(authored.Placements) && authored.Placements[i] != nil {
+			placement = *authored.Placements[i]
+		}
+		if line.Qty != 1 {
+			return fmt.Errorf("component %s of a drawing must have qty 1", line.Name)
+		}
+		component := &Component{
+			Name:        line.Name,
+			CoItem:      line.Item,
+			Rotation:    &placement.Rotation,
+			Translation: &placement.Position,
+		}
+		if len(line.Refdes) == 1 {
+			component.Refdes = line.Refdes[0]
+		}
+		d.Components = append(d.Components, component)
+	}
+	d.Output = authored.Output
+	d.Details = authored.Details
+	return nil
+}
@@ -17,6 +17,7 @@ type Component struct {
 	CoItem      model.ItemID      `json:"coitem" yaml:"coitem"`
 	Rotation    *model.Quaternion `json:"rotation" yaml:"rotation"`
 	Translation *model.Vec3       `json:"translation" yaml:"translation"`
+	Refdes      string            `json:"refdes,omitempty" yaml:"refdes,omitempty"`
 }
 
 type Drawing struct {
@@ -43,11 +44,15 @@ func (d *Drawing) GetType() string {
 func (d *Drawing) GetInput() []*model.BOMLine {
 	ret := make([]*model.BOMLine, 0, len(d.Components))
 	for _, component := range d.Components {
-		ret = append(ret, &model.BOMLine{
+		line := &model.BOMLine{
 			Name: component.Name,
 			Item: component.CoItem,
 			Qty:  1,
-		})
+		}
+		if component.Refdes != "" {
+			line.Refdes = []string{component.Refdes}
+		}
+		ret = append(ret, line)
 	}
 	return ret
 }
@@ -55,3 +60,7 @@ func (d *Drawing) GetInput() []*model.BOMLine {
 func (d *Drawing) GetOutput() []*model.BOMLine {
 	return d.Output
 }
+
+func (d *Drawing) GetDetails() stable.Map {
+	return d.Details
+}
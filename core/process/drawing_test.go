@@ -13,3 +13,7 @@ func TestDrawingImplementProcessContent(t *testing.T) {
 func TestMarshallJSONForDrawing(t *testing.T) {
 	testMarshallJSON(t, &process.Drawing{})
 }
+
+func TestDrawingImplementAuthorable(t *testing.T) {
+	var _ process.Authorable = (*process.Drawing)(nil)
+}
@@ -2,9 +2,9 @@ package process
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 
+	"github.com/tychonis/cyanotype/internal/stable"
 	"github.com/tychonis/cyanotype/model"
 )
 
@@ -56,6 +56,29 @@ func (pb *ProcessBase) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// attributes exposes the process to references such as frame_proc.output.
+// Inputs and outputs are lists of BOM lines.
+func (pb *ProcessBase) attributes() stable.Map {
+	ret := stable.Map{
+		"qualifier": pb.Qualifier,
+		"digest":    pb.Digest,
+		"type":      pb.Type,
+		"input":     []any{},
+		"output":    []any{},
+		"details":   stable.Map{},
+	}
+	if pb.Content == nil {
+		return ret
+	}
+	ret["name"] = pb.Content.GetName()
+	ret["input"] = model.BOMLineAttributes(pb.Content.GetInput())
+	ret["output"] = model.BOMLineAttributes(pb.Content.GetOutput())
+	if details := pb.Content.GetDetails(); details != nil {
+		ret["details"] = details
+	}
+	return ret
+}
+
 type Process struct {
 	ProcessBase
 }
@@ -69,31 +92,29 @@ type ProcessContent interface {
 	GetType() string
 	GetInput() []*model.BOMLine
 	GetOutput() []*model.BOMLine
+	GetDetails() stable.Map
 }
 
 func (p *Process) Input() []*model.BOMLine {
+	if p.Content == nil {
+		return nil
+	}
 	return p.Content.GetInput()
 }
 
 func (p *Process) Output() []*model.BOMLine {
+	if p.Content == nil {
+		return nil
+	}
 	return p.Content.GetOutput()
 }
 
 func (p *Process) Resolve(path []string) (model.Symbol, error) {
-	if len(path) == 0 {
-		return p, nil
-	}
-	if len(path) < 2 {
-		return nil, errors.New("insufficient path length")
-	}
-	switch path[0] {
-	case "input":
-		return nil, nil
-	case "output":
-		return nil, nil
-	default:
-		return nil, errors.New("illformed token")
-	}
+	return model.ResolveAttribute(p, path)
+}
+
+func (p *Process) Attributes() stable.Map {
+	return p.ProcessBase.attributes()
 }
 
 func (p *Process) GetQualifier() string {
@@ -109,28 +130,25 @@ func (p *Process) GetType() string {
 }
 
 func (cp *CoProcess) Input() []*model.BOMLine {
+	if cp.Content == nil {
+		return nil
+	}
 	return cp.Content.GetInput()
 }
 
 func (cp *CoProcess) Output() []*model.BOMLine {
+	if cp.Content == nil {
+		return nil
+	}
 	return cp.Content.GetOutput()
 }
 
 func (cp *CoProcess) Resolve(path []string) (model.Symbol, error) {
-	if len(path) == 0 {
-		return cp, nil
-	}
-	if len(path) < 2 {
-		return nil, errors.New("insufficient path length")
-	}
-	switch path[0] {
-	case "input":
-		return nil, nil
-	case "output":
-		return nil, nil
-	default:
-		return nil, errors.New("illformed token")
-	}
+	return model.ResolveAttribute(cp, path)
+}
+
+func (cp *CoProcess) Attributes() stable.Map {
+	return cp.ProcessBase.attributes()
 }
 
 func (cp *CoProcess) GetQualifier() string {
@@ -1,15 +1,42 @@
 package qualifier
 
-import "github.com/tychonis/cyanotype/model"
+import (
+	"strings"
+
+	"github.com/tychonis/cyanotype/model"
+)
+
+// Suffixes of the companions generated for every item.
+const (
+	PROCESS   = ".__process__"
+	COPROCESS = ".__coprocess__"
+	COITEM    = ".__coitem__"
+)
 
 func ImplicitProcess(item *model.Item) string {
-	return item.Qualifier + ".__process__"
+	return item.Qualifier + PROCESS
 }
 
 func ImplicitCoProcess(item *model.Item) string {
-	return item.Qualifier + ".__coprocess__"
+	return item.Qualifier + COPROCESS
 }
 
 func ImplicitCoItem(item *model.Item) string {
-	return item.Qualifier + ".__coitem__"
+	return item.Qualifier + COITEM
+}
+
+// IsImplicit reports whether q names a companion of an item.
+func IsImplicit(q string) bool {
+	return strings.HasSuffix(q, PROCESS) || strings.HasSuffix(q, COPROCESS) || strings.HasSuffix(q, COITEM)
+}
+
+// Owner returns the qualifier of the item a companion was generated for, or
+// q itself.
+func Owner(q string) string {
+	for _, suffix := range []string{PROCESS, COPROCESS, COITEM} {
+		if owner, ok := strings.CutSuffix(q, suffix); ok {
+			return owner
+		}
+	}
+	return q
 }
@@ -0,0 +1,74 @@
+package ranker
+
+import (
+	"sort"
+
+	"github.com/tychonis/cyanotype/core/process"
+)
+
+// PriorityRanker prefers processes and coprocesses with a higher `priority`
+// detail. Candidates of equal priority keep the order of the base ranker.
+type PriorityRanker struct {
+	Base Ranker
+}
+
+func NewPriorityRanker(base Ranker) *PriorityRanker {
+	return &PriorityRanker{
+		Base: base,
+	}
+}
+
+func priority(content process.ProcessContent) float64 {
+	if content == nil {
+		return 0
+	}
+	p, ok := content.GetDetails()["priority"].(float64)
+	if !ok {
+		return 0
+	}
+	return p
+}
+
+func (r *PriorityRanker) RankCoProcess(cps []*process.CoProcess) ([]*process.CoProcess, error) {
+	ranked, err := r.Base.RankCoProcess(cps)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return priority(ranked[i].Content) > priority(ranked[j].Content)
+	})
+	return ranked, nil
+}
+
+func (r *PriorityRanker) TopCoProcess(cps []*process.CoProcess) (*process.CoProcess, error) {
+	ranked, err := r.RankCoProcess(cps)
+	if err != nil {
+		return nil, err
+	}
+	if len(ranked) > 0 {
+		return ranked[0], nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *PriorityRanker) RankProcess(ps []*process.Process) ([]*process.Process, error) {
+	ranked, err := r.Base.RankProcess(ps)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return priority(ranked[i].Content) > priority(ranked[j].Content)
+	})
+	return ranked, nil
+}
+
+func (r *PriorityRanker) TopProcess(ps []*process.Process) (*process.Process, error) {
+	ranked, err := r.RankProcess(ps)
+	if err != nil {
+		return nil, err
+	}
+	if len(ranked) > 0 {
+		return ranked[0], nil
+	}
+	return nil, ErrNotFound
+}
@@ -0,0 +1,43 @@
+package ranker_test
+
+import (
+	"testing"
+
+	"github.com/tychonis/cyanotype/core/process"
+	"github.com/tychonis/cyanotype/core/ranker"
+	"github.com/tychonis/cyanotype/internal/stable"
+)
+
+func coProcess(name string, details stable.Map) *process.CoProcess {
+	cp := &process.CoProcess{}
+	cp.Qualifier = name
+	cp.Content = &process.Abstract{Details: details}
+	return cp
+}
+
+func TestPriorityRanker(t *testing.T) {
+	r := ranker.NewPriorityRanker(&ranker.NaiveRanker{})
+	cps := []*process.CoProcess{
+		coProcess("implicit", nil),
+		coProcess("low", stable.Map{"priority": -1.0}),
+		coProcess("high", stable.Map{"priority": 5.0}),
+		coProcess("tie", nil),
+	}
+	ranked, err := r.RankCoProcess(cps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"high", "implicit", "tie", "low"}
+	for i, cp := range ranked {
+		if cp.Qualifier != want[i] {
+			t.Fatalf("rank %d = %s, want %s", i, cp.Qualifier, want[i])
+		}
+	}
+	top, err := r.TopCoProcess(cps)
+	if err != nil || top.Qualifier != "high" {
+		t.Errorf("TopCoProcess = %v, %v", top, err)
+	}
+	if _, err := r.TopCoProcess(nil); err != ranker.ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package cerror
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/hashicorp/hcl/v2"
@@ -21,3 +22,65 @@ func ErrorWithRange(msg string, r hcl.Range) *ErrWithRange {
 		msg: msg,
 	}
 }
+
+func (e *ErrWithRange) Range() hcl.Range {
+	return e.r
+}
+
+func (e *ErrWithRange) Message() string {
+	return e.msg
+}
+
+// HasRange reports whether err already points at a source location.
+func HasRange(err error) bool {
+	var rangeErr *ErrWithRange
+	if errors.As(err, &rangeErr) {
+		return true
+	}
+	var diags hcl.Diagnostics
+	if errors.As(err, &diags) {
+		for _, diag := range diags {
+			if diag.Subject != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Diagnostics converts err into diagnostics, keeping source ranges where
+// they are known. Joined errors become one diagnostic each.
+func Diagnostics(err error) hcl.Diagnostics {
+	if err == nil {
+		return nil
+	}
+	switch e := err.(type) {
+	case hcl.Diagnostics:
+		return e
+	case *hcl.Diagnostic:
+		return hcl.Diagnostics{e}
+	case *ErrWithRange:
+		r := e.r
+		return hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  e.msg,
+			Subject:  &r,
+		}}
+	case interface{ Unwrap() []error }:
+		var ret hcl.Diagnostics
+		for _, inner := range e.Unwrap() {
+			ret = ret.Extend(Diagnostics(inner))
+		}
+		return ret
+	}
+	diag := &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  err.Error(),
+	}
+	var rangeErr *ErrWithRange
+	if errors.As(err, &rangeErr) {
+		r := rangeErr.r
+		diag.Subject = &r
+	}
+	return hcl.Diagnostics{diag}
+}
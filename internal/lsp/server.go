@@ -0,0 +1,400 @@
+// Package lsp implements a language server for bpo files on top of the HCL
+// parser, so editors get the diagnostics and symbols `build` sees.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tychonis/cyanotype/core/parser/hcl"
+	"github.com/tychonis/cyanotype/internal/symbols"
+	"github.com/tychonis/cyanotype/internal/version"
+	"github.com/tychonis/cyanotype/model"
+)
+
+type Server struct {
+	conn *Conn
+
+	// docs holds the text of open documents, keyed by filename.
+	docs map[string][]byte
+	// builds holds the last build of each folder and parsed the last one
+	// that read every file, which stays useful while a document has syntax
+	// errors.
+	builds map[string]*hcl.Parser
+	parsed map[string]*hcl.Parser
+	// published records the documents each folder reported diagnostics on.
+	published map[string][]string
+
+	shutdown bool
+}
+
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{
+		conn:      NewConn(r, w),
+		docs:      make(map[string][]byte),
+		builds:    make(map[string]*hcl.Parser),
+		parsed:    make(map[string]*hcl.Parser),
+		published: make(map[string][]string),
+	}
+}
+
+// Serve handles messages until the client sends exit or closes the stream.
+func (s *Server) Serve() error {
+	for {
+		msg, err := s.conn.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var rerr *ResponseError
+		if errors.As(err, &rerr) {
+			s.conn.Reply(nil, nil, rerr)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		}
+		result, err := s.handle(msg)
+		if msg.ID == nil {
+			if err != nil {
+				slog.Warn("Failed to handle notification.", "method", msg.Method, "error", err)
+			}
+			continue
+		}
+		err = s.conn.Reply(msg.ID, result, err)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func decode[T any](raw json.RawMessage) (*T, error) {
+	params := new(T)
+	err := json.Unmarshal(raw, params)
+	if err != nil {
+		return nil, &ResponseError{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return params, nil
+}
+
+func (s *Server) handle(msg *Message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		return s.initialize()
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		params, err := decode[DidOpenTextDocumentParams](msg.Params)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, []byte(params.TextDocument.Text))
+	case "textDocument/didChange":
+		params, err := decode[DidChangeTextDocumentParams](msg.Params)
+		if err != nil || len(params.ContentChanges) == 0 {
+			return nil, err
+		}
+		last := params.ContentChanges[len(params.ContentChanges)-1]
+		return nil, s.update(params.TextDocument.URI, []byte(last.Text))
+	case "textDocument/didSave":
+		params, err := decode[DidCloseTextDocumentParams](msg.Params)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.rebuild(filepath.Dir(URIToPath(params.TextDocument.URI)))
+	case "textDocument/didClose":
+		params, err := decode[DidCloseTextDocumentParams](msg.Params)
+		if err != nil {
+			return nil, err
+		}
+		filename := URIToPath(params.TextDocument.URI)
+		delete(s.docs, filename)
+		return nil, s.rebuild(filepath.Dir(filename))
+	case "textDocument/definition":
+		params, err := decode[TextDocumentPositionParams](msg.Params)
+		if err != nil {
+			return nil, err
+		}
+		return s.definition(params)
+	case "textDocument/hover":
+		params, err := decode[TextDocumentPositionParams](msg.Params)
+		if err != nil {
+			return nil, err
+		}
+		return s.hover(params)
+	case "textDocument/completion":
+		params, err := decode[TextDocumentPositionParams](msg.Params)
+		if err != nil {
+			return nil, err
+		}
+		return s.completion(params)
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+		return nil, nil
+	default:
+		if msg.ID == nil {
+			return nil, nil
+		}
+		return nil, &ResponseError{Code: CodeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+}
+
+func (s *Server) initialize() (any, error) {
+	return &InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:   1,
+			DefinitionProvider: true,
+			HoverProvider:      true,
+			CompletionProvider: &CompletionOptions{TriggerCharacters: []string{"."}},
+		},
+		ServerInfo: ServerInfo{Name: "cyanotype", Version: version.Version},
+	}, nil
+}
+
+func (s *Server) update(uri string, text []byte) error {
+	filename := URIToPath(uri)
+	s.docs[filename] = text
+	return s.rebuild(filepath.Dir(filename))
+}
+
+// rebuild builds the folder the way `build` does, with open documents in
+// place of the files on disk, and publishes the diagnostics.
+func (s *Server) rebuild(dir string) error {
+	p := hcl.NewParser()
+	p.Options.IgnoreArtifacts = true
+	p.Options.NoLock = true
+	p.Options.Overlay = maps.Clone(s.docs)
+	err := p.Build(dir)
+	if err != nil {
+		slog.Debug("Build failed.", "dir", dir, "error", err)
+	}
+	s.builds[dir] = p
+	if p.Parsed() {
+		s.parsed[dir] = p
+	}
+	return s.publish(dir, p)
+}
+
+func (s *Server) publish(dir string, p *hcl.Parser) error {
+	byURI := make(map[string][]Diagnostic)
+	for _, diag := range p.Diagnostics() {
+		// Diagnostics without a range are shown at the top of the folder's
+		// first file.
+		filename := filepath.Join(dir, "main"+hcl.EXTENSION)
+		if files := p.ModuleFiles("."); len(files) > 0 {
+			filename = files[0]
+		}
+		if diag.Subject != nil {
+			filename = diag.Subject.Filename
+		}
+		uri := PathToURI(filename)
+		byURI[uri] = append(byURI[uri], toDiagnostic(s.parsedText(p, filename), diag))
+	}
+	for _, uri := range s.published[dir] {
+		if _, ok := byURI[uri]; !ok {
+			byURI[uri] = []Diagnostic{}
+		}
+	}
+	uris := slices.Sorted(maps.Keys(byURI))
+	for _, uri := range uris {
+		err := s.conn.Notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+			URI:         uri,
+			Diagnostics: byURI[uri],
+		})
+		if err != nil {
+			return err
+		}
+	}
+	s.published[dir] = slices.DeleteFunc(uris, func(uri string) bool { return len(byURI[uri]) == 0 })
+	return nil
+}
+
+// parserFor returns the build to answer queries about filename from.
+func (s *Server) parserFor(filename string) (*hcl.Parser, bool) {
+	dir := filepath.Dir(filename)
+	if p, ok := s.builds[dir]; ok && p.Parsed() {
+		return p, true
+	}
+	p, ok := s.parsed[dir]
+	return p, ok
+}
+
+func (s *Server) text(filename string) []byte {
+	if text, ok := s.docs[filename]; ok {
+		return text
+	}
+	text, _ := os.ReadFile(filename)
+	return text
+}
+
+// parsedText returns the text p read filename from, which the ranges it
+// reports are in.
+func (s *Server) parsedText(p *hcl.Parser, filename string) []byte {
+	if file, ok := p.Files()[filename]; ok && file != nil {
+		return file.Bytes
+	}
+	return s.text(filename)
+}
+
+func (s *Server) reference(params *TextDocumentPositionParams) (*hcl.Parser, *hcl.Reference, bool) {
+	filename := URIToPath(params.TextDocument.URI)
+	p, ok := s.parserFor(filename)
+	if !ok {
+		return nil, nil, false
+	}
+	ref, err := p.ReferenceAt(filename, offsetOf(s.text(filename), params.Position))
+	if err != nil {
+		return nil, nil, false
+	}
+	return p, ref, true
+}
+
+func (s *Server) definition(params *TextDocumentPositionParams) (any, error) {
+	p, ref, ok := s.reference(params)
+	if !ok {
+		return nil, nil
+	}
+	r, ok := p.Definition(ref.Symbol)
+	if !ok || strings.HasPrefix(r.Filename, hcl.GIT_PREFIX) {
+		return nil, nil
+	}
+	return &Location{URI: PathToURI(r.Filename), Range: toRange(s.parsedText(p, r.Filename), r)}, nil
+}
+
+func describe(p *hcl.Parser, sym model.Symbol) string {
+	switch s := sym.(type) {
+	case *symbols.Import:
+		return fmt.Sprintf("**module** `%s`", s.Identifier)
+	case *hcl.UnprocessedFamily:
+		return fmt.Sprintf("**%s family** `%s` with %d members", s.Block.Type, s.Block.Labels[0], len(s.Members))
+	}
+	concrete, ok := p.Concrete(sym)
+	if !ok {
+		if us, ok := sym.(*hcl.UnprocessedSymbol); ok {
+			return fmt.Sprintf("**%s** `%s`", us.Block.Type, us.Block.Labels[0])
+		}
+		return ""
+	}
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "**%s** `%s`\n\n", concrete.GetType(), concrete.GetQualifier())
+	var content *model.ItemContent
+	switch c := concrete.(type) {
+	case *model.Item:
+		content = c.Content
+	case *model.CoItem:
+		content = c.Content
+	}
+	if content != nil && content.PartNumber != "" {
+		fmt.Fprintf(sb, "Part number: `%s`\n\n", content.PartNumber)
+	}
+	fmt.Fprintf(sb, "Digest: `%s`", concrete.GetDigest())
+	return sb.String()
+}
+
+func (s *Server) hover(params *TextDocumentPositionParams) (any, error) {
+	p, ref, ok := s.reference(params)
+	if !ok {
+		return nil, nil
+	}
+	value := describe(p, ref.Symbol)
+	if value == "" {
+		return nil, nil
+	}
+	r := toRange(s.parsedText(p, ref.Range.Filename), ref.Range)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: value}, Range: &r}, nil
+}
+
+func isRefChar(c byte) bool {
+	return c == '.' || c == '_' || c == '-' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func keywordItems(keys []string, kind int) []CompletionItem {
+	ret := make([]CompletionItem, 0, len(keys))
+	for _, key := range keys {
+		ret = append(ret, CompletionItem{Label: key, Kind: kind})
+	}
+	return ret
+}
+
+// symbolItems lists the symbols visible after path, e.g. the symbols of an
+// imported module after `std.`.
+func symbolItems(p *hcl.Parser, module string, path []string) []CompletionItem {
+	scope, ok := p.Symbols.Modules[module]
+	if !ok {
+		return nil
+	}
+	for _, name := range path {
+		imp, ok := scope.Symbols[name].(*symbols.Import)
+		if !ok {
+			return nil
+		}
+		scope, ok = p.Symbols.Modules[imp.Identifier]
+		if !ok {
+			return nil
+		}
+	}
+	ret := make([]CompletionItem, 0, len(scope.Symbols))
+	for _, name := range slices.Sorted(maps.Keys(scope.Symbols)) {
+		item := CompletionItem{Label: name, Kind: CompletionKindClass}
+		switch sym := scope.Symbols[name].(type) {
+		case *symbols.Import:
+			item.Kind = CompletionKindModule
+			item.Detail = sym.Identifier
+		case *hcl.UnprocessedFamily:
+			item.Detail = sym.Block.Type + " family"
+		case *hcl.UnprocessedSymbol:
+			item.Detail = sym.Block.Type
+			if concrete, ok := p.Concrete(sym); ok {
+				item.Detail += " " + concrete.GetQualifier()
+			}
+		}
+		ret = append(ret, item)
+	}
+	return ret
+}
+
+// completion offers block types at the top level, reserved keys where an
+// attribute name is expected, and symbols inside expressions.
+func (s *Server) completion(params *TextDocumentPositionParams) (any, error) {
+	filename := URIToPath(params.TextDocument.URI)
+	text := s.text(filename)
+	offset := offsetOf(text, params.Position)
+	lineStart := strings.LastIndexByte(string(text[:offset]), '\n') + 1
+	line := string(text[lineStart:offset])
+
+	p, ok := s.parserFor(filename)
+	if !ok {
+		return &CompletionList{Items: keywordItems(hcl.BLOCK_TYPES, CompletionKindKeyword)}, nil
+	}
+	block, ok := p.BlockAt(filename, offset)
+	if !ok {
+		return &CompletionList{Items: keywordItems(hcl.BLOCK_TYPES, CompletionKindKeyword)}, nil
+	}
+	if !strings.Contains(line, "=") {
+		return &CompletionList{Items: keywordItems(hcl.ReservedKeys(block.Type), CompletionKindField)}, nil
+	}
+	start := len(line)
+	for start > 0 && isRefChar(line[start-1]) {
+		start--
+	}
+	parts := strings.Split(line[start:], ".")
+	module, ok := p.ModuleOf(filename)
+	if !ok {
+		return &CompletionList{Items: []CompletionItem{}}, nil
+	}
+	return &CompletionList{Items: symbolItems(p, module, parts[:len(parts)-1])}, nil
+}
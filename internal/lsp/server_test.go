@@ -0,0 +1,248 @@
+package lsp_test
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/tychonis/cyanotype/internal/lsp"
+)
+
+type client struct {
+	t        *testing.T
+	conn     *lsp.Conn
+	id       int
+	done     chan error
+	messages chan *lsp.Message
+	notices  []*lsp.Message
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{
+		t:        t,
+		conn:     lsp.NewConn(clientIn, clientOut),
+		done:     make(chan error, 1),
+		messages: make(chan *lsp.Message, 64),
+	}
+	go func() {
+		c.done <- lsp.NewServer(serverIn, serverOut).Serve()
+		serverOut.Close()
+	}()
+	// Pipes are synchronous, so messages from the server are read while the
+	// client writes.
+	go func() {
+		defer close(c.messages)
+		for {
+			msg, err := c.conn.Read()
+			if err != nil {
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	t.Cleanup(func() { clientOut.Close() })
+	return c
+}
+
+// call sends a request and returns its result, keeping the notifications
+// received meanwhile.
+func (c *client) call(method string, params any, result any) {
+	c.t.Helper()
+	c.id++
+	id := json.RawMessage(strconv.Itoa(c.id))
+	raw, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	err = c.conn.Write(&lsp.Message{ID: &id, Method: method, Params: raw})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for msg := range c.messages {
+		if msg.ID == nil {
+			c.notices = append(c.notices, msg)
+			continue
+		}
+		if msg.Error != nil {
+			c.t.Fatalf("%s failed: %v", method, msg.Error)
+		}
+		if result != nil {
+			data, _ := json.Marshal(msg.Result)
+			err = json.Unmarshal(data, result)
+			if err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+	c.t.Fatalf("server closed before answering %s", method)
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	err = c.conn.Write(&lsp.Message{Method: method, Params: raw})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// diagnostics returns the last diagnostics published for uri.
+func (c *client) diagnostics(uri string) []lsp.Diagnostic {
+	var ret []lsp.Diagnostic
+	for _, msg := range c.notices {
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		params := &lsp.PublishDiagnosticsParams{}
+		json.Unmarshal(msg.Params, params)
+		if params.URI == uri {
+			ret = params.Diagnostics
+		}
+	}
+	return ret
+}
+
+const lspSource = `item "bolt" {
+  part_number = "B-1"
+}
+
+item "frame" {
+  from = [{ name = "bolt", ref = bolt, qty = 2 }]
+}
+`
+
+func position(t *testing.T, text string, needle string) lsp.Position {
+	t.Helper()
+	i := strings.Index(text, needle)
+	if i < 0 {
+		t.Fatalf("%q not found", needle)
+	}
+	line := strings.Count(text[:i], "\n")
+	return lsp.Position{Line: line, Character: i - strings.LastIndex(text[:i], "\n") - 1}
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "main.bpo")
+	err := os.WriteFile(filename, []byte(lspSource), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := lsp.PathToURI(filename)
+	doc := lsp.TextDocumentIdentifier{URI: uri}
+
+	c := newClient(t)
+	init := &lsp.InitializeResult{}
+	c.call("initialize", map[string]any{"rootUri": lsp.PathToURI(dir)}, init)
+	if !init.Capabilities.DefinitionProvider || !init.Capabilities.HoverProvider {
+		t.Errorf("unexpected capabilities %+v", init.Capabilities)
+	}
+	c.notify("initialized", map[string]any{})
+
+	// An unsaved edit breaking the reference is reported.
+	broken := strings.Replace(lspSource, "ref = bolt", "ref = nut", 1)
+	c.notify("textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "bpo", Text: broken},
+	})
+	c.call("textDocument/hover", lsp.TextDocumentPositionParams{TextDocument: doc}, nil)
+	diags := c.diagnostics(uri)
+	if len(diags) != 1 || !strings.Contains(diags[0].Message, "nut") || diags[0].Range.Start.Line != 5 {
+		t.Fatalf("expected one diagnostic for nut on line 6, got %+v", diags)
+	}
+
+	c.notify("textDocument/didChange", lsp.DidChangeTextDocumentParams{
+		TextDocument:   doc,
+		ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: lspSource}},
+	})
+
+	at := lsp.TextDocumentPositionParams{TextDocument: doc, Position: position(t, lspSource, "bolt, qty")}
+	loc := &lsp.Location{}
+	c.call("textDocument/definition", at, loc)
+	if loc.URI != uri || loc.Range.Start.Line != 0 {
+		t.Errorf("expected definition of bolt on the first line, got %+v", loc)
+	}
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Errorf("expected diagnostics to be cleared, got %+v", diags)
+	}
+
+	hover := &lsp.Hover{}
+	c.call("textDocument/hover", at, hover)
+	if !strings.Contains(hover.Contents.Value, "B-1") || !strings.Contains(hover.Contents.Value, "Digest") {
+		t.Errorf("expected hover to show part number and digest, got %q", hover.Contents.Value)
+	}
+
+	list := &lsp.CompletionList{}
+	c.call("textDocument/completion", at, list)
+	labels := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		labels = append(labels, item.Label)
+	}
+	if strings.Join(labels, ",") != "bolt,frame" {
+		t.Errorf("expected item completions, got %v", labels)
+	}
+
+	at.Position = position(t, lspSource, "part_number")
+	c.call("textDocument/completion", at, list)
+	found := false
+	for _, item := range list.Items {
+		found = found || item.Label == "unit"
+	}
+	if !found {
+		t.Errorf("expected reserved keys, got %+v", list.Items)
+	}
+
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerUTF16Ranges(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "main.bpo")
+	source := `item "frame" {
+  from = [{ name = "🔩 bolt", ref = nut, qty = 2 }]
+}
+`
+	err := os.WriteFile(filename, []byte(source), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := lsp.PathToURI(filename)
+
+	c := newClient(t)
+	c.call("initialize", map[string]any{"rootUri": lsp.PathToURI(dir)}, nil)
+	c.notify("initialized", map[string]any{})
+	c.notify("textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "bpo", Text: source},
+	})
+	c.call("textDocument/hover", lsp.TextDocumentPositionParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}}, nil)
+
+	// The bolt emoji takes two UTF-16 code units.
+	line := strings.Split(source, "\n")[1]
+	start := len(utf16.Encode([]rune(line[:strings.Index(line, "nut")])))
+	diags := c.diagnostics(uri)
+	if len(diags) != 1 || diags[0].Range.Start != (lsp.Position{Line: 1, Character: start}) ||
+		diags[0].Range.End != (lsp.Position{Line: 1, Character: start + 3}) {
+		t.Fatalf("expected one diagnostic on nut at character %d, got %+v", start, diags)
+	}
+
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Fatal(err)
+	}
+}
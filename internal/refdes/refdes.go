@@ -0,0 +1,105 @@
+package refdes
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// split cuts a designator such as "R12" into its prefix and number. The
+// number is -1 for designators without a trailing number.
+func split(d string) (string, int) {
+	i := len(d)
+	for i > 0 && '0' <= d[i-1] && d[i-1] <= '9' {
+		i--
+	}
+	if i == len(d) {
+		return d, -1
+	}
+	n, err := strconv.Atoi(d[i:])
+	if err != nil {
+		return d, -1
+	}
+	return d[:i], n
+}
+
+// Expand lists the designators of a spec such as "R1..R4, C3". Ranges must
+// share their prefix and count upwards.
+func Expand(spec string) ([]string, error) {
+	ret := make([]string, 0)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty designator in %q", spec)
+		}
+		from, to, ok := strings.Cut(part, "..")
+		if !ok {
+			ret = append(ret, part)
+			continue
+		}
+		fromPrefix, fromN := split(strings.TrimSpace(from))
+		toPrefix, toN := split(strings.TrimSpace(to))
+		if fromN < 0 || toN < 0 || fromPrefix != toPrefix || fromN > toN {
+			return nil, fmt.Errorf("invalid designator range %q", part)
+		}
+		for n := fromN; n <= toN; n++ {
+			ret = append(ret, fromPrefix+strconv.Itoa(n))
+		}
+	}
+	return ret, nil
+}
+
+// Duplicate returns the first designator listed twice, if any.
+func Duplicate(list []string) (string, bool) {
+	seen := make(map[string]bool, len(list))
+	for _, d := range list {
+		if seen[d] {
+			return d, true
+		}
+		seen[d] = true
+	}
+	return "", false
+}
+
+// Compare orders designators by prefix, then by number, so R2 comes before
+// R10.
+func Compare(a, b string) int {
+	ap, an := split(a)
+	bp, bn := split(b)
+	if c := strings.Compare(ap, bp); c != 0 {
+		return c
+	}
+	if an != bn {
+		return an - bn
+	}
+	return strings.Compare(a, b)
+}
+
+// Format sorts designators and writes runs of three or more as ranges, the
+// reverse of Expand.
+func Format(list []string) string {
+	sorted := slices.Clone(list)
+	slices.SortFunc(sorted, Compare)
+	sorted = slices.Compact(sorted)
+	parts := make([]string, 0, len(sorted))
+	for i := 0; i < len(sorted); {
+		prefix, n := split(sorted[i])
+		j := i + 1
+		for j < len(sorted) && n >= 0 {
+			p, m := split(sorted[j])
+			if p != prefix || m != n+j-i || sorted[j] != prefix+strconv.Itoa(m) {
+				break
+			}
+			j++
+		}
+		if j-i >= 3 && sorted[i] == prefix+strconv.Itoa(n) {
+			parts = append(parts, sorted[i]+".."+sorted[j-1])
+		} else {
+			j = i + 1
+			parts = append(parts, sorted[i])
+		}
+		i = j
+	}
+	return strings.Join(parts, ", ")
+}
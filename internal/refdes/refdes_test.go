@@ -0,0 +1,35 @@
+package refdes_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/tychonis/cyanotype/internal/refdes"
+)
+
+func TestExpand(t *testing.T) {
+	got, err := refdes.Expand("R1..R4, C3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"R1", "R2", "R3", "R4", "C3"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, spec := range []string{"R4..R1", "R1..C4", "R..R4", "R1,,R2"} {
+		if _, err := refdes.Expand(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	got := refdes.Format([]string{"R10", "C3", "R2", "R1", "R3", "C4", "J1"})
+	if got != "C3, C4, J1, R1..R3, R10" {
+		t.Errorf("unexpected format %q", got)
+	}
+	list, err := refdes.Expand(got)
+	if err != nil || len(list) != 7 {
+		t.Errorf("format should round trip, got %v: %v", list, err)
+	}
+}
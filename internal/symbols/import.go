@@ -12,6 +12,9 @@ type Import struct {
 }
 
 func (i *Import) Resolve(path []string) (model.Symbol, error) {
+	if len(path) == 0 {
+		return i, nil
+	}
 	m, ok := i.Symbols.Modules[i.Identifier].Symbols[path[0]]
 	if !ok {
 		return nil, errors.New("sybmol not existed")
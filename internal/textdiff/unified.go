@@ -0,0 +1,150 @@
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const contextLines = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineOps computes a line based edit script from a to b using the longest
+// common subsequence. Source files are small enough for the quadratic table.
+func lineOps(a, b []string) []op {
+	m, n := len(a), len(b)
+	lcs := make([][]int, m+1)
+	for i := range lcs {
+		lcs[i] = make([]int, n+1)
+	}
+	for i := m - 1; i >= 0; i-- {
+		for j := n - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]op, 0, m+n)
+	i, j := 0, 0
+	for i < m && j < n {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{opEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{opDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, op{opInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < m; i++ {
+		ops = append(ops, op{opDelete, a[i]})
+	}
+	for ; j < n; j++ {
+		ops = append(ops, op{opInsert, b[j]})
+	}
+	return ops
+}
+
+func writeLine(sb *strings.Builder, prefix byte, line string) {
+	sb.WriteByte(prefix)
+	sb.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// Unified returns a unified diff between a and b, or an empty string if they
+// are identical.
+func Unified(nameA, nameB string, a, b string) string {
+	if a == b {
+		return ""
+	}
+	ops := lineOps(splitLines(a), splitLines(b))
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "--- %s\n+++ %s\n", nameA, nameB)
+
+	// Line numbers (0 based) in a and b at the start of each op.
+	posA := make([]int, len(ops)+1)
+	posB := make([]int, len(ops)+1)
+	for k, o := range ops {
+		posA[k+1], posB[k+1] = posA[k], posB[k]
+		if o.kind != opInsert {
+			posA[k+1]++
+		}
+		if o.kind != opDelete {
+			posB[k+1]++
+		}
+	}
+
+	k := 0
+	for k < len(ops) {
+		if ops[k].kind == opEqual {
+			k++
+			continue
+		}
+		start := max(k-contextLines, 0)
+		end := k
+		// Extend the hunk while changes are within 2*context of each other.
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*contextLines {
+				end = min(end+contextLines, len(ops))
+				break
+			}
+			end = run
+		}
+
+		fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n",
+			posA[start]+1, posA[end]-posA[start],
+			posB[start]+1, posB[end]-posB[start])
+		for _, o := range ops[start:end] {
+			switch o.kind {
+			case opEqual:
+				writeLine(sb, ' ', o.line)
+			case opDelete:
+				writeLine(sb, '-', o.line)
+			case opInsert:
+				writeLine(sb, '+', o.line)
+			}
+		}
+		k = end
+	}
+	return sb.String()
+}
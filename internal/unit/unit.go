@@ -0,0 +1,108 @@
+package unit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Dimension string
+
+const (
+	Count  Dimension = "count"
+	Length Dimension = "length"
+	Mass   Dimension = "mass"
+	Volume Dimension = "volume"
+)
+
+// DEFAULT is the unit assumed when none is given.
+const DEFAULT = "pcs"
+
+type Unit struct {
+	Name      string
+	Dimension Dimension
+	// Factor converts one of this unit into the base unit of its dimension.
+	Factor float64
+}
+
+var units = map[string]Unit{
+	"pcs": {"pcs", Count, 1},
+	"ea":  {"ea", Count, 1},
+
+	"mm": {"mm", Length, 0.001},
+	"cm": {"cm", Length, 0.01},
+	"m":  {"m", Length, 1},
+	"in": {"in", Length, 0.0254},
+	"ft": {"ft", Length, 0.3048},
+
+	"mg": {"mg", Mass, 0.000001},
+	"g":  {"g", Mass, 0.001},
+	"kg": {"kg", Mass, 1},
+	"oz": {"oz", Mass, 0.028349523125},
+	"lb": {"lb", Mass, 0.45359237},
+
+	"ml": {"ml", Volume, 0.001},
+	"l":  {"l", Volume, 1},
+}
+
+// Lookup returns the unit with the given name, an empty name is DEFAULT.
+func Lookup(name string) (Unit, error) {
+	if name == "" {
+		name = DEFAULT
+	}
+	u, ok := units[name]
+	if !ok {
+		return Unit{}, fmt.Errorf("unknown unit %q", name)
+	}
+	return u, nil
+}
+
+func Compatible(a, b string) bool {
+	ua, err := Lookup(a)
+	if err != nil {
+		return false
+	}
+	ub, err := Lookup(b)
+	if err != nil {
+		return false
+	}
+	return ua.Dimension == ub.Dimension
+}
+
+// Convert converts qty from one unit into another of the same dimension.
+func Convert(qty float64, from, to string) (float64, error) {
+	uf, err := Lookup(from)
+	if err != nil {
+		return 0, err
+	}
+	ut, err := Lookup(to)
+	if err != nil {
+		return 0, err
+	}
+	if uf.Dimension != ut.Dimension {
+		return 0, fmt.Errorf("cannot convert %s (%s) to %s (%s)", uf.Name, uf.Dimension, ut.Name, ut.Dimension)
+	}
+	if uf.Factor == ut.Factor {
+		return qty, nil
+	}
+	return qty * uf.Factor / ut.Factor, nil
+}
+
+// ParseQuantity parses a quantity such as "2.5 m" or "4".
+func ParseQuantity(s string) (float64, string, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, "", fmt.Errorf("invalid quantity %q", s)
+	}
+	qty, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid quantity %q", s)
+	}
+	if len(fields) == 1 {
+		return qty, "", nil
+	}
+	if _, err := Lookup(fields[1]); err != nil {
+		return 0, "", err
+	}
+	return qty, fields[1], nil
+}
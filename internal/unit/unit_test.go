@@ -0,0 +1,57 @@
+package unit_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tychonis/cyanotype/internal/unit"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		qty      float64
+		from, to string
+		want     float64
+	}{
+		{2500, "mm", "m", 2.5},
+		{1, "in", "mm", 25.4},
+		{250, "g", "kg", 0.25},
+		{4, "", "pcs", 4},
+		{3, "ea", "", 3},
+	}
+	for _, tt := range tests {
+		got, err := unit.Convert(tt.qty, tt.from, tt.to)
+		if err != nil {
+			t.Errorf("Convert(%v, %q, %q) error: %v", tt.qty, tt.from, tt.to, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Convert(%v, %q, %q) = %v, want %v", tt.qty, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestConvertIncompatible(t *testing.T) {
+	for _, pair := range [][2]string{{"m", "kg"}, {"pcs", "mm"}, {"furlong", "m"}} {
+		_, err := unit.Convert(1, pair[0], pair[1])
+		if err == nil {
+			t.Errorf("Convert(1, %q, %q) should fail", pair[0], pair[1])
+		}
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	qty, u, err := unit.ParseQuantity("2.5 m")
+	if err != nil || qty != 2.5 || u != "m" {
+		t.Errorf("ParseQuantity(2.5 m) = %v, %q, %v", qty, u, err)
+	}
+	qty, u, err = unit.ParseQuantity("4")
+	if err != nil || qty != 4 || u != "" {
+		t.Errorf("ParseQuantity(4) = %v, %q, %v", qty, u, err)
+	}
+	for _, bad := range []string{"", "m", "2 m extra", "2 parsecs"} {
+		if _, _, err := unit.ParseQuantity(bad); err == nil {
+			t.Errorf("ParseQuantity(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/tychonis/cyanotype/internal/digest"
+)
+
+// Poller watches the files of a few folders by polling, which works the
+// same on every platform and for folders on network drives.
+type Poller struct {
+	// Interval is the time between two scans.
+	Interval time.Duration
+	// Quiet is how long files must stay unchanged after a change before
+	// Wait returns, so that rapid saves are reported once.
+	Quiet time.Duration
+	// Match selects the files watched by name.
+	Match func(name string) bool
+
+	folders map[string]bool
+	files   map[string]*fileState
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+	digest  string
+}
+
+func NewPoller(match func(name string) bool) *Poller {
+	return &Poller{
+		Interval: 300 * time.Millisecond,
+		Quiet:    300 * time.Millisecond,
+		Match:    match,
+		folders:  make(map[string]bool),
+		files:    make(map[string]*fileState),
+	}
+}
+
+// Scan returns the files of folders changed since the last scan: created,
+// removed or with a new content. Files are only read again when their size
+// or modification time changed. Folders not scanned before are recorded
+// without reporting their files.
+func (p *Poller) Scan(folders []string) ([]string, error) {
+	changed := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, dir := range folders {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		known := p.folders[dir]
+		p.folders[dir] = true
+		for _, entry := range entries {
+			if entry.IsDir() || !p.Match(entry.Name()) {
+				continue
+			}
+			filename := filepath.Join(dir, entry.Name())
+			seen[filename] = true
+			modified, err := p.update(filename)
+			if err != nil {
+				return nil, err
+			}
+			if modified && known {
+				changed = append(changed, filename)
+			}
+		}
+	}
+	for filename := range p.files {
+		if !seen[filename] && slices.Contains(folders, filepath.Dir(filename)) {
+			delete(p.files, filename)
+			changed = append(changed, filename)
+		}
+	}
+	slices.Sort(changed)
+	return changed, nil
+}
+
+// update records the state of a file and reports whether its content
+// changed.
+func (p *Poller) update(filename string) (bool, error) {
+	info, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	prev, ok := p.files[filename]
+	if ok && prev.size == info.Size() && prev.modTime.Equal(info.ModTime()) {
+		return false, nil
+	}
+	sum, err := digest.SHA256FromFile(filename)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	p.files[filename] = &fileState{size: info.Size(), modTime: info.ModTime(), digest: sum}
+	return !ok || prev.digest != sum, nil
+}
+
+// Wait scans folders until a file changes, then until nothing has changed
+// for Quiet, and returns every file changed meanwhile. It returns the error
+// of ctx once it is done.
+func (p *Poller) Wait(ctx context.Context, folders []string) ([]string, error) {
+	changed := make([]string, 0)
+	var last time.Time
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		files, err := p.Scan(folders)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		if len(files) > 0 {
+			last = now
+			for _, f := range files {
+				if !slices.Contains(changed, f) {
+					changed = append(changed, f)
+				}
+			}
+		}
+		if len(changed) > 0 && now.Sub(last) >= p.Quiet {
+			slices.Sort(changed)
+			return changed, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package watch_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tychonis/cyanotype/internal/watch"
+)
+
+func bpo(name string) bool {
+	return strings.HasSuffix(name, ".bpo")
+}
+
+func write(t *testing.T, path string, content string) {
+	t.Helper()
+	err := os.WriteFile(path, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.bpo")
+	write(t, main, `item "a" {}`)
+	write(t, filepath.Join(dir, "notes.txt"), "x")
+
+	p := watch.NewPoller(bpo)
+	changed, err := p.Scan([]string{dir})
+	if err != nil || len(changed) != 0 {
+		t.Fatalf("first scan should only record files: %v %v", changed, err)
+	}
+
+	write(t, filepath.Join(dir, "notes.txt"), "y")
+	write(t, main, `item "a" {}`)
+	os.Chtimes(main, time.Now(), time.Now().Add(time.Second))
+	changed, err = p.Scan([]string{dir})
+	if err != nil || len(changed) != 0 {
+		t.Fatalf("unchanged content should not be reported: %v %v", changed, err)
+	}
+
+	other := filepath.Join(dir, "other.bpo")
+	write(t, main, `item "b" {}`)
+	write(t, other, `item "c" {}`)
+	changed, err = p.Scan([]string{dir})
+	if err != nil || !slices.Equal(changed, []string{main, other}) {
+		t.Fatalf("unexpected changes: %v %v", changed, err)
+	}
+
+	os.Remove(other)
+	changed, err = p.Scan([]string{dir})
+	if err != nil || !slices.Equal(changed, []string{other}) {
+		t.Fatalf("removal not reported: %v %v", changed, err)
+	}
+}
+
+func TestWaitDebounces(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.bpo")
+	write(t, main, "0")
+
+	p := watch.NewPoller(bpo)
+	p.Interval = 10 * time.Millisecond
+	p.Quiet = 100 * time.Millisecond
+	_, err := p.Scan([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for _, content := range []string{"1", "12", "123"} {
+			time.Sleep(20 * time.Millisecond)
+			os.WriteFile(main, []byte(content), 0o644)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changed, err := p.Wait(ctx, []string{dir})
+	if err != nil || !slices.Equal(changed, []string{main}) {
+		t.Fatalf("unexpected changes: %v %v", changed, err)
+	}
+	content, _ := os.ReadFile(main)
+	if string(content) != "123" {
+		t.Errorf("Wait returned before the last save: %q", content)
+	}
+
+	cancel()
+	_, err = p.Wait(ctx, []string{dir})
+	if err != context.Canceled {
+		t.Errorf("expected cancellation, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tychonis/cyanotype/internal/stable"
+)
+
+// Attributed symbols expose attributes to references such as
+// deck.part_number or motor.details.voltage. Values are plain Go values as
+// produced by the parser: string, float64, bool, []any, stable.Map or nil.
+type Attributed interface {
+	Symbol
+	Attributes() stable.Map
+}
+
+// Value is an attribute reached through a symbol.
+type Value struct {
+	Value any
+}
+
+func (v *Value) Resolve(path []string) (Symbol, error) {
+	return resolveValue(v.Value, path)
+}
+
+// ResolveAttribute resolves path into the attributes of sym.
+func ResolveAttribute(sym Attributed, path []string) (Symbol, error) {
+	if len(path) == 0 {
+		return sym, nil
+	}
+	return resolveValue(sym.Attributes(), path)
+}
+
+// pathKey strips the brackets of an index such as `[0]` or `["key"]`.
+func pathKey(part string) (string, bool) {
+	if !strings.HasPrefix(part, "[") || !strings.HasSuffix(part, "]") {
+		return part, false
+	}
+	key := part[1 : len(part)-1]
+	if unquoted, err := strconv.Unquote(key); err == nil {
+		return unquoted, true
+	}
+	return key, true
+}
+
+func resolveValue(val any, path []string) (Symbol, error) {
+	for _, part := range path {
+		key, indexed := pathKey(part)
+		switch v := val.(type) {
+		case stable.Map:
+			next, ok := v[key]
+			if !ok {
+				return nil, fmt.Errorf("no attribute %s", key)
+			}
+			val = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if !indexed || err != nil {
+				return nil, fmt.Errorf("list must be indexed by number, got %s", part)
+			}
+			if i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("index %d out of range for list of %d", i, len(v))
+			}
+			val = v[i]
+		default:
+			return nil, fmt.Errorf("cannot access %s of %T", part, val)
+		}
+	}
+	return &Value{Value: val}, nil
+}
+
+func contractList(ids []ContractID) []any {
+	ret := make([]any, 0, len(ids))
+	for _, id := range ids {
+		ret = append(ret, id)
+	}
+	return ret
+}
+
+func (c *ItemContent) attributes(ret stable.Map) {
+	if c == nil {
+		return
+	}
+	ret["name"] = c.Name
+	ret["part_number"] = c.PartNumber
+	ret["unit"] = c.Unit
+	ret["source"] = c.Source
+	details := c.Details
+	if details == nil {
+		details = stable.Map{}
+	}
+	ret["details"] = details
+}
+
+// BOMLineAttributes exposes BOM lines to references.
+func BOMLineAttributes(lines []*BOMLine) []any {
+	ret := make([]any, 0, len(lines))
+	for _, line := range lines {
+		ret = append(ret, stable.Map{
+			"name":   line.Name,
+			"item":   line.Item,
+			"qty":    line.Qty,
+			"unit":   line.Unit,
+			"refdes": refdesList(line.Refdes),
+		})
+	}
+	return ret
+}
+
+func refdesList(refdes []string) []any {
+	ret := make([]any, 0, len(refdes))
+	for _, d := range refdes {
+		ret = append(ret, d)
+	}
+	return ret
+}
@@ -1,7 +1,8 @@
 package model
 
 import (
-	"errors"
+	"reflect"
+	"slices"
 
 	"github.com/tychonis/cyanotype/internal/stable"
 )
@@ -17,16 +18,71 @@ type Contract struct {
 	Digest ContractID `json:"-" yaml:"-"`
 }
 
-// TODO: implement attrs?
 func (c *Contract) Resolve(path []string) (Symbol, error) {
-	if len(path) > 0 {
-		return nil, errors.New("attr not implemented")
+	return ResolveAttribute(c, path)
+}
+
+func (c *Contract) Attributes() stable.Map {
+	params := c.Params
+	if params == nil {
+		params = stable.Map{}
+	}
+	return stable.Map{
+		"qualifier": c.Qualifier,
+		"name":      c.Name,
+		"digest":    c.Digest,
+		"params":    params,
 	}
-	return c, nil
 }
 
+// Fulfill reports whether c satisfies the requirement c2. Both must be the
+// same contract, and every parameter of c2 must hold the same value in c.
 func (c *Contract) Fulfill(c2 *Contract) bool {
-	return false
+	if c == nil || c2 == nil {
+		return false
+	}
+	if c.Digest != "" && c.Digest == c2.Digest {
+		return true
+	}
+	if c.Qualifier != c2.Qualifier {
+		return false
+	}
+	for key, val := range c2.Params {
+		own, ok := c.Params[key]
+		if !ok || !reflect.DeepEqual(own, val) {
+			return false
+		}
+	}
+	return true
+}
+
+type ContractLookup func(id ContractID) (*Contract, error)
+
+// Unfulfilled returns the contracts in require that no contract in implement
+// fulfills.
+func Unfulfilled(implement []ContractID, require []ContractID, lookup ContractLookup) ([]*Contract, error) {
+	if len(require) == 0 {
+		return nil, nil
+	}
+	implemented := make([]*Contract, 0, len(implement))
+	for _, id := range implement {
+		contract, err := lookup(id)
+		if err != nil {
+			return nil, err
+		}
+		implemented = append(implemented, contract)
+	}
+	ret := make([]*Contract, 0)
+	for _, id := range require {
+		required, err := lookup(id)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(implemented, func(c *Contract) bool { return c.Fulfill(required) }) {
+			ret = append(ret, required)
+		}
+	}
+	return ret, nil
 }
 
 func (c *Contract) GetQualifier() string {
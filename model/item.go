@@ -1,8 +1,6 @@
 package model
 
 import (
-	"errors"
-
 	"github.com/tychonis/cyanotype/internal/stable"
 )
 
@@ -35,6 +33,7 @@ type ItemContent struct {
 	Name       string      `json:"name" yaml:"name"`
 	Source     string      `json:"source,omitempty" yaml:"source,omitempty"`
 	PartNumber string      `json:"part_number" yaml:"part_number"`
+	Unit       string      `json:"unit,omitempty" yaml:"unit,omitempty"`
 	Artifacts  []*Artifact `json:"artifacts,omitempty" yaml:"artifacts,omitempty"`
 	Details    stable.Map  `json:"details" yaml:"details"`
 }
@@ -45,14 +44,29 @@ type Artifact struct {
 	Tag      string `json:"tag" yaml:"tag"`
 	Source   string `json:"source" yaml:"source"`
 	Digest   string `json:"digest" yaml:"digest"`
+	// Files lists the content of artifacts read from a directory or a glob.
+	// Digest is then the digest of the manifest of Files.
+	Files []*ArtifactFile `json:"files,omitempty" yaml:"files,omitempty"`
+}
+
+type ArtifactFile struct {
+	Path   string `json:"path" yaml:"path"`
+	Size   int64  `json:"size" yaml:"size"`
+	Digest string `json:"sha256" yaml:"sha256"`
 }
 
-// TODO: implement attrs?
 func (i *Item) Resolve(path []string) (Symbol, error) {
-	if len(path) > 0 {
-		return nil, errors.New("attr not implemented")
+	return ResolveAttribute(i, path)
+}
+
+func (i *Item) Attributes() stable.Map {
+	ret := stable.Map{
+		"qualifier": i.Qualifier,
+		"digest":    i.Digest,
+		"implement": contractList(i.Implement),
 	}
-	return i, nil
+	i.Content.attributes(ret)
+	return ret
 }
 
 func (i *Item) GetQualifier() string {
@@ -74,12 +88,18 @@ func (i *Item) GetName() string {
 	return i.GetQualifier()
 }
 
-// TODO: implement attrs?
 func (ci *CoItem) Resolve(path []string) (Symbol, error) {
-	if len(path) > 0 {
-		return nil, errors.New("attr not implemented")
+	return ResolveAttribute(ci, path)
+}
+
+func (ci *CoItem) Attributes() stable.Map {
+	ret := stable.Map{
+		"qualifier": ci.Qualifier,
+		"digest":    ci.Digest,
+		"require":   contractList(ci.Require),
 	}
-	return ci, nil
+	ci.Content.attributes(ret)
+	return ret
 }
 
 func (ci *CoItem) GetQualifier() string {
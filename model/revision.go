@@ -6,4 +6,6 @@ type Revision struct {
 	Digest    RevisionID   `json:"id"`
 	CreatedAt int64        `json:"created_at"`
 	Parents   []RevisionID `json:"parents"`
+	// Sources maps imported git modules to the commit they were read from.
+	Sources map[string]string `json:"sources,omitempty"`
 }
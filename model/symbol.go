@@ -17,4 +17,8 @@ type BOMLine struct {
 	Name string  `json:"name" yaml:"name"`
 	Item ItemID  `json:"item" yaml:"item"`
 	Qty  float64 `json:"qty" yaml:"qty"`
+	// Unit of Qty, empty means the base unit of the item.
+	Unit string `json:"unit,omitempty" yaml:"unit,omitempty"`
+	// Refdes lists the reference designators of the line, one per piece.
+	Refdes []string `json:"refdes,omitempty" yaml:"refdes,omitempty"`
 }